@@ -0,0 +1,87 @@
+package virtual_types
+
+import (
+	"testing"
+)
+
+type benchOperands struct {
+	name string
+	x, y func() Number
+}
+
+func benchOperandsList() []benchOperands {
+	integer := func() Number {
+		n, _ := NewIntegerRange(NewNRange(1, 10, true, true))
+		return n
+	}
+	return []benchOperands{
+		{"ConstInterned", func() Number { return NewNumberConst(3) }, func() Number { return NewNumberConst(4) }},
+		{"Const", func() Number { return NewNumberConst(3.5) }, func() Number { return NewNumberConst(4.25) }},
+		{"ConstRange", func() Number { return NewNumberConst(3) }, func() Number { return NewNumberSegment(1, 10) }},
+		{"Range", func() Number { return NewNumberSegment(1, 10) }, func() Number { return NewNumberSegment(2, 5) }},
+		{"Integer", integer, integer},
+	}
+}
+
+func benchmarkOperator(b *testing.B, f func(x, y Number) (Number, error)) {
+	for _, o := range benchOperandsList() {
+		b.Run(o.name, func(b *testing.B) {
+			x, y := o.x(), o.y()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := f(x, y); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAdd(b *testing.B)  { benchmarkOperator(b, Number.Add) }
+func BenchmarkSub(b *testing.B)  { benchmarkOperator(b, Number.Sub) }
+func BenchmarkMul(b *testing.B)  { benchmarkOperator(b, Number.Mul) }
+func BenchmarkDiv(b *testing.B)  { benchmarkOperator(b, Number.Div) }
+func BenchmarkIDiv(b *testing.B) { benchmarkOperator(b, Number.IDiv) }
+func BenchmarkPow(b *testing.B)  { benchmarkOperator(b, Number.Pow) }
+
+func benchmarkCompare(b *testing.B, f func(x, y Number) Boolean) {
+	for _, o := range benchOperandsList() {
+		b.Run(o.name, func(b *testing.B) {
+			x, y := o.x(), o.y()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f(x, y)
+			}
+		})
+	}
+}
+
+func BenchmarkLess(b *testing.B)         { benchmarkCompare(b, Number.Less) }
+func BenchmarkLessEqual(b *testing.B)    { benchmarkCompare(b, Number.LessEqual) }
+func BenchmarkGreater(b *testing.B)      { benchmarkCompare(b, Number.Greater) }
+func BenchmarkGreaterEqual(b *testing.B) { benchmarkCompare(b, Number.GreaterEqual) }
+func BenchmarkEqual(b *testing.B)        { benchmarkCompare(b, Number.Equal) }
+
+func TestConstAllocs(t *testing.T) {
+	x, y := NewNumberConst(3), NewNumberConst(4)
+	for name, f := range map[string]func(x, y Number) (Number, error){
+		"Add": Number.Add, "Sub": Number.Sub, "Mul": Number.Mul,
+		"IDiv": Number.IDiv, "Pow": Number.Pow,
+	} {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := f(x, y); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s of interned constants: %v allocs", name, allocs)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { x.Less(y) })
+	if allocs > 1 {
+		t.Errorf("Less of constants: %v allocs", allocs)
+	}
+}
@@ -0,0 +1,473 @@
+package virtual_types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// Binary encoding of value graph (see valueGraph), all integers are
+// uvarints:
+//
+//	magic "VTB", version
+//	roots count, root node indices
+//	nodes count, nodes
+//
+// Number node is tag 1, flags byte, constant or range edges, integer and
+// next references if flagged, constraints. Boolean node is tag 2, value
+// byte, constraints. Floats are stored as byte reversed IEEE 754 bits
+// so small integers take few bytes.
+//
+// Format is stable: codes below must never be reused, new versions must
+// keep reading older ones.
+
+const BINARY_VERSION = 1
+
+var binaryMagic = []byte("VTB")
+
+const (
+	binaryTagNumber  = 1
+	binaryTagBoolean = 2
+)
+
+const (
+	binaryFlagRange      = 1 << 0
+	binaryFlagLIncluding = 1 << 1
+	binaryFlagRIncluding = 1 << 2
+	binaryFlagInteger    = 1 << 3
+	binaryFlagNext       = 1 << 4
+	binaryFlagNaN        = 1 << 5
+)
+
+var binaryConstraintCodes = map[string]byte{
+	"NumberEqual":            1,
+	"NumberLess":             2,
+	"NumberLessEqual":        3,
+	"NumberGreater":          4,
+	"NumberGreaterEqual":     5,
+	"NumberOr":               6,
+	"NumberSelect":           7,
+	"NumberNotEqual":         8,
+	"NumberAnd":              9,
+	"NumberNot":              10,
+	"BooleanEqual":           16,
+	"BooleanNotEqual":        17,
+	"BooleanOr":              18,
+	"BooleanConjunction":     19,
+	"BooleanDisjunction":     20,
+	"BooleanNumberCompare":   21,
+	"BooleanDummyConstraint": 22,
+	"BooleanAnd":             23,
+	"BooleanNot":             24,
+}
+
+var binaryConstraintKinds = func() map[byte]string {
+	res := make(map[byte]string, len(binaryConstraintCodes))
+	for kind, code := range binaryConstraintCodes {
+		res[code] = kind
+	}
+	return res
+}()
+
+// ====== Encoding ======
+
+type binaryWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	w.buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func (w *binaryWriter) float(v float64) {
+	w.uvarint(bits.ReverseBytes64(math.Float64bits(v)))
+}
+
+func (w *binaryWriter) ref(id *int) {
+	w.uvarint(uint64(*id))
+}
+
+func (w *binaryWriter) constraint(c graphConstraint) error {
+	code, ok := binaryConstraintCodes[c.Kind]
+	if !ok {
+		return fmt.Errorf("could not encode %s constraint", c.Kind)
+	}
+	w.buf.WriteByte(code)
+	switch {
+	case c.Subject != nil:
+		w.ref(c.Subject)
+	case c.Cond != nil:
+		w.ref(c.Cond)
+		w.ref(c.Then)
+		w.ref(c.Else)
+	case c.Rel != nil:
+		w.uvarint(uint64(*c.Rel))
+		w.ref(c.LHS)
+		w.ref(c.RHS)
+	case c.Variants != nil:
+		return w.constraints(c.Variants)
+	case c.Kind == "BooleanConjunction" || c.Kind == "BooleanDisjunction":
+		w.uvarint(uint64(len(c.Operands)))
+		for _, o := range c.Operands {
+			w.uvarint(uint64(o))
+		}
+	}
+	return nil
+}
+
+func (w *binaryWriter) constraints(cs []graphConstraint) error {
+	w.uvarint(uint64(len(cs)))
+	for _, c := range cs {
+		if err := w.constraint(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *binaryWriter) node(n graphNode) error {
+	if n.Type == GRAPH_TYPE_BOOLEAN {
+		w.buf.WriteByte(binaryTagBoolean)
+		val, _ := parseBValue(n.Val)
+		w.buf.WriteByte(byte(val))
+		return w.constraints(n.Constraints)
+	}
+
+	w.buf.WriteByte(binaryTagNumber)
+	var flags byte
+	if n.Range != nil {
+		flags |= binaryFlagRange
+		if n.Range.LIncluding {
+			flags |= binaryFlagLIncluding
+		}
+		if n.Range.RIncluding {
+			flags |= binaryFlagRIncluding
+		}
+		if n.NaN {
+			flags |= binaryFlagNaN
+		}
+	}
+	if n.Integer != nil {
+		flags |= binaryFlagInteger
+	}
+	if n.Next != nil {
+		flags |= binaryFlagNext
+	}
+	w.buf.WriteByte(flags)
+
+	if n.Range != nil {
+		w.float(float64(n.Range.L))
+		w.float(float64(n.Range.R))
+	} else {
+		w.float(float64(*n.Const))
+	}
+	if n.Integer != nil {
+		w.ref(n.Integer)
+	}
+	if n.Next != nil {
+		w.ref(n.Next)
+	}
+	return w.constraints(n.Constraints)
+}
+
+// MarshalValuesBinary encodes values into single graph preserving sharing
+// between them.
+func MarshalValuesBinary(values ...BasicValue) ([]byte, error) {
+	g, err := encodeGraph(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &binaryWriter{}
+	w.buf.Write(binaryMagic)
+	w.uvarint(BINARY_VERSION)
+	w.uvarint(uint64(len(g.Roots)))
+	for _, id := range g.Roots {
+		w.uvarint(uint64(id))
+	}
+	w.uvarint(uint64(len(g.Nodes)))
+	for _, n := range g.Nodes {
+		if err := w.node(n); err != nil {
+			return nil, err
+		}
+	}
+	return w.buf.Bytes(), nil
+}
+
+func (n Number) MarshalBinary() ([]byte, error) {
+	return MarshalValuesBinary(n)
+}
+
+func (b Boolean) MarshalBinary() ([]byte, error) {
+	return MarshalValuesBinary(b)
+}
+
+// ====== Decoding ======
+
+type binaryReader struct {
+	r *bytes.Reader
+}
+
+func (r *binaryReader) byte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (r *binaryReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// count reads length of sequence, each element takes at least one byte
+func (r *binaryReader) count() (int, error) {
+	v, err := r.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(r.r.Len()) {
+		return 0, errors.New("sequence length exceeds data")
+	}
+	return int(v), nil
+}
+
+func (r *binaryReader) ref() (*int, error) {
+	v, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if v > math.MaxInt32 {
+		return nil, errors.New("invalid reference")
+	}
+	return ref(int(v)), nil
+}
+
+func (r *binaryReader) float() (jsonFloat, error) {
+	v, err := r.uvarint()
+	return jsonFloat(math.Float64frombits(bits.ReverseBytes64(v))), err
+}
+
+func (r *binaryReader) constraint() (graphConstraint, error) {
+	code, err := r.byte()
+	if err != nil {
+		return graphConstraint{}, err
+	}
+	kind, ok := binaryConstraintKinds[code]
+	if !ok {
+		return graphConstraint{}, fmt.Errorf("unknown constraint code %d", code)
+	}
+
+	c := graphConstraint{Kind: kind}
+	switch kind {
+	case "BooleanDummyConstraint":
+	case "NumberOr", "BooleanOr", "NumberAnd", "BooleanAnd", "NumberNot", "BooleanNot":
+		c.Variants, err = r.constraints()
+	case "BooleanConjunction", "BooleanDisjunction":
+		var n int
+		if n, err = r.count(); err != nil {
+			return c, err
+		}
+		c.Operands = make([]int, n)
+		for i := range c.Operands {
+			var o *int
+			if o, err = r.ref(); err != nil {
+				return c, err
+			}
+			c.Operands[i] = *o
+		}
+	case "NumberSelect":
+		if c.Cond, err = r.ref(); err != nil {
+			return c, err
+		}
+		if c.Then, err = r.ref(); err != nil {
+			return c, err
+		}
+		c.Else, err = r.ref()
+	case "BooleanNumberCompare":
+		var rel uint64
+		if rel, err = r.uvarint(); err != nil {
+			return c, err
+		}
+		nrel := NRelation(rel)
+		c.Rel = &nrel
+		if c.LHS, err = r.ref(); err != nil {
+			return c, err
+		}
+		c.RHS, err = r.ref()
+	default:
+		c.Subject, err = r.ref()
+	}
+	return c, err
+}
+
+func (r *binaryReader) constraints() ([]graphConstraint, error) {
+	n, err := r.count()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	cs := make([]graphConstraint, n)
+	for i := range cs {
+		if cs[i], err = r.constraint(); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+func (r *binaryReader) node() (graphNode, error) {
+	tag, err := r.byte()
+	if err != nil {
+		return graphNode{}, err
+	}
+
+	switch tag {
+	case binaryTagBoolean:
+		val, err := r.byte()
+		if err != nil {
+			return graphNode{}, err
+		}
+		n := graphNode{Type: GRAPH_TYPE_BOOLEAN, Val: BValue(val).String()}
+		n.Constraints, err = r.constraints()
+		return n, err
+	case binaryTagNumber:
+	default:
+		return graphNode{}, fmt.Errorf("unknown node tag %d", tag)
+	}
+
+	n := graphNode{Type: GRAPH_TYPE_NUMBER}
+	flags, err := r.byte()
+	if err != nil {
+		return n, err
+	}
+	if flags&binaryFlagRange != 0 {
+		n.Range = &graphRange{
+			LIncluding: flags&binaryFlagLIncluding != 0,
+			RIncluding: flags&binaryFlagRIncluding != 0,
+		}
+		n.NaN = flags&binaryFlagNaN != 0
+		if n.Range.L, err = r.float(); err != nil {
+			return n, err
+		}
+		if n.Range.R, err = r.float(); err != nil {
+			return n, err
+		}
+	} else {
+		val, err := r.float()
+		if err != nil {
+			return n, err
+		}
+		n.Const = &val
+	}
+	if flags&binaryFlagInteger != 0 {
+		if n.Integer, err = r.ref(); err != nil {
+			return n, err
+		}
+	}
+	if flags&binaryFlagNext != 0 {
+		if n.Next, err = r.ref(); err != nil {
+			return n, err
+		}
+	}
+	n.Constraints, err = r.constraints()
+	return n, err
+}
+
+func (r *binaryReader) graph() (valueGraph, error) {
+	var g valueGraph
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(r.r, magic); err != nil || !bytes.Equal(magic, binaryMagic) {
+		return g, errors.New("not a binary encoded value")
+	}
+	version, err := r.uvarint()
+	if err != nil {
+		return g, err
+	}
+	if version == 0 || version > BINARY_VERSION {
+		return g, fmt.Errorf("unsupported binary encoding version %d", version)
+	}
+
+	n, err := r.count()
+	if err != nil {
+		return g, err
+	}
+	g.Roots = make([]int, n)
+	for i := range g.Roots {
+		id, err := r.ref()
+		if err != nil {
+			return g, err
+		}
+		g.Roots[i] = *id
+	}
+
+	if n, err = r.count(); err != nil {
+		return g, err
+	}
+	g.Nodes = make([]graphNode, n)
+	for i := range g.Nodes {
+		if g.Nodes[i], err = r.node(); err != nil {
+			return g, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+	}
+	if r.r.Len() != 0 {
+		return g, errors.New("trailing data after encoded values")
+	}
+	return g, nil
+}
+
+// UnmarshalValuesBinary decodes values encoded by MarshalValuesBinary.
+func UnmarshalValuesBinary(data []byte) ([]BasicValue, error) {
+	r := &binaryReader{r: bytes.NewReader(data)}
+	g, err := r.graph()
+	if err != nil {
+		return nil, err
+	}
+	return decodeGraph(g)
+}
+
+func unmarshalSingleBinary(data []byte) (BasicValue, error) {
+	values, err := UnmarshalValuesBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected single value, got %d", len(values))
+	}
+	return values[0], nil
+}
+
+func (n *Number) UnmarshalBinary(data []byte) error {
+	v, err := unmarshalSingleBinary(data)
+	if err != nil {
+		return err
+	}
+	num, ok := v.(Number)
+	if !ok {
+		return fmt.Errorf("expected Number, got %s", v.TypeName())
+	}
+	*n = num
+	return nil
+}
+
+func (b *Boolean) UnmarshalBinary(data []byte) error {
+	v, err := unmarshalSingleBinary(data)
+	if err != nil {
+		return err
+	}
+	bv, ok := v.(Boolean)
+	if !ok {
+		return fmt.Errorf("expected Boolean, got %s", v.TypeName())
+	}
+	*b = bv
+	return nil
+}
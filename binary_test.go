@@ -0,0 +1,293 @@
+package virtual_types
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+type BinarySuite struct {
+	suite.Suite
+}
+
+func roundTripBinary(assert *assert.Assertions, n Number) Number {
+	data, err := n.MarshalBinary()
+	assert.Nil(err)
+
+	var res Number
+	assert.Nil(res.UnmarshalBinary(data))
+	return res
+}
+
+func (s *BinarySuite) TestNumber() {
+	assert := assert.New(s.T())
+
+	for _, n := range []Number{
+		NewNumberConst(1.5),
+		NewNumberConst(math.Inf(-1)),
+		NewNumberSegment(0, 10),
+		NewNumberRange(NewNRange(math.Inf(-1), 0, true, false)),
+		NewNumber(),
+		NewNumberSegment(0, 10).OrNaN(),
+		NewNumberConst(1).OrNaN(),
+	} {
+		res := roundTripBinary(assert, n)
+		assert.Equal(n.String(), res.String())
+		assert.True(n.IsSame(res), n.String())
+	}
+
+	nan := roundTripBinary(assert, NewNumberConst(math.NaN()))
+	assert.True(nan.IsNaN().IsTrue())
+
+	i, err := NewIntegerRange(NewNRange(0, 10, false, true))
+	assert.Nil(err)
+	res := roundTripBinary(assert, i)
+	assert.True(res.IsInteger().IsTrue())
+	assert.Equal("[1, 10] int", res.String())
+
+	chain := NewNumberSegment(0, 1)
+	chain.p.next = NewNumberRange(NewNRange(5, 6, true, false)).p
+	res = roundTripBinary(assert, chain)
+	assert.Equal("[0, 1] | [5, 6)", res.String())
+
+	// small integers are compact
+	data, err := NewNumberConst(2).MarshalBinary()
+	assert.Nil(err)
+	assert.True(len(data) < 16, len(data))
+}
+
+func (s *BinarySuite) TestSharedSubjects() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+	lt := abs.Less(NewNumberSegment(0, 20))
+
+	data, err := MarshalValuesBinary(x, abs, lt)
+	assert.Nil(err)
+	values, err := UnmarshalValuesBinary(data)
+	assert.Nil(err)
+	assert.Len(values, 3)
+
+	x2, abs2, lt2 := values[0].(Number), values[1].(Number), values[2].(Boolean)
+	subject := abs2.Constraints()[0].(NumberGreaterEqual).Subject()
+	assert.True(subject == x2)
+	assert.True(abs2.GreaterEqual(x2).IsTrue())
+
+	cmp := lt2.Constraints()[0].(BooleanNumberCompare)
+	lhs, _ := cmp.Operands()
+	assert.True(lhs == abs2)
+	assert.Equal(NRelationLess, cmp.Relation())
+
+	y := NewNumberSegment(0, 5)
+	y.p.constraints = []NumberConstraint{NewNumberNotEqual(x)}
+	data, err = MarshalValuesBinary(x, y)
+	assert.Nil(err)
+	values, err = UnmarshalValuesBinary(data)
+	assert.Nil(err)
+	x2, y2 := values[0].(Number), values[1].(Number)
+	assert.True(y2.Constraints()[0].(NumberNotEqual).Subject() == x2)
+	assert.True(y2.Equal(x2).IsFalse())
+}
+
+func (s *BinarySuite) TestSelect() {
+	assert := assert.New(s.T())
+
+	cond := NewBoolean()
+	sel := Select(cond, NewNumberSegment(0, 5), NewNumberSegment(3, 8))
+	data, err := MarshalValuesBinary(sel, cond)
+	assert.Nil(err)
+	values, err := UnmarshalValuesBinary(data)
+	assert.Nil(err)
+
+	sel2, cond2 := values[0].(Number), values[1].(Boolean)
+	assert.Equal("[0, 8]", sel2.String())
+	c := sel2.Constraints()[0].(NumberSelect)
+	cond3, then, els := c.Operands()
+	assert.True(cond3 == cond2)
+	assert.Equal("[0, 5]", then.String())
+	assert.Equal("[3, 8]", els.String())
+
+	json, err := MarshalValuesJSON(sel)
+	assert.Nil(err)
+	values, err = UnmarshalValuesJSON(json)
+	assert.Nil(err)
+	_, ok := values[0].(Number).Constraints()[0].(NumberSelect)
+	assert.True(ok)
+
+	var buf bytes.Buffer
+	assert.Nil(WriteDOT(&buf, sel))
+	assert.Contains(buf.String(), `[label="select cond"]`)
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("x", sel))
+	assert.Contains(e.String(), "(ite ")
+}
+
+func (s *BinarySuite) TestCombinators() {
+	assert := assert.New(s.T())
+
+	x, y := NewNumberSegment(0, 5), NewNumberSegment(3, 8)
+	n := NewNumberSegment(0, 10)
+	n.p.constraints = []NumberConstraint{
+		NewNumberAnd(NewNumberGreaterEqual(x), NewNumberNot(NewNumberLess(y))),
+	}
+	a := NewBoolean()
+	b := NewBoolean()
+	b.p.constraints = []BooleanConstraint{
+		NewBooleanAnd(NewBooleanNot(NewBooleanEqual(a)), BooleanDummyConstraint{}),
+	}
+
+	data, err := MarshalValuesBinary(n, x, y, b, a)
+	assert.Nil(err)
+	values, err := UnmarshalValuesBinary(data)
+	assert.Nil(err)
+	n2, x2 := values[0].(Number), values[1].(Number)
+	and := n2.Constraints()[0].(NumberAnd)
+	assert.True(and.Operands()[0].(NumberGreaterEqual).Subject() == x2)
+	not := and.Operands()[1].(NumberNot)
+	assert.Equal("NumberLess", not.Operand().Name())
+
+	json, err := MarshalValuesJSON(n, b)
+	assert.Nil(err)
+	values, err = UnmarshalValuesJSON(json)
+	assert.Nil(err)
+	band := values[1].(Boolean).p.constraints[0].(BooleanAnd)
+	assert.Equal("BooleanNot", band.Operands()[0].Name())
+
+	var buf bytes.Buffer
+	assert.Nil(WriteDOT(&buf, n))
+	assert.Contains(buf.String(), `[label="NumberNot", arrowhead=none]`)
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("n", n))
+	assert.Nil(e.DeclareBoolean("b", b))
+	assert.Contains(e.String(), "(not (< n ")
+	assert.Contains(e.String(), "(not (= b ")
+
+	// malformed combinators
+	for _, data := range []string{
+		`{"roots": [0], "nodes": [{"type": "Boolean", "val": "unknown", "constraints": [{"kind": "BooleanNot"}]}]}`,
+		`{"roots": [0], "nodes": [{"type": "Boolean", "val": "unknown", "constraints": [{"kind": "BooleanAnd", "variants": [{"kind": "BooleanDummyConstraint"}]}]}]}`,
+	} {
+		_, err := UnmarshalValuesJSON([]byte(data))
+		assert.NotNil(err, data)
+	}
+}
+
+func (s *BinarySuite) TestBoolean() {
+	assert := assert.New(s.T())
+
+	b := NewBoolean()
+	data, err := MarshalValuesBinary(b, b.Not(), b.And(NewBoolean()), b.Or(NewBoolean()))
+	assert.Nil(err)
+	values, err := UnmarshalValuesBinary(data)
+	assert.Nil(err)
+
+	b2, nb2, and2, or2 := values[0].(Boolean), values[1].(Boolean), values[2].(Boolean), values[3].(Boolean)
+	assert.True(b2.IsUnknown())
+	assert.True(b2.Equal(nb2).IsFalse())
+	assert.Len(and2.Constraints(), 2)
+	assert.Len(or2.Constraints(), 2)
+
+	for _, v := range []BValue{BFalse, BTrue} {
+		data, err := NewBooleanConst(v, nil).MarshalBinary()
+		assert.Nil(err)
+		var res Boolean
+		assert.Nil(res.UnmarshalBinary(data))
+		assert.Equal(v, res.p.val)
+	}
+}
+
+func (s *BinarySuite) TestErrors() {
+	assert := assert.New(s.T())
+
+	data, err := MarshalValuesBinary(NewNumberSegment(0, 1).Abs())
+	assert.Nil(err)
+
+	var n Number
+	for i := 0; i < len(data); i++ {
+		assert.NotNil(n.UnmarshalBinary(data[:i]), i)
+	}
+	assert.NotNil(n.UnmarshalBinary(append(append([]byte{}, data...), 0)))
+
+	for _, data := range [][]byte{
+		[]byte("XYZ\x01\x01\x00\x01\x01\x00\x00\x00"),
+		// unsupported versions
+		[]byte("VTB\x00\x01\x00\x01\x01\x00\x00\x00"),
+		[]byte("VTB\x02\x01\x00\x01\x01\x00\x00\x00"),
+		// root out of range
+		[]byte("VTB\x01\x01\x01\x01\x01\x00\x00\x00"),
+		// unknown node tag
+		[]byte("VTB\x01\x01\x00\x01\x07\x00\x00\x00"),
+		// unknown constraint code
+		[]byte("VTB\x01\x01\x00\x01\x01\x00\x00\x01\x63\x00"),
+		// huge count
+		[]byte("VTB\x01\xff\xff\xff\xff\x0f"),
+	} {
+		assert.NotNil(n.UnmarshalBinary(data), data)
+	}
+
+	var b Boolean
+	assert.NotNil(b.UnmarshalBinary(data))
+
+	_, err = Number{}.MarshalBinary()
+	assert.NotNil(err)
+
+	// kinds without code are not written as zero
+	w := &binaryWriter{}
+	assert.NotNil(w.node(graphNode{Type: GRAPH_TYPE_BOOLEAN, Val: "unknown", Constraints: []graphConstraint{{Kind: "BooleanXor"}}}))
+}
+
+// golden files pin the format: they must stay readable by later versions
+func (s *BinarySuite) TestGolden() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+	i, err := NewIntegerRange(NewNRange(0, 10, false, true))
+	assert.Nil(err)
+	b := NewBoolean()
+
+	for _, c := range []struct {
+		name   string
+		values []BasicValue
+		strs   []string
+	}{
+		{"const", []BasicValue{NewNumberConst(-2.5)}, []string{"-2.5"}},
+		{"integer", []BasicValue{i}, []string{"[1, 10] int"}},
+		{"infinite", []BasicValue{NewNumber()}, []string{"[-inf, inf]"}},
+		{"constraints", []BasicValue{x, abs, abs.Less(NewNumberSegment(0, 20))}, []string{"[-10, 10]", "[0, 10]", "unknown"}},
+		{"boolean", []BasicValue{b, b.Not(), b.And(NewBoolean())}, []string{"unknown", "unknown", "unknown"}},
+	} {
+		data, err := MarshalValuesBinary(c.values...)
+		assert.Nil(err)
+
+		path := filepath.Join("testdata", "binary_"+c.name+".golden")
+		if *updateGolden {
+			assert.Nil(ioutil.WriteFile(path, data, 0644))
+		}
+
+		golden, err := ioutil.ReadFile(path)
+		assert.Nil(err)
+		assert.True(bytes.Equal(golden, data), c.name)
+
+		values, err := UnmarshalValuesBinary(golden)
+		assert.Nil(err)
+		assert.Len(values, len(c.strs))
+		for i, v := range values {
+			assert.Equal(c.strs[i], v.(interface{ String() string }).String(), c.name)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	suite.Run(t, new(BinarySuite))
+}
@@ -10,36 +10,57 @@ const (
 
 type BooleanPrivate struct {
 	val         BValue
-	constraints []Constraint
+	constraints []BooleanConstraint
+	// why val is known, see Explain
+	reasons []Reason
+	// cached constraintDepth, see ConstraintBudget
+	depth int
+}
+
+func bvalueOf(v bool) BValue {
+	if v {
+		return BTrue
+	}
+	return BFalse
 }
 
 func (p *BooleanPrivate) equal(o *BooleanPrivate) *BooleanPrivate {
+	return p.equalWalk(o, nil)
+}
+
+func (p *BooleanPrivate) equalWalk(o *BooleanPrivate, w *booleanWalk) *BooleanPrivate {
 	if p == o {
-		return &BooleanPrivate{val: BTrue}
+		return _true.p
 	}
 
 	if (p.val == BFalse || p.val == BTrue) && (o.val == BFalse || o.val == BTrue) {
 		if p.val == o.val {
-			return &BooleanPrivate{val: BTrue}
+			return derived(&BooleanPrivate{val: BTrue}, p, o)
 		}
-		return &BooleanPrivate{val: BFalse}
+		return derived(&BooleanPrivate{val: BFalse}, p, o)
+	}
+
+	if len(p.constraints) == 0 && len(o.constraints) == 0 {
+		return booleanConst(BUnknown).p
+	}
+	if w == nil {
+		w = newBooleanWalk()
+	}
+	if val, ok := w.enter(p, o); !ok {
+		return booleanConst(val).p
 	}
 
 	res := BUnknown
 	if p.val == BUnknown {
 		var err error
-		res, err = checkConstraintsBoolean(p, o, constraintBooleanEqualAllVisitor)
+		res, err = checkConstraintsBoolean(p, o, w, constraintBooleanEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 	}
 
-	if res == BFalse {
-		return &BooleanPrivate{val: BFalse}
-	}
-
-	if o.val == BUnknown {
-		new_res, err := checkConstraintsBoolean(o, p, constraintBooleanEqualAllVisitor)
+	if res != BFalse && o.val == BUnknown {
+		new_res, err := checkConstraintsBoolean(o, p, w, constraintBooleanEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
@@ -49,7 +70,8 @@ func (p *BooleanPrivate) equal(o *BooleanPrivate) *BooleanPrivate {
 		}
 	}
 
-	return &BooleanPrivate{val: res}
+	w.leave(p, o, res)
+	return booleanConst(res).p
 }
 
 func (p *BooleanPrivate) not() *BooleanPrivate {
@@ -68,18 +90,19 @@ func (p *BooleanPrivate) not() *BooleanPrivate {
 		panic("Invalid BValue")
 	}
 
-	return &BooleanPrivate{
-		val:         res,
-		constraints: []Constraint{BooleanNotEqual{subject: p}},
-	}
+	not := &BooleanPrivate{val: res}
+	not.setConstraints([]BooleanConstraint{BooleanNotEqual{subject: p}})
+	return derived(not, p)
 }
 
-type constraintFuncBoolean func(c Constraint, o *BooleanPrivate) (BValue, error)
+type constraintFuncBoolean func(c BooleanConstraint, o *BooleanPrivate, w *booleanWalk) (BValue, error)
 
-func checkConstraintsBoolean(p, o *BooleanPrivate, f constraintFuncBoolean) (BValue, error) {
+// checkConstraintsBoolean visits constraints of p, w may be nil to start
+// new walk on demand
+func checkConstraintsBoolean(p, o *BooleanPrivate, w *booleanWalk, f constraintFuncBoolean) (BValue, error) {
 	res := BUnknown
 	for _, c := range p.constraints {
-		r, err := f(c, o)
+		r, err := f(c, o, w)
 		if err != nil || r == BFalse {
 			return r, err
 		}
@@ -98,6 +121,29 @@ func (b Boolean) TypeName() string {
 	return "Boolean"
 }
 
+func (v BValue) String() string {
+	switch v {
+	case BFalse:
+		return "false"
+	case BTrue:
+		return "true"
+	case BUnknown:
+		return "unknown"
+	}
+	return "invalid"
+}
+
+func (b Boolean) String() string {
+	if b.p == nil {
+		return "invalid"
+	}
+	return b.p.val.String()
+}
+
+func (b Boolean) Constraints() []BooleanConstraint {
+	return b.p.constraints
+}
+
 func (b Boolean) IsValid() bool {
 	return b.p != nil
 }
@@ -122,7 +168,15 @@ func (b Boolean) IsSame(o Boolean) bool {
 	return b.p.val == o.p.val
 }
 
-func constraintBooleanEqualAllVisitor(c Constraint, o *BooleanPrivate) (BValue, error) {
+func constraintBooleanEqualAllVisitor(c BooleanConstraint, o *BooleanPrivate, w *booleanWalk) (BValue, error) {
+	switch c := c.(type) {
+	case walkConstraint:
+		return c.equalWalk(o, w)
+	case BooleanOr:
+		return c.any(func(v BooleanConstraint) (BValue, error) {
+			return constraintBooleanEqualAllVisitor(v, o, w)
+		})
+	}
 	return c.Equal(o)
 }
 
@@ -130,6 +184,17 @@ func (b Boolean) Equal(o Boolean) Boolean {
 	return Boolean{p: b.p.equal(o.p)}
 }
 
+func (b Boolean) ToBoolean() Boolean {
+	return b
+}
+
+func (b Boolean) Join(o Boolean) Boolean {
+	if b.p == o.p || (b.IsConstant() && b.IsSame(o)) {
+		return b
+	}
+	return NewBoolean()
+}
+
 func (b Boolean) Not() Boolean {
 	return Boolean{p: b.p.not()}
 }
@@ -138,12 +203,12 @@ func (b Boolean) And(o Boolean) Boolean {
 	if b.p.val == BFalse {
 		return b
 	} else if b.p.val == BUnknown {
-		res, err := checkConstraintsBoolean(b.p, o.p, constraintBooleanEqualAllVisitor)
+		res, err := checkConstraintsBoolean(b.p, o.p, nil, constraintBooleanEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if res == BFalse {
-			return NewBooleanConst(BFalse, []Constraint{
+			return NewBooleanConst(BFalse, []BooleanConstraint{
 				NewBooleanOr(
 					NewBooleanEqual(b),
 					NewBooleanEqual(o),
@@ -154,39 +219,42 @@ func (b Boolean) And(o Boolean) Boolean {
 
 	if o.p.val == BFalse {
 		if b.p.val == BUnknown {
-			return NewBooleanConst(BFalse, []Constraint{
+			and := NewBooleanConst(BFalse, []BooleanConstraint{
 				NewBooleanOr(
 					NewBooleanEqual(b),
 					NewBooleanEqual(o),
 				),
 			})
+			derived(and.p, o.p)
+			return and
 		}
 	} else if o.p.val == BUnknown {
-		res, err := checkConstraintsBoolean(o.p, b.p, constraintBooleanEqualAllVisitor)
+		res, err := checkConstraintsBoolean(o.p, b.p, nil, constraintBooleanEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if res == BFalse {
 			if b.p.val == BUnknown {
-				return NewBooleanConst(BFalse, []Constraint{
+				return NewBooleanConst(BFalse, []BooleanConstraint{
 					NewBooleanOr(
 						NewBooleanEqual(b),
 						NewBooleanEqual(o),
 					),
 				})
 			}
-			return NewBooleanConst(BFalse, []Constraint{
+			return NewBooleanConst(BFalse, []BooleanConstraint{
 				NewBooleanEqual(o),
 			})
 		}
 	}
 
 	if b.p.val == BUnknown {
-		return NewBooleanConst(BUnknown, []Constraint{
+		return NewBooleanConst(BUnknown, []BooleanConstraint{
 			NewBooleanOr(
 				NewBooleanEqual(b),
 				NewBooleanEqual(o),
 			),
+			NewBooleanConjunction(b, o),
 		})
 	}
 
@@ -205,19 +273,22 @@ func (b Boolean) Or(o Boolean) Boolean {
 		res = BTrue
 	}
 
-	return NewBooleanConst(res, []Constraint{
+	or := NewBooleanConst(res, []BooleanConstraint{
 		NewBooleanOr(
 			NewBooleanEqual(b),
 			NewBooleanEqual(o),
 		),
+		NewBooleanDisjunction(b, o),
 	})
+	derived(or.p, o.p)
+	return or
 }
 
 func NewBoolean() Boolean {
 	return NewBooleanConst(BUnknown, nil)
 }
 
-func NewBooleanConst(v BValue, constraints []Constraint) Boolean {
+func NewBooleanConst(v BValue, constraints []BooleanConstraint) Boolean {
 	switch v {
 	case BFalse:
 	case BTrue:
@@ -227,10 +298,7 @@ func NewBooleanConst(v BValue, constraints []Constraint) Boolean {
 		panic("Invalid BValue")
 	}
 
-	return Boolean{
-		p: &BooleanPrivate{
-			val:         v,
-			constraints: constraints,
-		},
-	}
+	p := &BooleanPrivate{val: v}
+	p.setConstraints(constraints)
+	return Boolean{p: p}
 }
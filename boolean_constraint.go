@@ -1,81 +1,29 @@
 package virtual_types
 
-import "fmt"
+// ====== BooleanOr ======
 
-func errApplyInvalidBoolean(action string) error {
-	return fmt.Errorf("could not apply %s constraint to non-Boolean value", action)
-}
+type BooleanOr = Or[*BooleanPrivate, BooleanConstraint]
 
-func errInverseInvalidBoolean(action string) error {
-	return fmt.Errorf("could not inserse %s constraint on non-Boolean value", action)
+func NewBooleanOr(variants ...BooleanConstraint) BooleanOr {
+	return NewOr[*BooleanPrivate](variants...)
 }
 
-// ====== BooleanOr ======
+// ====== BooleanAnd ======
 
-type BooleanOr struct {
-	variants []Constraint
-}
+type BooleanAnd = And[*BooleanPrivate, BooleanConstraint]
 
-func NewBooleanOr(variants ...Constraint) BooleanOr {
-	variantsLen := len(variants)
-	if variantsLen < 2 {
-		panic("expected atleast 2 variants for BooleanOr construction")
-	}
-	variantsArr := make([]Constraint, variantsLen)
-	for i, v := range variants {
-		variantsArr[i] = v
-	}
-	return BooleanOr{variants: variants}
+func NewBooleanAnd(operands ...BooleanConstraint) BooleanAnd {
+	return NewAnd[*BooleanPrivate](operands...)
 }
 
-func (c BooleanOr) Name() string {
-	return "BooleanOr"
-}
+// ====== BooleanNot ======
 
-func (c BooleanOr) Equal(object interface{}) (BValue, error) {
-	res := BUnknown
-	var err error
-	for _, v := range c.variants {
-		res, err = v.Equal(object)
-		if err != nil || res == BTrue {
-			return res, err
-		}
-	}
-	return res, err
-}
+type BooleanNot = Not[*BooleanPrivate, BooleanConstraint]
 
-func (c BooleanOr) NotEqual(object interface{}) (BValue, error) {
-	res := BUnknown
-	var err error
-	for _, v := range c.variants {
-		res, err = v.NotEqual(object)
-		if err != nil || res == BTrue {
-			return res, err
-		}
-	}
-	return res, err
+func NewBooleanNot(operand BooleanConstraint) BooleanNot {
+	return NewNot[*BooleanPrivate](operand)
 }
 
-func (c BooleanOr) Inverse(subject interface{}) (Constraint, error) {
-	variants := make([]Constraint, len(c.variants))
-	for i, v := range c.variants {
-		res, err := v.Inverse(subject)
-		if err != nil {
-			return nil, err
-		}
-		variants[i] = res
-	}
-	return NewBooleanOr(variants...), nil
-}
-
-/*func (c BooleanOr) Unbox() []interface{} {
-	var res []interface{}
-	for _, c := range c.variants {
-		res = append(res, c.Unbox())
-	}
-	return res
-}*/
-
 // ====== BooleanEqual ======
 
 type BooleanEqual struct {
@@ -90,41 +38,40 @@ func (c BooleanEqual) Name() string {
 	return "BooleanEqual"
 }
 
-func (c BooleanEqual) Equal(object interface{}) (BValue, error) {
-	if obj, ok := object.(*BooleanPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		} else if c.subject.val == BUnknown || obj.val == BUnknown {
-			p := c.subject.equal(obj)
-			return p.val, nil
-		} else if c.subject.val == obj.val {
-			return BTrue, nil
-		}
-		return BFalse, nil
-	}
-	return -1, errApplyInvalidBoolean("BooleanEqual")
-}
-
-func (c BooleanEqual) NotEqual(object interface{}) (BValue, error) {
-	if obj, ok := object.(*BooleanPrivate); ok {
-		if c.subject == obj {
-			return BFalse, nil
-		} else if c.subject.val == BUnknown || obj.val == BUnknown {
-			p := c.subject.equal(obj).not()
-			return p.val, nil
-		} else if c.subject.val == obj.val {
-			return BFalse, nil
-		}
+func (c BooleanEqual) Subject() Boolean {
+	return Boolean{p: c.subject}
+}
+
+func (c BooleanEqual) Equal(object *BooleanPrivate) (BValue, error) {
+	return c.equalWalk(object, nil)
+}
+
+func (c BooleanEqual) equalWalk(obj *BooleanPrivate, w *booleanWalk) (BValue, error) {
+	if c.subject == obj {
+		return BTrue, nil
+	} else if c.subject.val == BUnknown || obj.val == BUnknown {
+		p := c.subject.equalWalk(obj, w)
+		return p.val, nil
+	} else if c.subject.val == obj.val {
 		return BTrue, nil
 	}
-	return -1, errApplyInvalidBoolean("BooleanEqual")
+	return BFalse, nil
 }
 
-func (c BooleanEqual) Inverse(subject interface{}) (Constraint, error) {
-	if subj, ok := subject.(*BooleanPrivate); ok {
-		return BooleanEqual{subject: subj}, nil
+func (c BooleanEqual) NotEqual(object *BooleanPrivate) (BValue, error) {
+	if c.subject == object {
+		return BFalse, nil
+	} else if c.subject.val == BUnknown || object.val == BUnknown {
+		p := c.subject.equal(object).not()
+		return p.val, nil
+	} else if c.subject.val == object.val {
+		return BFalse, nil
 	}
-	return nil, errInverseInvalidBoolean("BooleanEqual")
+	return BTrue, nil
+}
+
+func (c BooleanEqual) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return BooleanEqual{subject: subject}, nil
 }
 
 func (c BooleanEqual) Unbox() []interface{} {
@@ -145,41 +92,39 @@ func (c BooleanNotEqual) Name() string {
 	return "BooleanNotEqual"
 }
 
-func (c BooleanNotEqual) Equal(object interface{}) (BValue, error) {
-	if obj, ok := object.(*BooleanPrivate); ok {
-		if c.subject == obj {
-			return BFalse, nil
-		} else if c.subject.val == BUnknown || obj.val == BUnknown {
-			p := c.subject.equal(obj).not()
-			return p.val, nil
-		} else if c.subject.val == obj.val {
-			return BFalse, nil
-		}
-		return BTrue, nil
-	}
-	return -1, errApplyInvalidBoolean("BooleanNotEqual")
+func (c BooleanNotEqual) Subject() Boolean {
+	return Boolean{p: c.subject}
 }
 
-func (c BooleanNotEqual) NotEqual(object interface{}) (BValue, error) {
-	if obj, ok := object.(*BooleanPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		} else if c.subject.val == BUnknown || obj.val == BUnknown {
-			p := c.subject.equal(obj)
-			return p.val, nil
-		} else if c.subject.val == obj.val {
-			return BTrue, nil
-		}
+func (c BooleanNotEqual) Equal(object *BooleanPrivate) (BValue, error) {
+	return c.equalWalk(object, nil)
+}
+
+func (c BooleanNotEqual) equalWalk(obj *BooleanPrivate, w *booleanWalk) (BValue, error) {
+	if c.subject == obj {
+		return BFalse, nil
+	} else if c.subject.val == BUnknown || obj.val == BUnknown {
+		return notBValue(c.subject.equalWalk(obj, w).val), nil
+	} else if c.subject.val == obj.val {
 		return BFalse, nil
 	}
-	return -1, errApplyInvalidBoolean("BooleanNotEqual")
+	return BTrue, nil
 }
 
-func (c BooleanNotEqual) Inverse(subject interface{}) (Constraint, error) {
-	if subj, ok := subject.(*BooleanPrivate); ok {
-		return BooleanNotEqual{subject: subj}, nil
+func (c BooleanNotEqual) NotEqual(object *BooleanPrivate) (BValue, error) {
+	if c.subject == object {
+		return BTrue, nil
+	} else if c.subject.val == BUnknown || object.val == BUnknown {
+		p := c.subject.equal(object)
+		return p.val, nil
+	} else if c.subject.val == object.val {
+		return BTrue, nil
 	}
-	return nil, errInverseInvalidBoolean("BooleanNotEqual")
+	return BFalse, nil
+}
+
+func (c BooleanNotEqual) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return BooleanNotEqual{subject: subject}, nil
 }
 
 func (c BooleanNotEqual) Unbox() []interface{} {
@@ -194,27 +139,146 @@ func (c BooleanDummyConstraint) Name() string {
 	return "BooleanDummyConstraint"
 }
 
-func (c BooleanDummyConstraint) Equal(object interface{}) (BValue, error) {
-	if _, ok := object.(*BooleanPrivate); ok {
-		return BUnknown, nil
+func (c BooleanDummyConstraint) Equal(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
+}
+
+func (c BooleanDummyConstraint) NotEqual(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
+}
+
+func (c BooleanDummyConstraint) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return c, nil
+}
+
+func (c BooleanDummyConstraint) Unbox() []interface{} {
+	return nil
+}
+
+// ====== BooleanConjunction ======
+
+type BooleanConjunction struct {
+	operands []*BooleanPrivate
+}
+
+func NewBooleanConjunction(operands ...Boolean) BooleanConjunction {
+	operandsArr := make([]*BooleanPrivate, len(operands))
+	for i, o := range operands {
+		operandsArr[i] = o.p
 	}
-	return -1, errApplyInvalidBoolean("BooleanDummyConstraint")
+	return BooleanConjunction{operands: operandsArr}
+}
+
+func (c BooleanConjunction) Name() string {
+	return "BooleanConjunction"
 }
 
-func (c BooleanDummyConstraint) NotEqual(object interface{}) (BValue, error) {
-	if _, ok := object.(*BooleanPrivate); ok {
-		return BUnknown, nil
+func (c BooleanConjunction) Operands() []Boolean {
+	res := make([]Boolean, len(c.operands))
+	for i, o := range c.operands {
+		res[i] = Boolean{p: o}
 	}
-	return -1, errApplyInvalidBoolean("BooleanDummyConstrain")
+	return res
+}
+
+func (c BooleanConjunction) Equal(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
 }
 
-func (c BooleanDummyConstraint) Inverse(subject interface{}) (Constraint, error) {
-	if _, ok := subject.(*BooleanPrivate); ok {
-		return c, nil
+func (c BooleanConjunction) NotEqual(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
+}
+
+func (c BooleanConjunction) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return BooleanDummyConstraint{}, nil
+}
+
+// ====== BooleanDisjunction ======
+
+type BooleanDisjunction struct {
+	operands []*BooleanPrivate
+}
+
+func NewBooleanDisjunction(operands ...Boolean) BooleanDisjunction {
+	operandsArr := make([]*BooleanPrivate, len(operands))
+	for i, o := range operands {
+		operandsArr[i] = o.p
 	}
-	return nil, errInverseInvalidBoolean("BooleanDummyConstraint")
+	return BooleanDisjunction{operands: operandsArr}
 }
 
-func (c BooleanDummyConstraint) Unbox() []interface{} {
-	return nil
+func (c BooleanDisjunction) Name() string {
+	return "BooleanDisjunction"
+}
+
+func (c BooleanDisjunction) Operands() []Boolean {
+	res := make([]Boolean, len(c.operands))
+	for i, o := range c.operands {
+		res[i] = Boolean{p: o}
+	}
+	return res
+}
+
+func (c BooleanDisjunction) Equal(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
+}
+
+func (c BooleanDisjunction) NotEqual(object *BooleanPrivate) (BValue, error) {
+	return BUnknown, nil
+}
+
+func (c BooleanDisjunction) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return BooleanDummyConstraint{}, nil
+}
+
+// ====== BooleanNumberCompare ======
+
+// BooleanNumberCompare binds Boolean to the result of lhs <rel> rhs.
+type BooleanNumberCompare struct {
+	rel NRelation
+	lhs *NumberPrivate
+	rhs *NumberPrivate
+}
+
+func NewBooleanNumberCompare(rel NRelation, lhs, rhs Number) BooleanNumberCompare {
+	return BooleanNumberCompare{rel: rel, lhs: lhs.p, rhs: rhs.p}
+}
+
+func (c BooleanNumberCompare) Name() string {
+	return "BooleanNumberCompare"
+}
+
+func (c BooleanNumberCompare) Relation() NRelation {
+	return c.rel
+}
+
+func (c BooleanNumberCompare) Operands() (Number, Number) {
+	return Number{p: c.lhs}, Number{p: c.rhs}
+}
+
+func (c BooleanNumberCompare) sameAs(o BooleanNumberCompare) bool {
+	if c.rel != o.rel {
+		return false
+	}
+	if c.lhs == o.lhs && c.rhs == o.rhs {
+		return true
+	}
+	return c.rel == NRelationEqual && c.lhs == o.rhs && c.rhs == o.lhs
+}
+
+func (c BooleanNumberCompare) Equal(object *BooleanPrivate) (BValue, error) {
+	for _, oc := range object.constraints {
+		if o, ok := oc.(BooleanNumberCompare); ok && c.sameAs(o) {
+			return BTrue, nil
+		}
+	}
+	return BUnknown, nil
+}
+
+func (c BooleanNumberCompare) NotEqual(object *BooleanPrivate) (BValue, error) {
+	return not(c.Equal(object))
+}
+
+func (c BooleanNumberCompare) Inverse(subject *BooleanPrivate) (BooleanConstraint, error) {
+	return BooleanDummyConstraint{}, nil
 }
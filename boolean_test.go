@@ -21,7 +21,7 @@ func (s *BooleanSuite) SetupTest() {
 	s.False = NewBooleanConst(BFalse, nil)
 	s.Unknown_1 = NewBooleanConst(BUnknown, nil)
 	s.Unknown_2 = NewBoolean()
-	s.Unknown_1_same = NewBooleanConst(BUnknown, []Constraint{
+	s.Unknown_1_same = NewBooleanConst(BUnknown, []BooleanConstraint{
 		NewBooleanEqual(s.Unknown_1),
 	})
 	s.Unknown_1_copy = Boolean{p: s.Unknown_1.p}
@@ -183,8 +183,8 @@ func (s *BooleanSuite) TestEqualTransitive() {
 	{
 		// b ==> a <== c
 		a := NewBoolean()
-		b := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(a)})
-		c := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(a)})
+		b := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(a)})
+		c := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(a)})
 
 		assert.True(a.Equal(b).IsTrue())
 		assert.True(a.Equal(c).IsTrue())
@@ -199,8 +199,8 @@ func (s *BooleanSuite) TestEqualTransitive() {
 	{
 		// b ==> a <!= c
 		a := NewBoolean()
-		b := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(a)})
-		c := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(a)})
+		b := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(a)})
+		c := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(a)})
 
 		assert.True(a.Equal(b).IsTrue())
 		assert.True(b.Equal(a).IsTrue())
@@ -214,8 +214,8 @@ func (s *BooleanSuite) TestEqualTransitive() {
 		// [a <== c], [ b <!= d ]
 		a := NewBoolean()
 		b := NewBoolean()
-		c := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(a)})
-		d := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(b)})
+		c := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(a)})
+		d := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(b)})
 
 		assert.True(b.Equal(c).IsUnknown())
 		assert.True(c.Equal(b).IsUnknown())
@@ -232,7 +232,7 @@ func (s *BooleanSuite) TestEqualTransitive() {
 		eqChain := []Boolean{NewBooleanConst(BUnknown, nil)}
 
 		for i := 1; i < 10; i++ {
-			eqChain = append(eqChain, NewBooleanConst(BUnknown, []Constraint{
+			eqChain = append(eqChain, NewBooleanConst(BUnknown, []BooleanConstraint{
 				NewBooleanEqual(eqChain[i-1]),
 			}))
 		}
@@ -248,9 +248,9 @@ func (s *BooleanSuite) TestEqualTransitive() {
 	{
 		// d !=> b ==> a <!= c
 		a := NewBoolean()
-		b := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(a)})
-		c := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(a)})
-		d := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(b)})
+		b := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(a)})
+		c := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(a)})
+		d := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(b)})
 
 		assert.True(a.Equal(b).IsTrue())
 		assert.True(b.Equal(a).IsTrue())
@@ -271,12 +271,12 @@ func (s *BooleanSuite) TestEqualTransitive() {
 	{
 		// a ==> b ==> c <== d <== e <!= f
 		c := NewBoolean()
-		b := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(c)})
-		a := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(b)})
-		d := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(c)})
-		e := NewBooleanConst(BUnknown, []Constraint{NewBooleanEqual(d)})
+		b := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(c)})
+		a := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(b)})
+		d := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(c)})
+		e := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanEqual(d)})
 
-		f := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(e)})
+		f := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(e)})
 
 		assert.True(a.Equal(e).IsTrue())
 		assert.True(e.Equal(a).IsTrue())
@@ -289,13 +289,25 @@ func (s *BooleanSuite) TestEqualTransitive() {
 func (s *BooleanSuite) TestSameNotEqualTarget() {
 	assert := assert.New(s.T())
 
-	not_eq_1 := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(s.Unknown_1)})
-	not_eq_2 := NewBooleanConst(BUnknown, []Constraint{NewBooleanNotEqual(s.Unknown_1)})
+	not_eq_1 := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(s.Unknown_1)})
+	not_eq_2 := NewBooleanConst(BUnknown, []BooleanConstraint{NewBooleanNotEqual(s.Unknown_1)})
 
 	assert.True(not_eq_1.Equal(not_eq_2).IsTrue())
 	assert.True(not_eq_2.Equal(not_eq_1).IsTrue())
 }
 
+func (s *BooleanSuite) TestJoin() {
+	assert := assert.New(s.T())
+
+	assert.True(s.True.Join(s.True).IsTrue())
+	assert.True(s.True.Join(NewBooleanConst(BTrue, nil)).IsTrue())
+	assert.True(s.False.Join(s.False).IsFalse())
+	assert.True(s.True.Join(s.False).IsUnknown())
+	assert.True(s.True.Join(s.Unknown_1).IsUnknown())
+	assert.True(s.Unknown_1.Join(s.False).IsUnknown())
+	assert.True(s.Unknown_1.Join(s.Unknown_1_copy).p == s.Unknown_1.p)
+}
+
 func TestBoolean(t *testing.T) {
 	suite.Run(t, new(BooleanSuite))
 }
@@ -0,0 +1,209 @@
+package virtual_types
+
+import "sync/atomic"
+
+// ====== Constraint budget ======
+
+// ConstraintBudget bounds constraint graphs held by values. Results of Not,
+// And, Or, Abs and arithmetic refer their operands through constraints, so
+// long computations keep whole history alive and make constraint walks
+// unbounded. Constraints exceeding budget are dropped when value is made:
+// results stay sound, only less precise. Dropped Boolean constraints are
+// summarized by single BooleanDummyConstraint, Number range is summary
+// of its own.
+type ConstraintBudget struct {
+	// MaxDepth limits hops from value through constraint subjects,
+	// 0 means unlimited
+	MaxDepth int
+	// MaxConstraints limits constraints of single value, 0 means unlimited
+	MaxConstraints int
+}
+
+var DefaultConstraintBudget = ConstraintBudget{MaxDepth: 64, MaxConstraints: 16}
+
+var constraintBudget atomic.Value
+
+func init() {
+	constraintBudget.Store(DefaultConstraintBudget)
+}
+
+// SetConstraintBudget sets budget for values made afterwards and returns
+// previous one.
+func SetConstraintBudget(b ConstraintBudget) ConstraintBudget {
+	return constraintBudget.Swap(b).(ConstraintBudget)
+}
+
+func CurrentConstraintBudget() ConstraintBudget {
+	return constraintBudget.Load().(ConstraintBudget)
+}
+
+func (b ConstraintBudget) allows(count, depth int) bool {
+	return (b.MaxConstraints <= 0 || count < b.MaxConstraints) &&
+		(b.MaxDepth <= 0 || depth <= b.MaxDepth)
+}
+
+// constraintDepth returns hops to the farthest value reachable through
+// constraints of p. It is computed when p becomes constraint subject and
+// cached as depth+1, so values nobody refers stay untouched.
+func (p *BooleanPrivate) constraintDepth() int {
+	if len(p.constraints) == 0 {
+		return 0
+	}
+	if p.depth == 0 {
+		// guards cycles
+		p.depth = 1
+		depth := 0
+		for _, c := range p.constraints {
+			depth = maxInt(depth, booleanConstraintDepth(c))
+		}
+		p.depth = depth + 1
+	}
+	return p.depth - 1
+}
+
+func (p *NumberPrivate) constraintDepth() int {
+	if len(p.constraints) == 0 {
+		return 0
+	}
+	if p.depth == 0 {
+		p.depth = 1
+		depth := 0
+		for _, c := range p.constraints {
+			depth = maxInt(depth, numberConstraintDepth(c))
+		}
+		p.depth = depth + 1
+	}
+	return p.depth - 1
+}
+
+func booleanSubjectDepth(p *BooleanPrivate) int {
+	if p == nil {
+		return 0
+	}
+	return p.constraintDepth() + 1
+}
+
+func numberSubjectDepth(p *NumberPrivate) int {
+	if p == nil {
+		return 0
+	}
+	return p.constraintDepth() + 1
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// booleanConstraintDepth returns hops to the farthest value reachable
+// through c, constraints of unknown types are opaque
+func booleanConstraintDepth(c BooleanConstraint) int {
+	depth := 0
+	switch c := c.(type) {
+	case BooleanOr:
+		for _, v := range c.variants {
+			depth = maxInt(depth, booleanConstraintDepth(v))
+		}
+	case BooleanAnd:
+		for _, o := range c.operands {
+			depth = maxInt(depth, booleanConstraintDepth(o))
+		}
+	case BooleanNot:
+		depth = booleanConstraintDepth(c.operand)
+	case BooleanEqual:
+		depth = booleanSubjectDepth(c.subject)
+	case BooleanNotEqual:
+		depth = booleanSubjectDepth(c.subject)
+	case BooleanConjunction:
+		for _, o := range c.operands {
+			depth = maxInt(depth, booleanSubjectDepth(o))
+		}
+	case BooleanDisjunction:
+		for _, o := range c.operands {
+			depth = maxInt(depth, booleanSubjectDepth(o))
+		}
+	case BooleanNumberCompare:
+		depth = maxInt(numberSubjectDepth(c.lhs), numberSubjectDepth(c.rhs))
+	}
+	return depth
+}
+
+func numberConstraintDepth(c NumberConstraint) int {
+	depth := 0
+	switch c := c.(type) {
+	case NumberOr:
+		for _, v := range c.variants {
+			depth = maxInt(depth, numberConstraintDepth(v))
+		}
+	case NumberAnd:
+		for _, o := range c.operands {
+			depth = maxInt(depth, numberConstraintDepth(o))
+		}
+	case NumberNot:
+		depth = numberConstraintDepth(c.operand)
+	case NumberSelect:
+		depth = maxInt(booleanSubjectDepth(c.cond), maxInt(numberSubjectDepth(c.then), numberSubjectDepth(c.els)))
+	case interface{ Subject() Number }:
+		depth = numberSubjectDepth(c.Subject().p)
+	}
+	return depth
+}
+
+// setConstraints attaches constraints fitting current budget
+func (p *BooleanPrivate) setConstraints(constraints []BooleanConstraint) {
+	p.constraints, p.depth = nil, 0
+	if len(constraints) == 0 {
+		return
+	}
+
+	budget := CurrentConstraintBudget()
+	kept := make([]BooleanConstraint, 0, len(constraints))
+	pruned := false
+	for _, c := range constraints {
+		if _, ok := c.(BooleanDummyConstraint); ok {
+			pruned = true
+			continue
+		}
+		depth := booleanConstraintDepth(c)
+		if !budget.allows(len(kept), depth) {
+			pruned = true
+			continue
+		}
+		kept = append(kept, c)
+	}
+	if pruned {
+		kept = append(kept, BooleanDummyConstraint{})
+	}
+	p.constraints = kept
+}
+
+func (p *BooleanPrivate) addConstraint(c BooleanConstraint) {
+	p.setConstraints(append(p.constraints[:len(p.constraints):len(p.constraints)], c))
+}
+
+// setConstraints attaches constraints fitting current budget
+func (p *NumberPrivate) setConstraints(constraints []NumberConstraint) {
+	p.constraints, p.depth = nil, 0
+	if len(constraints) == 0 {
+		return
+	}
+
+	budget := CurrentConstraintBudget()
+	kept := make([]NumberConstraint, 0, len(constraints))
+	for _, c := range constraints {
+		depth := numberConstraintDepth(c)
+		if !budget.allows(len(kept), depth) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	if len(kept) != 0 {
+		p.constraints = kept
+	}
+}
+
+func (p *NumberPrivate) addConstraints(constraints ...NumberConstraint) {
+	p.setConstraints(append(p.constraints[:len(p.constraints):len(p.constraints)], constraints...))
+}
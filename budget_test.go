@@ -0,0 +1,105 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type BudgetSuite struct {
+	suite.Suite
+	prev ConstraintBudget
+}
+
+func (s *BudgetSuite) SetupTest() {
+	s.prev = CurrentConstraintBudget()
+}
+
+func (s *BudgetSuite) TearDownTest() {
+	SetConstraintBudget(s.prev)
+}
+
+func hasDummy(b Boolean) bool {
+	for _, c := range b.Constraints() {
+		if _, ok := c.(BooleanDummyConstraint); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *BudgetSuite) TestBooleanDepth() {
+	assert := assert.New(s.T())
+
+	SetConstraintBudget(ConstraintBudget{MaxDepth: 8})
+
+	b := NewBoolean()
+	for i := 0; i < 1000; i++ {
+		b = b.Not()
+		assert.True(b.p.constraintDepth() <= 8)
+	}
+	assert.True(hasDummy(b) || b.p.constraintDepth() <= 8)
+
+	// nearest operand is still known
+	assert.True(b.Not().Equal(b).IsFalse())
+
+	SetConstraintBudget(ConstraintBudget{})
+	deep := NewBoolean()
+	for i := 0; i < 100; i++ {
+		deep = deep.Not()
+	}
+	assert.Equal(100, deep.p.constraintDepth())
+	assert.False(hasDummy(deep))
+}
+
+func (s *BudgetSuite) TestMaxConstraints() {
+	assert := assert.New(s.T())
+
+	SetConstraintBudget(ConstraintBudget{MaxConstraints: 1})
+
+	and := NewBoolean().And(NewBoolean())
+	assert.Len(and.Constraints(), 2)
+	assert.Equal("BooleanOr", and.Constraints()[0].Name())
+	assert.True(hasDummy(and))
+
+	SetConstraintBudget(DefaultConstraintBudget)
+	and = NewBoolean().And(NewBoolean())
+	assert.Len(and.Constraints(), 2)
+	assert.False(hasDummy(and))
+}
+
+func (s *BudgetSuite) TestNumberDepth() {
+	assert := assert.New(s.T())
+
+	one := NewNumberConst(1)
+	x0 := NewNumberSegment(0, 10)
+	x1, err := x0.Add(one)
+	assert.Nil(err)
+	x2, err := x1.Add(one)
+	assert.Nil(err)
+	assert.True(x2.Greater(x1).IsTrue())
+
+	SetConstraintBudget(ConstraintBudget{MaxDepth: 1})
+
+	y1, err := x0.Add(one)
+	assert.Nil(err)
+	assert.True(y1.Greater(x0).IsTrue())
+	y2, err := y1.Add(one)
+	assert.Nil(err)
+	for _, c := range y2.Constraints() {
+		assert.False(c.(interface{ Subject() Number }).Subject() == y1)
+	}
+	assert.True(y2.Greater(y1).IsUnknown())
+
+	SetConstraintBudget(ConstraintBudget{MaxDepth: 4})
+	y := x0
+	for i := 0; i < 100; i++ {
+		y, err = y.Add(one)
+		assert.Nil(err)
+		assert.True(y.p.constraintDepth() <= 4)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	suite.Run(t, new(BudgetSuite))
+}
@@ -0,0 +1,22 @@
+// Command vtrepl is an interactive shell for exploring virtual values.
+//
+//	> x in [0, 10] int
+//	> y in [5, 20]
+//	> x * 2 + 1
+//	[1, 21] int
+//	> x < y?
+//	unknown
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	r := newREPL(os.Stdout)
+	if err := r.Run(os.Stdin, true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+const helpText = `declarations:
+  x in [0, 10] int     number in range (use ( ) for open edges, inf for infinity)
+  n: int | number      unbounded number
+  b: bool              unknown boolean
+commands:
+  let name = expr      bind result of expr to name
+  expr                 evaluate expr and print it with its constraints
+  expr?                answer true, false or unknown for boolean expr and explain why
+  :assume expr         refine variables assuming boolean expr is true
+  :vars                list variables
+  :reset               forget all variables
+  :help                show this help
+  :quit                exit`
+
+var errQuit = errors.New("quit")
+
+type repl struct {
+	env *vt.Env
+	out io.Writer
+}
+
+func newREPL(out io.Writer) *repl {
+	return &repl{env: vt.NewEnv(), out: out}
+}
+
+func (r *repl) Run(in io.Reader, prompt bool) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			fmt.Fprint(r.out, "> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		err := r.exec(scanner.Text())
+		if err == errQuit {
+			return nil
+		} else if err != nil {
+			fmt.Fprintln(r.out, "error:", err)
+		}
+	}
+}
+
+func isDecl(line string) bool {
+	fields := strings.Fields(strings.Replace(line, ":", " : ", 1))
+	return len(fields) > 1 && (fields[1] == "in" || fields[1] == ":")
+}
+
+func (r *repl) exec(line string) error {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "" || strings.HasPrefix(line, "--"):
+		return nil
+	case line == ":quit" || line == ":q":
+		return errQuit
+	case line == ":help":
+		fmt.Fprintln(r.out, helpText)
+		return nil
+	case line == ":vars":
+		for _, name := range r.env.Names() {
+			v, _ := r.env.Get(name)
+			fmt.Fprintf(r.out, "%s = %s\n", name, v)
+		}
+		return nil
+	case line == ":reset":
+		r.env = vt.NewEnv()
+		return nil
+	case strings.HasPrefix(line, ":assume "):
+		return r.assume(strings.TrimPrefix(line, ":assume "))
+	case strings.HasPrefix(line, ":"):
+		return fmt.Errorf("unknown command %s, see :help", line)
+	case strings.HasPrefix(line, "let "):
+		return r.let(strings.TrimPrefix(line, "let "))
+	case strings.HasSuffix(line, "?"):
+		return r.query(strings.TrimSuffix(line, "?"))
+	case isDecl(line):
+		return vexpr.Declare(r.env, line)
+	}
+
+	v, err := vexpr.Eval(line, r.env)
+	if err != nil {
+		return err
+	}
+	r.print(v)
+	return nil
+}
+
+func (r *repl) let(src string) error {
+	parts := strings.SplitN(src, "=", 2)
+	if len(parts) != 2 {
+		return errors.New("expected let name = expr")
+	}
+	name := strings.TrimSpace(parts[0])
+	if _, err := vexpr.ParseDecl(name + ": bool"); err != nil {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+
+	v, err := vexpr.Eval(parts[1], r.env)
+	if err != nil {
+		return err
+	}
+	r.env.Set(name, v)
+	r.print(v)
+	return nil
+}
+
+func (r *repl) evalBoolean(src string) (vt.Boolean, error) {
+	v, err := vexpr.Eval(src, r.env)
+	if err != nil {
+		return vt.Boolean{}, err
+	}
+	b, ok := v.(vt.Boolean)
+	if !ok {
+		return vt.Boolean{}, fmt.Errorf("expected Boolean expression, got %s", v.TypeName())
+	}
+	return b, nil
+}
+
+func (r *repl) query(src string) error {
+	b, err := r.evalBoolean(src)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, b)
+	for _, reason := range b.Explain() {
+		fmt.Fprintln(r.out, "  "+reason.String())
+	}
+	return nil
+}
+
+func (r *repl) assume(src string) error {
+	b, err := r.evalBoolean(src)
+	if err != nil {
+		return err
+	}
+	env, err := r.env.Assume(b)
+	if err == vt.ERR_UNREACHABLE {
+		return errors.New("assumption is infeasible, variables are left intact")
+	} else if err != nil {
+		return err
+	}
+
+	for _, name := range env.Names() {
+		prev, _ := r.env.Get(name)
+		if curr, _ := env.Get(name); curr != prev {
+			fmt.Fprintf(r.out, "%s = %s\n", name, curr)
+		}
+	}
+	r.env = env
+	return nil
+}
+
+// namer labels subjects of constraints with variable names when possible
+type namer struct {
+	env   *vt.Env
+	names map[vt.BasicValue]string
+}
+
+func (n *namer) name(v vt.BasicValue) string {
+	for _, name := range n.env.Names() {
+		if ev, _ := n.env.Get(name); ev == v {
+			return name
+		}
+	}
+	if name, ok := n.names[v]; ok {
+		return name
+	}
+	name := fmt.Sprintf("_%d", len(n.names)+1)
+	n.names[v] = name
+	return name
+}
+
+func (n *namer) numberConstraint(c vt.NumberConstraint) string {
+	switch c := c.(type) {
+	case interface{ Subject() vt.Number }:
+		return fmt.Sprintf("%s(%s)", c.(vt.NumberConstraint).Name(), n.name(c.Subject()))
+	case vt.NumberSelect:
+		cond, then, els := c.Operands()
+		return fmt.Sprintf("%s(%s ? %s : %s)", c.Name(), n.name(cond), n.name(then), n.name(els))
+	case vt.NumberOr:
+		variants := make([]string, len(c.Variants()))
+		for i, v := range c.Variants() {
+			variants[i] = n.numberConstraint(v)
+		}
+		return strings.Join(variants, " or ")
+	}
+	return c.Name()
+}
+
+var relations = map[vt.NRelation]string{
+	vt.NRelationLess:      "<",
+	vt.NRelationLessEqual: "<=",
+	vt.NRelationEqual:     "==",
+}
+
+func (n *namer) booleanConstraint(c vt.BooleanConstraint) string {
+	switch c := c.(type) {
+	case interface{ Subject() vt.Boolean }:
+		return fmt.Sprintf("%s(%s)", c.(vt.BooleanConstraint).Name(), n.name(c.Subject()))
+	case vt.BooleanOr:
+		variants := make([]string, len(c.Variants()))
+		for i, v := range c.Variants() {
+			variants[i] = n.booleanConstraint(v)
+		}
+		return strings.Join(variants, " or ")
+	case vt.BooleanConjunction:
+		return fmt.Sprintf("%s(%s)", c.Name(), n.operands(c.Operands()))
+	case vt.BooleanDisjunction:
+		return fmt.Sprintf("%s(%s)", c.Name(), n.operands(c.Operands()))
+	case vt.BooleanNumberCompare:
+		lhs, rhs := c.Operands()
+		return fmt.Sprintf("%s(%s %s %s)", c.Name(), n.name(lhs), relations[c.Relation()], n.name(rhs))
+	}
+	return c.Name()
+}
+
+func (n *namer) operands(operands []vt.Boolean) string {
+	names := make([]string, len(operands))
+	for i, o := range operands {
+		names[i] = n.name(o)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *repl) print(v vt.BasicValue) {
+	fmt.Fprintln(r.out, v)
+
+	n := &namer{env: r.env, names: map[vt.BasicValue]string{}}
+	switch v := v.(type) {
+	case vt.Number:
+		for _, c := range v.Constraints() {
+			fmt.Fprintln(r.out, "  "+n.numberConstraint(c))
+		}
+	case vt.Boolean:
+		for _, c := range v.Constraints() {
+			fmt.Fprintln(r.out, "  "+n.booleanConstraint(c))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	r := newREPL(&out)
+
+	script := `
+x in [0, 10] int
+y in [5, 20]
+b: bool
+x * 2 + 1
+x < y?
+x < 11?
+x > 10 and b?
+let z = abs(x - 20)
+z > x?
+:assume x < 3
+x < y?
+x + y?
+:vars
+q in [5, 1]
+:quit
+x
+`
+	assert.Nil(r.Run(strings.NewReader(script), false))
+	assert.Equal(`[1, 21] int
+  NumberGreater(_1)
+unknown
+true
+  [0, 10] int < 11 is true because of ranges
+false
+  10 < [0, 10] int is false because of ranges
+[10, 20]
+  NumberGreater(_1)
+unknown
+x = [0, 2] int
+true
+  [0, 2] int < [5, 20] is true because of ranges
+error: expected Boolean expression, got Number
+b = unknown
+x = [0, 2] int
+y = [5, 20]
+z = [10, 20]
+error: empty range in declaration of q
+`, out.String())
+}
@@ -1,9 +1,344 @@
 package virtual_types
 
-type Constraint interface {
-	Equal(object interface{}) (BValue, error)
-	NotEqual(object interface{}) (BValue, error)
-	Inverse(subject interface{}) (Constraint, error)
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Constraint relates value holding it to other values. T is private part
+// of virtual type constraint is attached to (*BooleanPrivate,
+// *NumberPrivate), query methods answer whether relation between holder
+// and object is implied by constraint.
+type Constraint[T any] interface {
+	Equal(object T) (BValue, error)
+	NotEqual(object T) (BValue, error)
+	Inverse(subject T) (Constraint[T], error)
 	Name() string
 	//Unbox() []interface{}
 }
+
+// OrderedConstraint is Constraint of virtual type with order
+type OrderedConstraint[T any] interface {
+	Constraint[T]
+	Less(object T) (BValue, error)
+	Greater(object T) (BValue, error)
+	LessEqual(object T) (BValue, error)
+	GreaterEqual(object T) (BValue, error)
+}
+
+type BooleanConstraint = Constraint[*BooleanPrivate]
+type NumberConstraint = OrderedConstraint[*NumberPrivate]
+
+func not(val BValue, err error) (BValue, error) {
+	return notBValue(val), err
+}
+
+func or(l, r BValue) BValue {
+	if l == BTrue || r == BFalse {
+		return l
+	}
+	return r
+}
+
+// ====== Registry ======
+
+var constraintTypes sync.Map
+
+func init() {
+	RegisterConstraintType[*BooleanPrivate]("Boolean")
+	RegisterConstraintType[*NumberPrivate]("Number")
+}
+
+// RegisterConstraintType names virtual type T for combinators attached to
+// its values, so Or[T, C] of T named "Table" is "TableOr".
+func RegisterConstraintType[T any](name string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if prev, loaded := constraintTypes.LoadOrStore(t, name); loaded {
+		panic(fmt.Sprintf("constraint type %s already registered as %s", t, prev))
+	}
+}
+
+func constraintTypeName[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if name, ok := constraintTypes.Load(t); ok {
+		return name.(string)
+	}
+	panic(fmt.Sprintf("constraint type %s is not registered", t))
+}
+
+// ====== Or ======
+
+// Or holds when any of its variants holds. C is constraint interface of
+// variants, Or is OrderedConstraint whenever they are.
+type Or[T any, C Constraint[T]] struct {
+	variants []C
+}
+
+func NewOr[T any, C Constraint[T]](variants ...C) Or[T, C] {
+	if len(variants) < 2 {
+		panic("expected atleast 2 variants for " + constraintTypeName[T]() + "Or construction")
+	}
+	return Or[T, C]{variants: append([]C(nil), variants...)}
+}
+
+func (_ Or[T, C]) Name() string {
+	return constraintTypeName[T]() + "Or"
+}
+
+func (c Or[T, C]) Variants() []C {
+	return c.variants
+}
+
+func (c Or[T, C]) any(f query[T]) (BValue, error) {
+	result := BFalse
+	for _, v := range c.variants {
+		res, err := f(v)
+		if err != nil || res == BTrue {
+			return res, err
+		} else if res == BUnknown {
+			result = BUnknown
+		}
+	}
+	return result, nil
+}
+
+func (c Or[T, C]) Equal(object T) (BValue, error) {
+	return c.any(equalQuery(object))
+}
+
+func (c Or[T, C]) NotEqual(object T) (BValue, error) {
+	return c.any(notEqualQuery(object))
+}
+
+func (c Or[T, C]) Less(object T) (BValue, error) {
+	return c.any(lessQuery(object))
+}
+
+func (c Or[T, C]) Greater(object T) (BValue, error) {
+	return c.any(greaterQuery(object))
+}
+
+func (c Or[T, C]) LessEqual(object T) (BValue, error) {
+	lt, err := c.Less(object)
+	if err != nil || lt == BTrue {
+		return lt, err
+	}
+
+	eq, err := c.Equal(object)
+	if err != nil || eq == BTrue {
+		return eq, err
+	}
+
+	return or(lt, eq), nil
+}
+
+func (c Or[T, C]) GreaterEqual(object T) (BValue, error) {
+	gt, err := c.Greater(object)
+	if err != nil || gt == BTrue {
+		return gt, err
+	}
+
+	eq, err := c.Equal(object)
+	if err != nil || eq == BTrue {
+		return eq, err
+	}
+
+	return or(gt, eq), nil
+}
+
+func (c Or[T, C]) Inverse(subject T) (Constraint[T], error) {
+	variants, err := inverseAll[T](c.variants, subject)
+	if err != nil {
+		return nil, err
+	}
+	return NewOr[T](variants...), nil
+}
+
+// ====== And ======
+
+// And holds when all of its operands hold. Operands never contradict each
+// other, so first decided answer is the answer.
+type And[T any, C Constraint[T]] struct {
+	operands []C
+}
+
+func NewAnd[T any, C Constraint[T]](operands ...C) And[T, C] {
+	if len(operands) < 2 {
+		panic("expected atleast 2 operands for " + constraintTypeName[T]() + "And construction")
+	}
+	return And[T, C]{operands: append([]C(nil), operands...)}
+}
+
+func (_ And[T, C]) Name() string {
+	return constraintTypeName[T]() + "And"
+}
+
+func (c And[T, C]) Operands() []C {
+	return c.operands
+}
+
+func (c And[T, C]) first(f query[T]) (BValue, error) {
+	for _, o := range c.operands {
+		res, err := f(o)
+		if err != nil || res != BUnknown {
+			return res, err
+		}
+	}
+	return BUnknown, nil
+}
+
+func (c And[T, C]) Equal(object T) (BValue, error) {
+	return c.first(equalQuery(object))
+}
+
+func (c And[T, C]) NotEqual(object T) (BValue, error) {
+	return c.first(notEqualQuery(object))
+}
+
+func (c And[T, C]) Less(object T) (BValue, error) {
+	return c.first(lessQuery(object))
+}
+
+func (c And[T, C]) Greater(object T) (BValue, error) {
+	return c.first(greaterQuery(object))
+}
+
+func (c And[T, C]) LessEqual(object T) (BValue, error) {
+	return c.first(lessEqualQuery(object))
+}
+
+func (c And[T, C]) GreaterEqual(object T) (BValue, error) {
+	return c.first(greaterEqualQuery(object))
+}
+
+func (c And[T, C]) Inverse(subject T) (Constraint[T], error) {
+	operands, err := inverseAll[T](c.operands, subject)
+	if err != nil {
+		return nil, err
+	}
+	return NewAnd[T](operands...), nil
+}
+
+// ====== Not ======
+
+// Not holds when its operand does not. Only relations operand implies can
+// be refuted, anything else stays unknown.
+type Not[T any, C Constraint[T]] struct {
+	operand C
+}
+
+func NewNot[T any, C Constraint[T]](operand C) Not[T, C] {
+	return Not[T, C]{operand: operand}
+}
+
+func (_ Not[T, C]) Name() string {
+	return constraintTypeName[T]() + "Not"
+}
+
+func (c Not[T, C]) Operand() C {
+	return c.operand
+}
+
+func refute(val BValue, err error) (BValue, error) {
+	if err != nil {
+		return val, err
+	}
+	if val == BTrue {
+		return BFalse, nil
+	}
+	return BUnknown, nil
+}
+
+func (c Not[T, C]) Equal(object T) (BValue, error)    { return refute(c.operand.Equal(object)) }
+func (c Not[T, C]) NotEqual(object T) (BValue, error) { return refute(c.operand.NotEqual(object)) }
+
+func (c Not[T, C]) Less(object T) (BValue, error) {
+	return refute(lessQuery(object)(c.operand))
+}
+
+func (c Not[T, C]) Greater(object T) (BValue, error) {
+	return refute(greaterQuery(object)(c.operand))
+}
+
+func (c Not[T, C]) LessEqual(object T) (BValue, error) {
+	return refute(lessEqualQuery(object)(c.operand))
+}
+
+func (c Not[T, C]) GreaterEqual(object T) (BValue, error) {
+	return refute(greaterEqualQuery(object)(c.operand))
+}
+
+func (c Not[T, C]) Inverse(subject T) (Constraint[T], error) {
+	res, err := c.operand.Inverse(subject)
+	if err != nil {
+		return nil, err
+	}
+	operand, err := asConstraint[T, C](res)
+	if err != nil {
+		return nil, err
+	}
+	return NewNot[T](operand), nil
+}
+
+// ====== Helpers ======
+
+// query asks constraint about relation of its holder to some object
+type query[T any] func(c Constraint[T]) (BValue, error)
+
+func equalQuery[T any](object T) query[T] {
+	return func(c Constraint[T]) (BValue, error) { return c.Equal(object) }
+}
+
+func notEqualQuery[T any](object T) query[T] {
+	return func(c Constraint[T]) (BValue, error) { return c.NotEqual(object) }
+}
+
+// orderedQuery asks f, constraints of unordered types imply no order
+func orderedQuery[T any](f func(c OrderedConstraint[T]) (BValue, error)) query[T] {
+	return func(c Constraint[T]) (BValue, error) {
+		if oc, ok := c.(OrderedConstraint[T]); ok {
+			return f(oc)
+		}
+		return BUnknown, nil
+	}
+}
+
+func lessQuery[T any](object T) query[T] {
+	return orderedQuery(func(c OrderedConstraint[T]) (BValue, error) { return c.Less(object) })
+}
+
+func greaterQuery[T any](object T) query[T] {
+	return orderedQuery(func(c OrderedConstraint[T]) (BValue, error) { return c.Greater(object) })
+}
+
+func lessEqualQuery[T any](object T) query[T] {
+	return orderedQuery(func(c OrderedConstraint[T]) (BValue, error) { return c.LessEqual(object) })
+}
+
+func greaterEqualQuery[T any](object T) query[T] {
+	return orderedQuery(func(c OrderedConstraint[T]) (BValue, error) { return c.GreaterEqual(object) })
+}
+
+func asConstraint[T any, C Constraint[T]](c Constraint[T]) (C, error) {
+	res, ok := c.(C)
+	if !ok {
+		var zero C
+		return zero, fmt.Errorf("%s is not %s constraint", c.Name(), reflect.TypeOf((*C)(nil)).Elem())
+	}
+	return res, nil
+}
+
+func inverseAll[T any, C Constraint[T]](cs []C, subject T) ([]C, error) {
+	res := make([]C, len(cs))
+	for i, c := range cs {
+		inv, err := c.Inverse(subject)
+		if err != nil {
+			return nil, err
+		}
+		if res[i], err = asConstraint[T, C](inv); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,146 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ConstraintSuite struct {
+	suite.Suite
+}
+
+// tagPrivate stands for virtual type defined outside of this file
+type tagPrivate struct {
+	constraints []Constraint[*tagPrivate]
+}
+
+type tagSame struct {
+	subject *tagPrivate
+}
+
+func (_ tagSame) Name() string { return "TagSame" }
+
+func (c tagSame) Equal(object *tagPrivate) (BValue, error) {
+	if c.subject == object {
+		return BTrue, nil
+	}
+	return BUnknown, nil
+}
+
+func (c tagSame) NotEqual(object *tagPrivate) (BValue, error) { return not(c.Equal(object)) }
+
+func (c tagSame) Inverse(subject *tagPrivate) (Constraint[*tagPrivate], error) {
+	return tagSame{subject: subject}, nil
+}
+
+func init() {
+	RegisterConstraintType[*tagPrivate]("Tag")
+}
+
+func (s *ConstraintSuite) TestNames() {
+	assert := assert.New(s.T())
+
+	x, y := NewNumber(), NewNumber()
+	assert.Equal("NumberOr", NewNumberOr(NewNumberLess(x), NewNumberGreater(y)).Name())
+	assert.Equal("NumberAnd", NewAnd[*NumberPrivate, NumberConstraint](NewNumberLess(x), NewNumberGreater(y)).Name())
+	assert.Equal("BooleanOr", NewBooleanOr(BooleanDummyConstraint{}, BooleanDummyConstraint{}).Name())
+	assert.Equal("BooleanNot", NewNot[*BooleanPrivate](BooleanConstraint(BooleanDummyConstraint{})).Name())
+	assert.Equal("TagOr", NewOr[*tagPrivate](tagSame{}, tagSame{}).Name())
+
+	assert.Panics(func() { RegisterConstraintType[*NumberPrivate]("Number") })
+	assert.Panics(func() { NewOr[*float64](Constraint[*float64](nil), nil).Name() })
+	assert.Panics(func() { NewNumberOr(NewNumberLess(x)) })
+}
+
+func (s *ConstraintSuite) TestNumberCombinators() {
+	assert := assert.New(s.T())
+
+	x, y, z := NewNumber(), NewNumber(), NewNumber()
+	lt, gt := NewNumberLess(x), NewNumberGreater(y)
+
+	or := NewNumberOr(lt, gt)
+	res, err := or.Less(x.p)
+	assert.Nil(err)
+	assert.Equal(BTrue, res)
+	res, _ = or.Less(z.p)
+	assert.Equal(BUnknown, res)
+	res, _ = NewNumberOr(lt, NewNumberLess(y)).GreaterEqual(x.p)
+	assert.Equal(BUnknown, res)
+
+	and := NewAnd[*NumberPrivate, NumberConstraint](lt, gt)
+	res, _ = and.GreaterEqual(x.p)
+	assert.Equal(BFalse, res)
+	res, _ = and.Greater(y.p)
+	assert.Equal(BTrue, res)
+	res, _ = and.Equal(z.p)
+	assert.Equal(BUnknown, res)
+
+	not := NewNot[*NumberPrivate](NumberConstraint(lt))
+	res, _ = not.Less(x.p)
+	assert.Equal(BFalse, res)
+	// x <= holder does not follow from !(holder < x)
+	res, _ = not.Greater(x.p)
+	assert.Equal(BUnknown, res)
+	res, _ = not.Equal(z.p)
+	assert.Equal(BUnknown, res)
+
+	// combinators of ordered constraints are ordered
+	var _ NumberConstraint = or
+	var _ NumberConstraint = and
+	var _ NumberConstraint = not
+}
+
+func (s *ConstraintSuite) TestBooleanOr() {
+	assert := assert.New(s.T())
+
+	a, b := NewBoolean(), NewBoolean()
+	or := NewBooleanOr(NewBooleanEqual(a), NewBooleanNotEqual(b))
+	res, err := or.Equal(a.p)
+	assert.Nil(err)
+	assert.Equal(BTrue, res)
+	res, _ = or.Equal(NewBoolean().p)
+	assert.Equal(BUnknown, res)
+
+	inv, err := or.Inverse(b.p)
+	assert.Nil(err)
+	assert.Equal("BooleanOr", inv.Name())
+	assert.Len(inv.(BooleanOr).Variants(), 2)
+
+	// any variant decides true, false needs all variants, regardless of
+	// their order
+	res, _ = NewBooleanOr(NewBooleanEqual(a), NewBooleanEqual(b)).Equal(a.p)
+	assert.Equal(BTrue, res)
+	res, _ = NewBooleanOr(NewBooleanEqual(b), NewBooleanNotEqual(a)).Equal(a.p)
+	assert.Equal(BUnknown, res)
+	res, _ = NewBooleanOr(NewBooleanNotEqual(a), NewBooleanNotEqual(a)).Equal(a.p)
+	assert.Equal(BFalse, res)
+}
+
+func (s *ConstraintSuite) TestNewType() {
+	assert := assert.New(s.T())
+
+	t1, t2, t3 := &tagPrivate{}, &tagPrivate{}, &tagPrivate{}
+	or := NewOr[*tagPrivate](tagSame{subject: t1}, tagSame{subject: t2})
+	t3.constraints = []Constraint[*tagPrivate]{or}
+
+	res, err := t3.constraints[0].Equal(t2)
+	assert.Nil(err)
+	assert.Equal(BTrue, res)
+	res, _ = NewNot[*tagPrivate](or).Equal(t1)
+	assert.Equal(BFalse, res)
+	res, _ = NewAnd[*tagPrivate, Constraint[*tagPrivate]](or, tagSame{subject: t2}).NotEqual(t2)
+	assert.Equal(BFalse, res)
+
+	// tags are unordered
+	res, _ = or.Less(t1)
+	assert.Equal(BUnknown, res)
+
+	inv, err := NewNot[*tagPrivate](or).Inverse(t3)
+	assert.Nil(err)
+	assert.Equal("TagNot", inv.Name())
+}
+
+func TestConstraint(t *testing.T) {
+	suite.Run(t, new(ConstraintSuite))
+}
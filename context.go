@@ -0,0 +1,481 @@
+package virtual_types
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	UNREACHABLE_STR = "unreachable"
+
+	contextMaxRefineRounds = 32
+)
+
+var ERR_UNREACHABLE = errors.New(UNREACHABLE_STR)
+
+type booleanJunction struct {
+	operands []*BooleanPrivate
+	val      BValue
+	neutral  BValue
+}
+
+type numberRelation struct {
+	rel NRelation
+	val BValue
+	lhs *NumberPrivate
+	rhs *NumberPrivate
+}
+
+// Context is a set of path conditions. Values are never modified in place:
+// refined versions are obtained with Context.Number and Context.Boolean.
+type Context struct {
+	numbers   map[*NumberPrivate]Number
+	booleans  map[*BooleanPrivate]BValue
+	visited   map[*NumberPrivate]bool
+	relations []numberRelation
+	pending   []booleanJunction
+}
+
+func NewContext() Context {
+	return Context{
+		numbers:  map[*NumberPrivate]Number{},
+		booleans: map[*BooleanPrivate]BValue{},
+		visited:  map[*NumberPrivate]bool{},
+	}
+}
+
+func Assume(cond Boolean) (Context, error) {
+	return NewContext().Assume(cond)
+}
+
+func (ctx Context) clone() Context {
+	res := Context{
+		numbers:   make(map[*NumberPrivate]Number, len(ctx.numbers)),
+		booleans:  make(map[*BooleanPrivate]BValue, len(ctx.booleans)),
+		visited:   make(map[*NumberPrivate]bool, len(ctx.visited)),
+		relations: make([]numberRelation, len(ctx.relations)),
+		pending:   make([]booleanJunction, len(ctx.pending)),
+	}
+	for k, v := range ctx.numbers {
+		res.numbers[k] = v
+	}
+	for k, v := range ctx.booleans {
+		res.booleans[k] = v
+	}
+	for k, v := range ctx.visited {
+		res.visited[k] = v
+	}
+	copy(res.relations, ctx.relations)
+	copy(res.pending, ctx.pending)
+	return res
+}
+
+// Assume returns new Context where cond is known to be true. ERR_UNREACHABLE
+// is returned when cond contradicts the already assumed conditions.
+func (ctx Context) Assume(cond Boolean) (Context, error) {
+	res := ctx.clone()
+	if err := res.assumeBoolean(cond.p, BTrue); err != nil {
+		return Context{}, err
+	}
+	if err := res.resolvePending(); err != nil {
+		return Context{}, err
+	}
+	if err := res.refineNumbers(); err != nil {
+		return Context{}, err
+	}
+	return res, nil
+}
+
+func (ctx Context) Number(n Number) Number {
+	if res, ok := ctx.numbers[n.p]; ok {
+		return res
+	}
+	if branch := ctx.selected(n.p); branch != nil {
+		return ctx.Number(Number{p: branch})
+	}
+	return n
+}
+
+// selected returns branch of Select result chosen by assumed condition
+func (ctx Context) selected(p *NumberPrivate) *NumberPrivate {
+	for _, c := range p.constraints {
+		if c, ok := c.(NumberSelect); ok {
+			switch ctx.booleanValue(c.cond, 0) {
+			case BTrue:
+				return c.then
+			case BFalse:
+				return c.els
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx Context) Boolean(b Boolean) Boolean {
+	if val := ctx.booleanValue(b.p, 0); val != b.p.val {
+		return NewBooleanConst(val, []BooleanConstraint{NewBooleanEqual(b)})
+	}
+	return b
+}
+
+func notBValue(v BValue) BValue {
+	switch v {
+	case BTrue:
+		return BFalse
+	case BFalse:
+		return BTrue
+	}
+	return v
+}
+
+func (ctx Context) booleanValue(p *BooleanPrivate, depth int) BValue {
+	if val, ok := ctx.booleans[p]; ok {
+		return val
+	}
+	if p.val != BUnknown || depth > contextMaxRefineRounds {
+		return p.val
+	}
+
+	for _, c := range p.constraints {
+		var val BValue
+		switch c := c.(type) {
+		case BooleanEqual:
+			val = ctx.booleanValue(c.subject, depth+1)
+		case BooleanNotEqual:
+			val = notBValue(ctx.booleanValue(c.subject, depth+1))
+		case BooleanConjunction:
+			val = BTrue
+			for _, o := range c.operands {
+				if oVal := ctx.booleanValue(o, depth+1); oVal == BFalse {
+					val = BFalse
+					break
+				} else if oVal == BUnknown {
+					val = BUnknown
+				}
+			}
+		case BooleanDisjunction:
+			val = BFalse
+			for _, o := range c.operands {
+				if oVal := ctx.booleanValue(o, depth+1); oVal == BTrue {
+					val = BTrue
+					break
+				} else if oVal == BUnknown {
+					val = BUnknown
+				}
+			}
+		case BooleanNumberCompare:
+			val = ctx.compare(c.rel, c.lhs, c.rhs)
+		default:
+			continue
+		}
+		if val != BUnknown {
+			return val
+		}
+	}
+	return BUnknown
+}
+
+func (ctx Context) compare(rel NRelation, lhs, rhs *NumberPrivate) BValue {
+	l := ctx.Number(Number{p: lhs})
+	r := ctx.Number(Number{p: rhs})
+	if l.p == lhs && r.p == rhs {
+		// nothing was refined, do not repeat the original comparison
+		return BUnknown
+	}
+
+	switch rel {
+	case NRelationLess:
+		return l.Less(r).p.val
+	case NRelationLessEqual:
+		return l.LessEqual(r).p.val
+	}
+	return l.Equal(r).p.val
+}
+
+func (ctx *Context) assumeBoolean(p *BooleanPrivate, v BValue) error {
+	if val, ok := ctx.booleans[p]; ok {
+		if val != v {
+			return ERR_UNREACHABLE
+		}
+		return nil
+	}
+	if val := ctx.booleanValue(p, 0); val != BUnknown && val != v {
+		return ERR_UNREACHABLE
+	}
+	ctx.booleans[p] = v
+
+	for _, c := range p.constraints {
+		var err error
+		switch c := c.(type) {
+		case BooleanEqual:
+			err = ctx.assumeBoolean(c.subject, v)
+		case BooleanNotEqual:
+			err = ctx.assumeBoolean(c.subject, notBValue(v))
+		case BooleanConjunction:
+			err = ctx.assumeJunction(booleanJunction{operands: c.operands, val: v, neutral: BTrue})
+		case BooleanDisjunction:
+			err = ctx.assumeJunction(booleanJunction{operands: c.operands, val: v, neutral: BFalse})
+		case BooleanNumberCompare:
+			ctx.relations = append(ctx.relations, numberRelation{
+				rel: c.rel,
+				val: v,
+				lhs: c.lhs,
+				rhs: c.rhs,
+			})
+			ctx.collectNumber(c.lhs)
+			ctx.collectNumber(c.rhs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) assumeJunction(j booleanJunction) error {
+	resolved, err := ctx.assumeOperands(j)
+	if err == nil && !resolved {
+		ctx.pending = append(ctx.pending, j)
+	}
+	return err
+}
+
+// assumeOperands handles both conjunction (neutral = BTrue) and
+// disjunction (neutral = BFalse) of operands evaluated to j.val.
+// Junction stays unresolved while more than one operand is undecided.
+func (ctx *Context) assumeOperands(j booleanJunction) (bool, error) {
+	if j.val == j.neutral {
+		for _, o := range j.operands {
+			if err := ctx.assumeBoolean(o, j.val); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	var undecided *BooleanPrivate
+	for _, o := range j.operands {
+		switch ctx.booleanValue(o, 0) {
+		case j.val:
+			return true, nil
+		case BUnknown:
+			if undecided != nil {
+				return false, nil
+			}
+			undecided = o
+		}
+	}
+	if undecided == nil {
+		return false, ERR_UNREACHABLE
+	}
+	return true, ctx.assumeBoolean(undecided, j.val)
+}
+
+func (ctx *Context) resolvePending() error {
+	for changed := true; changed; {
+		changed = false
+		pending := ctx.pending
+		ctx.pending = nil
+		for _, j := range pending {
+			resolved, err := ctx.assumeOperands(j)
+			if err != nil {
+				return err
+			}
+			if resolved {
+				changed = true
+			} else {
+				ctx.pending = append(ctx.pending, j)
+			}
+		}
+	}
+	return nil
+}
+
+func (ctx *Context) collectNumber(p *NumberPrivate) {
+	if ctx.visited[p] {
+		return
+	}
+	ctx.visited[p] = true
+
+	for _, c := range p.constraints {
+		var rel numberRelation
+		var subject *NumberPrivate
+		switch c := c.(type) {
+		case NumberEqual:
+			subject = c.subject
+			rel = numberRelation{rel: NRelationEqual, lhs: p, rhs: subject}
+		case NumberLess:
+			subject = c.subject
+			rel = numberRelation{rel: NRelationLess, lhs: p, rhs: subject}
+		case NumberLessEqual:
+			subject = c.subject
+			rel = numberRelation{rel: NRelationLessEqual, lhs: p, rhs: subject}
+		case NumberGreater:
+			subject = c.subject
+			rel = numberRelation{rel: NRelationLess, lhs: subject, rhs: p}
+		case NumberGreaterEqual:
+			subject = c.subject
+			rel = numberRelation{rel: NRelationLessEqual, lhs: subject, rhs: p}
+		case NumberSelect:
+			if subject = ctx.selected(p); subject == nil {
+				continue
+			}
+			rel = numberRelation{rel: NRelationEqual, lhs: p, rhs: subject}
+		default:
+			continue
+		}
+		rel.val = BTrue
+		ctx.relations = append(ctx.relations, rel)
+		ctx.collectNumber(subject)
+	}
+}
+
+func (ctx Context) numberRange(p *NumberPrivate) *NRange {
+	n := ctx.Number(Number{p: p})
+	if n.p.next != nil {
+		return nil
+	}
+	if n.p.valRange == nil {
+		if math.IsNaN(n.p.val) {
+			return nil
+		}
+		return newRangeSegment(n.p.val, n.p.val)
+	}
+	return n.p.valRange
+}
+
+func (ctx *Context) setRange(p *NumberPrivate, r *NRange) (bool, error) {
+	if r == nil {
+		return false, ERR_UNREACHABLE
+	}
+
+	curr := ctx.Number(Number{p: p})
+	prev := ctx.numberRange(p)
+	if prev.IsSame(r) && !curr.p.nan {
+		return false, nil
+	}
+
+	refined := curr.p.Clone()
+	refined.valRange = r
+	// relations refining ranges hold for numbers only, NaN fails them
+	refined.nan = false
+	refined.constraints = p.constraints
+
+	n, err := Number{p: refined}.RangeAdjust()
+	if err != nil {
+		return false, ERR_UNREACHABLE
+	}
+	ctx.numbers[p] = n
+	return true, nil
+}
+
+func lessRange(r *NRange, including bool) (upper, lower *NRange) {
+	upper = &NRange{
+		lVal: math.Inf(-1), lIncluding: true,
+		rVal: r.rVal, rIncluding: including && r.rIncluding,
+	}
+	lower = &NRange{
+		lVal: r.lVal, lIncluding: including && r.lIncluding,
+		rVal: math.Inf(1), rIncluding: true,
+	}
+	return upper, lower
+}
+
+func (ctx *Context) applyRelation(rel numberRelation) (bool, error) {
+	lhs, rhs := rel.lhs, rel.rhs
+	lRange, rRange := ctx.numberRange(lhs), ctx.numberRange(rhs)
+	if lRange == nil || rRange == nil {
+		// chained and NaN numbers are not refined
+		return false, nil
+	}
+	if rel.val == BFalse && (ctx.Number(Number{p: lhs}).p.nan || ctx.Number(Number{p: rhs}).p.nan) {
+		// false relation may be due to NaN, so ranges are not refined
+		return false, nil
+	}
+
+	if rel.val == BFalse {
+		switch rel.rel {
+		case NRelationLess:
+			// !(l < r) => r <= l
+			rel = numberRelation{rel: NRelationLessEqual, val: BTrue, lhs: rhs, rhs: lhs}
+		case NRelationLessEqual:
+			// !(l <= r) => r < l
+			rel = numberRelation{rel: NRelationLess, val: BTrue, lhs: rhs, rhs: lhs}
+		case NRelationEqual:
+			return ctx.applyNotEqual(lhs, rhs, lRange, rRange)
+		}
+		lhs, rhs = rel.lhs, rel.rhs
+		lRange, rRange = rRange, lRange
+	}
+
+	var lNew, rNew *NRange
+	switch rel.rel {
+	case NRelationEqual:
+		lNew = lRange.Intersect(rRange)
+		rNew = lNew
+	default:
+		including := rel.rel == NRelationLessEqual
+		upper, _ := lessRange(rRange, including)
+		_, lower := lessRange(lRange, including)
+		if lNew = lRange.Intersect(upper); lNew != nil {
+			rNew = rRange.Intersect(lower)
+		}
+	}
+	if lNew == nil || rNew == nil {
+		return false, ERR_UNREACHABLE
+	}
+
+	lChanged, err := ctx.setRange(lhs, lNew)
+	if err != nil {
+		return false, err
+	}
+	rChanged, err := ctx.setRange(rhs, rNew)
+	return lChanged || rChanged, err
+}
+
+func (ctx *Context) applyNotEqual(lhs, rhs *NumberPrivate, lRange, rRange *NRange) (bool, error) {
+	if lRange.IsConstant() && rRange.IsConstant() {
+		if lRange.lVal == rRange.lVal {
+			return false, ERR_UNREACHABLE
+		}
+		return false, nil
+	}
+
+	if rRange.IsConstant() {
+		lhs, rhs = rhs, lhs
+		lRange, rRange = rRange, lRange
+	}
+	if !lRange.IsConstant() {
+		return false, nil
+	}
+
+	// exclude constant from the edge of the other range
+	r := rRange.Clone()
+	if r.lVal == lRange.lVal {
+		r.lIncluding = false
+	}
+	if r.rVal == lRange.lVal {
+		r.rIncluding = false
+	}
+	if r.IsNaN() {
+		return false, ERR_UNREACHABLE
+	}
+	return ctx.setRange(rhs, r)
+}
+
+func (ctx *Context) refineNumbers() error {
+	for round := 0; round < contextMaxRefineRounds; round++ {
+		changed := false
+		for _, rel := range ctx.relations {
+			relChanged, err := ctx.applyRelation(rel)
+			if err != nil {
+				return err
+			}
+			changed = changed || relChanged
+		}
+		if !changed {
+			return nil
+		}
+	}
+	return nil
+}
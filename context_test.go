@@ -0,0 +1,237 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ContextSuite struct {
+	suite.Suite
+	X Number
+	Y Number
+	B Boolean
+}
+
+func (s *ContextSuite) SetupTest() {
+	s.X = NewNumberSegment(0, 10)
+	s.Y = NewNumberSegment(5, 20)
+	s.B = NewBoolean()
+}
+
+func (s *ContextSuite) TestAssumeLess() {
+	assert := assert.New(s.T())
+
+	lt := s.X.Less(s.Y)
+	assert.True(lt.IsUnknown())
+
+	ctx, err := Assume(lt)
+	assert.Nil(err)
+
+	x := ctx.Number(s.X)
+	assert.True(x.IsSame(NewNumberSegment(0, 10)))
+
+	y := ctx.Number(s.Y)
+	assert.True(y.IsSame(NewNumberRange(&NRange{
+		lVal: 5, lIncluding: true,
+		rVal: 20, rIncluding: true,
+	})))
+
+	assert.True(ctx.Boolean(lt).IsTrue())
+	assert.True(lt.IsUnknown())
+
+	ctx, err = ctx.Assume(s.Y.Less(NewNumberConst(7)))
+	assert.Nil(err)
+
+	assert.True(ctx.Number(s.Y).IsSame(NewNumberRange(&NRange{
+		lVal: 5, lIncluding: true,
+		rVal: 7, rIncluding: false,
+	})))
+	assert.True(ctx.Number(s.X).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 7, rIncluding: false,
+	})))
+
+	assert.True(ctx.Boolean(s.X.Less(NewNumberConst(8))).IsTrue())
+	assert.True(ctx.Boolean(s.X.GreaterEqual(NewNumberConst(8))).IsFalse())
+}
+
+func (s *ContextSuite) TestSelect() {
+	assert := assert.New(s.T())
+
+	sel := Select(s.B, s.X, s.Y)
+	assert.True(sel.IsSame(NewNumberSegment(0, 20)))
+
+	ctx, err := Assume(s.B)
+	assert.Nil(err)
+	assert.True(ctx.Number(sel) == s.X)
+
+	ctx, err = Assume(s.B.Not())
+	assert.Nil(err)
+	assert.True(ctx.Number(sel) == s.Y)
+
+	// refined branch flows into select result
+	ctx, err = Assume(s.B.And(s.X.Less(NewNumberConst(3))))
+	assert.Nil(err)
+	assert.True(ctx.Number(sel).IsSame(NewNumberRange(NewNRange(0, 3, true, false))))
+
+	// condition decided through select result comparison
+	ctx, err = Assume(s.B.Not())
+	assert.Nil(err)
+	assert.True(ctx.Boolean(sel.Less(NewNumberConst(5))).IsFalse())
+
+	ctx, err = Assume(sel.Less(NewNumberConst(5)))
+	assert.Nil(err)
+	assert.True(ctx.Number(sel).IsSame(NewNumberRange(NewNRange(0, 5, true, false))))
+}
+
+func (s *ContextSuite) TestAssumeNotLess() {
+	assert := assert.New(s.T())
+
+	ctx, err := Assume(s.X.Less(s.Y).Not())
+	assert.Nil(err)
+
+	assert.True(ctx.Number(s.X).IsSame(NewNumberSegment(5, 10)))
+	assert.True(ctx.Number(s.Y).IsSame(NewNumberSegment(5, 10)))
+}
+
+func (s *ContextSuite) TestAssumeNaN() {
+	assert := assert.New(s.T())
+
+	x := s.X.OrNaN()
+
+	// false comparison may be due to NaN
+	ctx, err := Assume(x.Less(s.Y).Not())
+	assert.Nil(err)
+	assert.True(ctx.Number(x) == x)
+	assert.True(ctx.Number(s.Y) == s.Y)
+
+	// true comparison rules NaN out
+	ctx, err = Assume(x.Less(s.Y))
+	assert.Nil(err)
+	assert.True(ctx.Number(x).IsSame(s.X))
+
+	ctx, err = Assume(x.Less(NewNumberConst(5)))
+	assert.Nil(err)
+	assert.True(ctx.Number(x).IsSame(NewNumberRange(NewNRange(0, 5, true, false))))
+}
+
+func (s *ContextSuite) TestAssumeInteger() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(0, 10)
+	x.p.integer = NewBooleanConst(BTrue, nil)
+
+	ctx, err := Assume(x.Greater(NewNumberConst(8)))
+	assert.Nil(err)
+
+	nine := ctx.Number(x)
+	assert.True(nine.p.valRange.IsSame(newRangeSegment(9, 10)))
+	assert.True(nine.IsInteger().IsTrue())
+
+	ctx, err = ctx.Assume(x.Less(NewNumberConst(10)))
+	assert.Nil(err)
+
+	nine = ctx.Number(x)
+	assert.True(nine.IsConstant())
+	assert.True(nine.Equal(NewNumberConst(9)).IsTrue())
+}
+
+func (s *ContextSuite) TestAssumeAnd() {
+	assert := assert.New(s.T())
+
+	cond := s.X.Less(NewNumberConst(3)).And(s.B)
+	assert.True(cond.IsUnknown())
+
+	ctx, err := Assume(cond)
+	assert.Nil(err)
+
+	assert.True(ctx.Boolean(s.B).IsTrue())
+	assert.True(ctx.Number(s.X).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 3, rIncluding: false,
+	})))
+
+	// !(a and b) with a known to be true fixes b to false
+	ctx, err = Assume(s.X.Less(NewNumberConst(3)).And(s.B).Not())
+	assert.Nil(err)
+	ctx, err = ctx.Assume(s.B)
+	assert.Nil(err)
+	assert.True(ctx.Number(s.X).IsSame(NewNumberSegment(3, 10)))
+}
+
+func (s *ContextSuite) TestAssumeOr() {
+	assert := assert.New(s.T())
+
+	other := NewBoolean()
+	cond := s.B.Or(other)
+
+	ctx, err := Assume(cond.Not())
+	assert.Nil(err)
+	assert.True(ctx.Boolean(s.B).IsFalse())
+	assert.True(ctx.Boolean(other).IsFalse())
+	assert.True(ctx.Boolean(cond).IsFalse())
+}
+
+func (s *ContextSuite) TestAssumeConstraints() {
+	assert := assert.New(s.T())
+
+	// abs >= x
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+
+	ctx, err := Assume(abs.Less(NewNumberConst(4)))
+	assert.Nil(err)
+
+	assert.True(ctx.Number(abs).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 4, rIncluding: false,
+	})))
+	assert.True(ctx.Number(x).IsSame(NewNumberRange(&NRange{
+		lVal: -10, lIncluding: true,
+		rVal: 4, rIncluding: false,
+	})))
+}
+
+func (s *ContextSuite) TestUnreachable() {
+	assert := assert.New(s.T())
+
+	_, err := Assume(NewBooleanConst(BFalse, nil))
+	assert.Equal(ERR_UNREACHABLE, err)
+
+	_, err = Assume(s.Y.Less(s.X).And(s.X.Less(NewNumberConst(5))))
+	assert.Equal(ERR_UNREACHABLE, err)
+
+	ctx, err := Assume(s.B)
+	assert.Nil(err)
+	_, err = ctx.Assume(s.B.Not())
+	assert.Equal(ERR_UNREACHABLE, err)
+
+	ctx, err = Assume(s.X.Equal(NewNumberConst(3)))
+	assert.Nil(err)
+	assert.True(ctx.Number(s.X).IsConstant())
+	_, err = ctx.Assume(s.X.Equal(NewNumberConst(3)).Not())
+	assert.Equal(ERR_UNREACHABLE, err)
+}
+
+func (s *ContextSuite) TestFork() {
+	assert := assert.New(s.T())
+
+	lt := s.X.Less(NewNumberConst(5))
+
+	thenCtx, err := Assume(lt)
+	assert.Nil(err)
+	elseCtx, err := Assume(lt.Not())
+	assert.Nil(err)
+
+	assert.True(thenCtx.Number(s.X).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 5, rIncluding: false,
+	})))
+	assert.True(elseCtx.Number(s.X).IsSame(NewNumberSegment(5, 10)))
+	assert.True(NewContext().Number(s.X).IsSame(NewNumberSegment(0, 10)))
+}
+
+func TestContext(t *testing.T) {
+	suite.Run(t, new(ContextSuite))
+}
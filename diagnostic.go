@@ -0,0 +1,228 @@
+package virtual_types
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+type DiagSeverity int
+
+const (
+	DiagPossible DiagSeverity = 0
+	DiagDefinite DiagSeverity = 1
+)
+
+func (s DiagSeverity) String() string {
+	if s == DiagDefinite {
+		return "definite"
+	}
+	return "possible"
+}
+
+type DiagKind int
+
+const (
+	DiagDivByZero       DiagKind = 0
+	DiagNaNProduced     DiagKind = 1
+	DiagInfProduced     DiagKind = 2
+	DiagIntegerOverflow DiagKind = 3
+)
+
+func (k DiagKind) String() string {
+	switch k {
+	case DiagDivByZero:
+		return DIV_BY_ZERO_STR
+	case DiagNaNProduced:
+		return "NaN produced"
+	case DiagInfProduced:
+		return "infinity produced"
+	case DiagIntegerOverflow:
+		return "integer overflow"
+	}
+	return fmt.Sprintf("DiagKind(%d)", int(k))
+}
+
+type Diagnostic struct {
+	Kind     DiagKind
+	Severity DiagSeverity
+	// Op is operator symbol: + - * / // ^
+	Op     string
+	Reason string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Severity, d.Kind, d.Reason)
+}
+
+type DiagnosticSink interface {
+	Report(d Diagnostic)
+}
+
+// Diagnostics is the simplest sink collecting all reported diagnostics.
+type Diagnostics []Diagnostic
+
+func (d *Diagnostics) Report(diag Diagnostic) {
+	*d = append(*d, diag)
+}
+
+type diagnosticSinkKey struct{}
+
+func WithDiagnostics(ctx context.Context, sink DiagnosticSink) context.Context {
+	return context.WithValue(ctx, diagnosticSinkKey{}, sink)
+}
+
+func DiagnosticSinkFrom(ctx context.Context) DiagnosticSink {
+	sink, _ := ctx.Value(diagnosticSinkKey{}).(DiagnosticSink)
+	return sink
+}
+
+// ====== Checks ======
+
+// mayBe tells whether every (BTrue), some (BUnknown) or none (BFalse) of
+// values of n equal val
+func (n Number) mayBe(val float64) BValue {
+	all, some := true, false
+	for curr := n.p; curr != nil; curr = curr.next {
+		r := curr.valRange
+		if r == nil {
+			r = newRangeSegment(curr.val, curr.val)
+		}
+		if ok, _ := r.Contains(val); ok {
+			some = true
+			all = all && r.IsConstant() && !curr.nan
+		} else {
+			all = false
+		}
+	}
+	if !some {
+		return BFalse
+	} else if all {
+		return BTrue
+	}
+	return BUnknown
+}
+
+// isInt64 tells whether integer n fits into Lua integer
+func (n Number) isInt64() BValue {
+	r := n.p.hull()
+	if r == nil || !n.IsInteger().IsTrue() {
+		return BFalse
+	}
+
+	const min, max = -(1 << 63), 1 << 63
+	if r.lVal >= min && r.rVal < max {
+		return BTrue
+	} else if r.rVal < min || r.lVal >= max {
+		return BFalse
+	}
+	return BUnknown
+}
+
+func severity(val BValue) DiagSeverity {
+	if val == BTrue {
+		return DiagDefinite
+	}
+	return DiagPossible
+}
+
+func may(val BValue) string {
+	if val == BTrue {
+		return "is"
+	}
+	return "may be"
+}
+
+func diagnose(ctx context.Context, op string, x, y Number, f func() (Number, error)) (Number, error) {
+	sink := DiagnosticSinkFrom(ctx)
+	if sink == nil {
+		return f()
+	}
+
+	divByZero := BFalse
+	if op == "/" || op == "//" {
+		divByZero = y.mayBe(0)
+		if divByZero != BFalse {
+			sink.Report(Diagnostic{
+				Kind:     DiagDivByZero,
+				Severity: severity(divByZero),
+				Op:       op,
+				Reason:   fmt.Sprintf("divisor %s %s 0", y, may(divByZero)),
+			})
+		}
+	}
+
+	res, err := f()
+	if err != nil || divByZero != BFalse {
+		// NaN and infinity are consequences of division by zero
+		return res, err
+	}
+
+	if x.IsNaN().IsFalse() && y.IsNaN().IsFalse() {
+		if nan := res.IsNaN(); !nan.IsFalse() {
+			sink.Report(Diagnostic{
+				Kind:     DiagNaNProduced,
+				Severity: severity(nan.p.val),
+				Op:       op,
+				Reason:   fmt.Sprintf("%s %s %s %s NaN", x, op, y, may(nan.p.val)),
+			})
+		}
+	}
+
+	if x.IsInf(0).IsFalse() && y.IsInf(0).IsFalse() && !res.IsNaN().IsTrue() {
+		inf := res.mayBe(math.Inf(1))
+		if inf == BFalse {
+			inf = res.mayBe(math.Inf(-1))
+		}
+		if inf != BFalse {
+			sink.Report(Diagnostic{
+				Kind:     DiagInfProduced,
+				Severity: severity(inf),
+				Op:       op,
+				Reason:   fmt.Sprintf("%s %s %s %s infinite", x, op, y, may(inf)),
+			})
+		}
+	}
+
+	if op != "/" && op != "^" && x.isInt64() == BTrue && y.isInt64() == BTrue {
+		if fits := res.isInt64(); fits != BTrue {
+			overflow := BUnknown
+			if fits == BFalse {
+				overflow = BTrue
+			}
+			sink.Report(Diagnostic{
+				Kind:     DiagIntegerOverflow,
+				Severity: severity(overflow),
+				Op:       op,
+				Reason:   fmt.Sprintf("%s %s %s %s out of integer range", x, op, y, may(overflow)),
+			})
+		}
+	}
+
+	return res, err
+}
+
+// AddContext is Add reporting diagnostics to sink of ctx, see WithDiagnostics.
+func (n Number) AddContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "+", n, o, func() (Number, error) { return n.Add(o) })
+}
+
+func (n Number) SubContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "-", n, o, func() (Number, error) { return n.Sub(o) })
+}
+
+func (n Number) MulContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "*", n, o, func() (Number, error) { return n.Mul(o) })
+}
+
+func (n Number) DivContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "/", n, o, func() (Number, error) { return n.Div(o) })
+}
+
+func (n Number) IDivContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "//", n, o, func() (Number, error) { return n.IDiv(o) })
+}
+
+func (n Number) PowContext(ctx context.Context, o Number) (Number, error) {
+	return diagnose(ctx, "^", n, o, func() (Number, error) { return n.Pow(o) })
+}
@@ -0,0 +1,124 @@
+package virtual_types
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+)
+
+type DiagnosticSuite struct {
+	suite.Suite
+	Diags *Diagnostics
+	Ctx   context.Context
+}
+
+func (s *DiagnosticSuite) SetupTest() {
+	s.Diags = &Diagnostics{}
+	s.Ctx = WithDiagnostics(context.Background(), s.Diags)
+}
+
+func intRange(l, r float64) Number {
+	n, err := NewIntegerRange(NewNRange(l, r, true, true))
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (s *DiagnosticSuite) TestDivByZero() {
+	assert := assert.New(s.T())
+
+	res, err := NewNumberConst(1).DivContext(s.Ctx, NewNumberConst(0))
+	assert.Nil(err)
+	assert.Equal("inf", res.String())
+	assert.Len(*s.Diags, 1)
+	assert.Equal(Diagnostic{
+		Kind:     DiagDivByZero,
+		Severity: DiagDefinite,
+		Op:       "/",
+		Reason:   "divisor 0 is 0",
+	}, (*s.Diags)[0])
+
+	*s.Diags = nil
+	_, err = NewNumberConst(10).DivContext(s.Ctx, NewNumberSegment(0, 2))
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagPossible, (*s.Diags)[0].Severity)
+	assert.Equal("possible division by zero: divisor [0, 2] may be 0", (*s.Diags)[0].String())
+
+	*s.Diags = nil
+	// divisor excludes zero but result is unbounded
+	_, err = NewNumberConst(10).DivContext(s.Ctx, NewNumberRange(NewNRange(0, 1, false, true)))
+	assert.Nil(err)
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagInfProduced, (*s.Diags)[0].Kind)
+	assert.Equal(DiagPossible, (*s.Diags)[0].Severity)
+
+	*s.Diags = nil
+
+	_, err = NewNumberConst(10).DivContext(s.Ctx, NewNumberSegment(1, 2))
+	assert.Nil(err)
+	assert.Empty(*s.Diags)
+}
+
+func (s *DiagnosticSuite) TestInfAndNaN() {
+	assert := assert.New(s.T())
+
+	_, err := NewNumberConst(1e308).MulContext(s.Ctx, NewNumberConst(10))
+	assert.Nil(err)
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagInfProduced, (*s.Diags)[0].Kind)
+	assert.Equal(DiagDefinite, (*s.Diags)[0].Severity)
+
+	// propagated infinity is not produced
+	*s.Diags = nil
+	_, err = NewNumberConst(math.Inf(1)).AddContext(s.Ctx, NewNumberConst(1))
+	assert.Nil(err)
+	assert.Empty(*s.Diags)
+
+	_, err = NewNumberConst(-1).PowContext(s.Ctx, NewNumberConst(0.5))
+	assert.Nil(err)
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagNaNProduced, (*s.Diags)[0].Kind)
+	assert.Equal(DiagDefinite, (*s.Diags)[0].Severity)
+}
+
+func (s *DiagnosticSuite) TestIntegerOverflow() {
+	assert := assert.New(s.T())
+
+	_, err := intRange(0, 10).AddContext(s.Ctx, intRange(1, 2))
+	assert.Nil(err)
+	assert.Empty(*s.Diags)
+
+	_, err = intRange(1<<62, 1<<62+1024).AddContext(s.Ctx, intRange(1<<62-2048, 1<<62))
+	assert.Nil(err)
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagIntegerOverflow, (*s.Diags)[0].Kind)
+	assert.Equal(DiagPossible, (*s.Diags)[0].Severity)
+
+	*s.Diags = nil
+	_, err = intRange(1<<62, 1<<62+1024).MulContext(s.Ctx, NewNumberConst(4))
+	assert.Nil(err)
+	assert.Len(*s.Diags, 1)
+	assert.Equal(DiagDefinite, (*s.Diags)[0].Severity)
+
+	// floats never overflow
+	*s.Diags = nil
+	_, err = NewNumberSegment(0, 1e19).AddContext(s.Ctx, NewNumberConst(1))
+	assert.Nil(err)
+	assert.Empty(*s.Diags)
+}
+
+func (s *DiagnosticSuite) TestNoSink() {
+	assert := assert.New(s.T())
+
+	res, err := NewNumberConst(1).DivContext(context.Background(), NewNumberConst(0))
+	assert.Nil(err)
+	assert.Equal("inf", res.String())
+	assert.Nil(DiagnosticSinkFrom(context.Background()))
+}
+
+func TestDiagnostic(t *testing.T) {
+	suite.Run(t, new(DiagnosticSuite))
+}
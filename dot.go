@@ -0,0 +1,185 @@
+package virtual_types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT renders values and everything reachable from them through
+// constraints as Graphviz digraph. Numbers are boxes, Booleans are
+// ellipses, values passed to WriteDOT have double border. Each
+// constraint is an edge from its owner labelled with constraint name.
+func WriteDOT(w io.Writer, values ...BasicValue) error {
+	g, err := encodeGraph(values...)
+	if err != nil {
+		return err
+	}
+
+	d := &dotWriter{g: g, roots: map[int]bool{}, inlined: map[int]bool{}}
+	for _, id := range g.Roots {
+		d.roots[id] = true
+	}
+	d.inline()
+
+	d.buf.WriteString("digraph values {\n")
+	for id := range g.Nodes {
+		d.node(id)
+	}
+	d.buf.WriteString("}\n")
+
+	_, err = w.Write(d.buf.Bytes())
+	return err
+}
+
+type dotWriter struct {
+	g     valueGraph
+	roots map[int]bool
+	// plain integer flags shown in Number labels
+	inlined map[int]bool
+	buf     bytes.Buffer
+	// helper nodes for Or, And and Not constraints
+	ors int
+}
+
+func (d *dotWriter) label(n graphNode) string {
+	if n.Type == GRAPH_TYPE_BOOLEAN {
+		return n.Val
+	}
+	var label string
+	if n.Range == nil {
+		label = formatNumberValue(float64(*n.Const))
+	} else {
+		r := &NRange{
+			lVal:       float64(n.Range.L),
+			rVal:       float64(n.Range.R),
+			lIncluding: n.Range.LIncluding,
+			rIncluding: n.Range.RIncluding,
+		}
+		label = r.String()
+	}
+	if n.NaN {
+		label += " | " + NAN_STR
+	}
+	return label
+}
+
+// constInteger returns integer flag of Number node when it has no
+// constraints, such flag is shown in label instead of separate node
+func (d *dotWriter) constInteger(n graphNode) (BValue, bool) {
+	if n.Integer == nil {
+		return BFalse, true
+	}
+	integer := d.g.Nodes[*n.Integer]
+	if len(integer.Constraints) != 0 {
+		return BUnknown, false
+	}
+	val, _ := parseBValue(integer.Val)
+	return val, true
+}
+
+// inline finds Boolean nodes only used as plain integer flags
+func (d *dotWriter) inline() {
+	used := map[int]bool{}
+	for id := range d.roots {
+		used[id] = true
+	}
+	var refer func(c graphConstraint)
+	refer = func(c graphConstraint) {
+		for _, ref := range []*int{c.Subject, c.LHS, c.RHS, c.Cond, c.Then, c.Else} {
+			if ref != nil {
+				used[*ref] = true
+			}
+		}
+		for _, o := range c.Operands {
+			used[o] = true
+		}
+		for _, v := range c.Variants {
+			refer(v)
+		}
+	}
+	for _, n := range d.g.Nodes {
+		for _, c := range n.Constraints {
+			refer(c)
+		}
+		if _, ok := d.constInteger(n); !ok {
+			used[*n.Integer] = true
+		}
+	}
+
+	for _, n := range d.g.Nodes {
+		if n.Integer != nil && !used[*n.Integer] {
+			d.inlined[*n.Integer] = true
+		}
+	}
+}
+
+func (d *dotWriter) node(id int) {
+	if d.inlined[id] {
+		return
+	}
+
+	n := d.g.Nodes[id]
+	label := d.label(n)
+	shape := "ellipse"
+	if n.Type == GRAPH_TYPE_NUMBER {
+		shape = "box"
+		if integer, ok := d.constInteger(n); ok && integer == BTrue && n.Range != nil {
+			label += " int"
+		}
+	}
+
+	fmt.Fprintf(&d.buf, "\tn%d [shape=%s, label=%s", id, shape, strconv.Quote(label))
+	if d.roots[id] {
+		d.buf.WriteString(", peripheries=2")
+	}
+	d.buf.WriteString("];\n")
+
+	if n.Type == GRAPH_TYPE_NUMBER {
+		if _, ok := d.constInteger(n); !ok {
+			d.edge(id, *n.Integer, "integer", "dotted")
+		}
+		if n.Next != nil {
+			d.edge(id, *n.Next, "next", "dashed")
+		}
+	}
+	from := fmt.Sprintf("n%d", id)
+	for _, c := range n.Constraints {
+		d.constraint(from, c)
+	}
+}
+
+func (d *dotWriter) edge(from, to int, label, style string) {
+	fmt.Fprintf(&d.buf, "\tn%d -> n%d [label=%s, style=%s];\n", from, to, strconv.Quote(label), style)
+}
+
+func (d *dotWriter) constraintEdge(from string, to int, label string) {
+	fmt.Fprintf(&d.buf, "\t%s -> n%d [label=%s];\n", from, to, strconv.Quote(label))
+}
+
+func (d *dotWriter) constraint(from string, c graphConstraint) {
+	switch {
+	case c.Subject != nil:
+		d.constraintEdge(from, *c.Subject, c.Kind)
+	case c.Cond != nil:
+		d.constraintEdge(from, *c.Cond, "select cond")
+		d.constraintEdge(from, *c.Then, "select then")
+		d.constraintEdge(from, *c.Else, "select else")
+	case c.Rel != nil:
+		d.constraintEdge(from, *c.LHS, "lhs "+relationStr[*c.Rel])
+		d.constraintEdge(from, *c.RHS, "rhs "+relationStr[*c.Rel])
+	case len(c.Operands) != 0:
+		for _, o := range c.Operands {
+			d.constraintEdge(from, o, c.Kind)
+		}
+	case len(c.Variants) != 0:
+		or := fmt.Sprintf("or%d", d.ors)
+		d.ors++
+		fmt.Fprintf(&d.buf, "\t%s [shape=point];\n", or)
+		fmt.Fprintf(&d.buf, "\t%s -> %s [label=%s, arrowhead=none];\n", from, or, strconv.Quote(c.Kind))
+		for _, v := range c.Variants {
+			d.constraint(or, v)
+		}
+	}
+}
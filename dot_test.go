@@ -0,0 +1,86 @@
+package virtual_types
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type DOTSuite struct {
+	suite.Suite
+}
+
+func writeDOT(assert *assert.Assertions, values ...BasicValue) string {
+	var buf bytes.Buffer
+	assert.Nil(WriteDOT(&buf, values...))
+	return buf.String()
+}
+
+func (s *DOTSuite) TestNumber() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+	lt := abs.Less(NewNumberSegment(0, 20))
+
+	assert.Equal(`digraph values {
+	n0 [shape=box, label="[0, 10]", peripheries=2];
+	n0 -> n2 [label="NumberGreaterEqual"];
+	n2 [shape=box, label="[-10, 10]"];
+	n4 [shape=ellipse, label="unknown", peripheries=2];
+	n4 -> n0 [label="lhs <"];
+	n4 -> n5 [label="rhs <"];
+	n5 [shape=box, label="[0, 20]"];
+}
+`, writeDOT(assert, abs, lt))
+
+	i, err := NewIntegerRange(NewNRange(0, 10, false, true))
+	assert.Nil(err)
+	i.p.next = NewNumberConst(20).p
+	assert.Equal(`digraph values {
+	n0 [shape=box, label="[1, 10] int", peripheries=2];
+	n0 -> n2 [label="next", style=dashed];
+	n2 [shape=box, label="20"];
+}
+`, writeDOT(assert, i))
+
+	nan := NewNumberSegment(0, 1).OrNaN()
+	assert.Equal(`digraph values {
+	n0 [shape=box, label="[0, 1] | NaN", peripheries=2];
+}
+`, writeDOT(assert, nan))
+}
+
+func (s *DOTSuite) TestBoolean() {
+	assert := assert.New(s.T())
+
+	b := NewBoolean()
+	and := b.And(b.Not())
+	assert.True(and.IsFalse())
+	assert.Equal(`digraph values {
+	n0 [shape=ellipse, label="false", peripheries=2];
+	or0 [shape=point];
+	n0 -> or0 [label="BooleanOr", arrowhead=none];
+	or0 -> n1 [label="BooleanEqual"];
+	or0 -> n2 [label="BooleanEqual"];
+	n1 [shape=ellipse, label="unknown"];
+	n2 [shape=ellipse, label="unknown"];
+	n2 -> n1 [label="BooleanNotEqual"];
+}
+`, writeDOT(assert, and))
+
+	assert.Contains(writeDOT(assert, b.Or(NewBoolean())), `n0 -> n1 [label="BooleanDisjunction"];`)
+}
+
+func (s *DOTSuite) TestErrors() {
+	assert := assert.New(s.T())
+
+	var buf bytes.Buffer
+	assert.NotNil(WriteDOT(&buf, Number{}))
+	assert.Equal(0, buf.Len())
+}
+
+func TestDOT(t *testing.T) {
+	suite.Run(t, new(DOTSuite))
+}
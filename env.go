@@ -0,0 +1,223 @@
+package virtual_types
+
+import "sort"
+
+// Env maps variable names to abstract values. Forks share storage until
+// one of them is modified (copy-on-write). Env without vars map is bottom:
+// the state of unreachable code.
+type Env struct {
+	vars   map[string]BasicValue
+	shared bool
+}
+
+// Any is a value nothing is known about, not even its type: variable may be
+// undefined or hold values of different types on joined paths.
+type Any struct{}
+
+func (_ Any) TypeName() string {
+	return "any"
+}
+
+func (_ Any) IsValid() bool {
+	return true
+}
+
+func (_ Any) IsConstant() bool {
+	return false
+}
+
+func (_ Any) ToBoolean() Boolean {
+	return NewBoolean()
+}
+
+func (_ Any) String() string {
+	return "any"
+}
+
+func NewEnv() *Env {
+	return &Env{vars: map[string]BasicValue{}}
+}
+
+func (e *Env) Fork() *Env {
+	e.shared = true
+	return &Env{vars: e.vars, shared: true}
+}
+
+func (e *Env) own() {
+	if !e.shared {
+		return
+	}
+	vars := make(map[string]BasicValue, len(e.vars))
+	for k, v := range e.vars {
+		vars[k] = v
+	}
+	e.vars = vars
+	e.shared = false
+}
+
+func (e *Env) Get(name string) (BasicValue, bool) {
+	v, ok := e.vars[name]
+	return v, ok
+}
+
+func (e *Env) Number(name string) (Number, bool) {
+	n, ok := e.vars[name].(Number)
+	return n, ok
+}
+
+func (e *Env) Boolean(name string) (Boolean, bool) {
+	b, ok := e.vars[name].(Boolean)
+	return b, ok
+}
+
+func (e *Env) Set(name string, v BasicValue) {
+	e.own()
+	e.vars[name] = v
+}
+
+func (e *Env) Delete(name string) {
+	if _, ok := e.vars[name]; !ok {
+		return
+	}
+	e.own()
+	delete(e.vars, name)
+}
+
+func (e *Env) Len() int {
+	return len(e.vars)
+}
+
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isSameValue(a, b BasicValue) bool {
+	switch a := a.(type) {
+	case Number:
+		if b, ok := b.(Number); ok {
+			return a.IsSame(b)
+		}
+	case Boolean:
+		if b, ok := b.(Boolean); ok {
+			return a.p == b.p || a.IsSame(b)
+		}
+	default:
+		return a == b
+	}
+	return false
+}
+
+func (e *Env) Equal(o *Env) bool {
+	if (e.vars == nil) != (o.vars == nil) || len(e.vars) != len(o.vars) {
+		return false
+	}
+	for name, v := range e.vars {
+		ov, ok := o.vars[name]
+		if !ok || !isSameValue(v, ov) {
+			return false
+		}
+	}
+	return true
+}
+
+type valueMergeFunc func(a, b BasicValue) (BasicValue, bool)
+
+func joinValues(a, b BasicValue) (BasicValue, bool) {
+	switch a := a.(type) {
+	case Number:
+		if b, ok := b.(Number); ok {
+			return a.Join(b), true
+		}
+	case Boolean:
+		if b, ok := b.(Boolean); ok {
+			return a.Join(b), true
+		}
+	}
+	return nil, false
+}
+
+func widenValues(a, b BasicValue) (BasicValue, bool) {
+	switch a := a.(type) {
+	case Number:
+		if b, ok := b.(Number); ok {
+			return a.Widen(b), true
+		}
+	case Boolean:
+		if b, ok := b.(Boolean); ok {
+			return a.Join(b), true
+		}
+	}
+	return nil, false
+}
+
+// merge joins values of variables defined in both environments by f.
+// Values of other types survive only if identical, otherwise as well as for
+// variables defined only in one environment the result is Any. Bottom is
+// the identity: unreachable state adds nothing.
+func (e *Env) merge(o *Env, f valueMergeFunc) *Env {
+	if e.vars == nil {
+		return o.Fork()
+	} else if o.vars == nil {
+		return e.Fork()
+	}
+
+	res := &Env{vars: make(map[string]BasicValue, len(e.vars))}
+	for name, v := range e.vars {
+		ov, ok := o.vars[name]
+		if !ok {
+			res.vars[name] = Any{}
+		} else if v == ov {
+			res.vars[name] = v
+		} else if merged, ok := f(v, ov); ok {
+			res.vars[name] = merged
+		} else {
+			res.vars[name] = Any{}
+		}
+	}
+	for name := range o.vars {
+		if _, ok := e.vars[name]; !ok {
+			res.vars[name] = Any{}
+		}
+	}
+	return res
+}
+
+// Join merges environments at control flow merge points.
+func (e *Env) Join(o *Env) *Env {
+	return e.merge(o, joinValues)
+}
+
+// Widen merges e (loop head state) with o (state on a back edge).
+func (e *Env) Widen(o *Env) *Env {
+	return e.merge(o, widenValues)
+}
+
+// Assume returns an environment with all values refined under cond.
+func (e *Env) Assume(cond Boolean) (*Env, error) {
+	ctx, err := Assume(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	res := e.Fork()
+	for name, v := range e.vars {
+		var refined BasicValue
+		switch v := v.(type) {
+		case Number:
+			refined = ctx.Number(v)
+		case Boolean:
+			refined = ctx.Boolean(v)
+		default:
+			continue
+		}
+		if refined != v {
+			res.Set(name, refined)
+		}
+	}
+	return res, nil
+}
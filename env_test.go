@@ -0,0 +1,168 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+)
+
+type EnvSuite struct {
+	suite.Suite
+	Env *Env
+}
+
+func (s *EnvSuite) SetupTest() {
+	s.Env = NewEnv()
+	s.Env.Set("x", NewNumberSegment(0, 10))
+	s.Env.Set("b", NewBoolean())
+	s.Env.Set("one", NewNumberConst(1))
+}
+
+func (s *EnvSuite) TestGetSet() {
+	assert := assert.New(s.T())
+
+	assert.Equal(3, s.Env.Len())
+	assert.Equal([]string{"b", "one", "x"}, s.Env.Names())
+
+	x, ok := s.Env.Number("x")
+	assert.True(ok)
+	assert.True(x.IsSame(NewNumberSegment(0, 10)))
+
+	_, ok = s.Env.Number("b")
+	assert.False(ok)
+
+	b, ok := s.Env.Boolean("b")
+	assert.True(ok)
+	assert.True(b.IsUnknown())
+
+	v, ok := s.Env.Get("one")
+	assert.True(ok)
+	assert.Equal("Number", v.TypeName())
+	assert.True(v.ToBoolean().IsTrue())
+
+	s.Env.Delete("one")
+	_, ok = s.Env.Get("one")
+	assert.False(ok)
+}
+
+func (s *EnvSuite) TestFork() {
+	assert := assert.New(s.T())
+
+	fork := s.Env.Fork()
+	assert.True(fork.Equal(s.Env))
+
+	fork.Set("x", NewNumberConst(3))
+	x, _ := s.Env.Number("x")
+	assert.True(x.IsSame(NewNumberSegment(0, 10)))
+	assert.False(fork.Equal(s.Env))
+
+	s.Env.Delete("b")
+	_, ok := fork.Boolean("b")
+	assert.True(ok)
+	assert.Equal(3, fork.Len())
+	assert.Equal(2, s.Env.Len())
+}
+
+func (s *EnvSuite) TestJoin() {
+	assert := assert.New(s.T())
+
+	then := s.Env.Fork()
+	then.Set("x", NewNumberConst(-5))
+	then.Set("b", NewBooleanConst(BTrue, nil))
+	then.Set("y", NewNumberConst(1))
+
+	els := s.Env.Fork()
+	els.Set("b", NewBooleanConst(BTrue, nil))
+	els.Set("one", NewBooleanConst(BFalse, nil))
+
+	joined := then.Join(els)
+	assert.Equal([]string{"b", "one", "x", "y"}, joined.Names())
+
+	// y is undefined in else branch, one has different types
+	y, _ := joined.Get("y")
+	assert.Equal(Any{}, y)
+	one, _ := joined.Get("one")
+	assert.Equal(Any{}, one)
+
+	x, _ := joined.Number("x")
+	assert.True(x.IsSame(NewNumberSegment(-5, 10)))
+
+	b, _ := joined.Boolean("b")
+	assert.True(b.IsTrue())
+
+	assert.True(s.Env.Join(s.Env.Fork()).Equal(s.Env))
+
+	// bottom is unreachable state and adds nothing
+	bottom := &Env{}
+	assert.False(bottom.Equal(NewEnv()))
+	assert.True(bottom.Join(s.Env).Equal(s.Env))
+	assert.True(s.Env.Join(bottom).Equal(s.Env))
+	assert.True(bottom.Widen(s.Env).Equal(s.Env))
+	assert.True(s.Env.Widen(bottom).Equal(s.Env))
+	assert.True(bottom.Join(bottom).Equal(bottom))
+
+	// result is a fork, changes don't leak into operands
+	joined = bottom.Join(s.Env)
+	joined.Set("x", NewNumberConst(5))
+	x, _ = s.Env.Number("x")
+	assert.True(x.IsSame(NewNumberSegment(0, 10)))
+}
+
+func (s *EnvSuite) TestWiden() {
+	assert := assert.New(s.T())
+
+	// i = 0; while ... do i = i + 1 end
+	head := NewEnv()
+	head.Set("i", NewNumberConst(0))
+
+	for iter := 0; iter < 10; iter++ {
+		i, _ := head.Number("i")
+		next, err := i.Add(NewNumberConst(1))
+		assert.Nil(err)
+
+		body := head.Fork()
+		body.Set("i", next)
+
+		widened := head.Widen(head.Join(body))
+		if widened.Equal(head) {
+			break
+		}
+		head = widened
+	}
+
+	i, _ := head.Number("i")
+	assert.True(i.p.valRange.IsSame(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: math.Inf(1), rIncluding: true,
+	}))
+	assert.True(i.IsInteger().IsTrue())
+}
+
+func (s *EnvSuite) TestAssume() {
+	assert := assert.New(s.T())
+
+	x, _ := s.Env.Number("x")
+	b, _ := s.Env.Boolean("b")
+
+	env, err := s.Env.Assume(x.Less(NewNumberConst(5)).And(b))
+	assert.Nil(err)
+
+	refinedX, _ := env.Number("x")
+	assert.True(refinedX.IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 5, rIncluding: false,
+	})))
+	refinedB, _ := env.Boolean("b")
+	assert.True(refinedB.IsTrue())
+
+	x, _ = s.Env.Number("x")
+	assert.True(x.IsSame(NewNumberSegment(0, 10)))
+
+	_, err = s.Env.Assume(x.Greater(NewNumberConst(10)))
+	assert.Equal(ERR_UNREACHABLE, err)
+}
+
+func TestEnv(t *testing.T) {
+	suite.Run(t, new(EnvSuite))
+}
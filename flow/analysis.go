@@ -0,0 +1,229 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+)
+
+const NO_FIXPOINT_STR = "fixpoint was not reached"
+
+var ERR_NO_FIXPOINT = errors.New(NO_FIXPOINT_STR)
+
+const (
+	// number of joins at loop head before widening kicks in
+	defaultWidenDelay = 2
+	// upper bound of block visits per block
+	defaultMaxVisits = 64
+)
+
+// Options tune the analysis, zero fields take default values.
+type Options struct {
+	WidenDelay int
+	MaxVisits  int
+}
+
+func (opt Options) withDefaults() Options {
+	if opt.WidenDelay == 0 {
+		opt.WidenDelay = defaultWidenDelay
+	}
+	if opt.MaxVisits == 0 {
+		opt.MaxVisits = defaultMaxVisits
+	}
+	return opt
+}
+
+// Result holds abstract states at every program point of analyzed graph.
+// States of unreachable blocks are nil.
+type Result struct {
+	graph  *Graph
+	points [][]*vt.Env
+}
+
+// Before returns state before i-th statement of b. Passing i equal to
+// len(b.Stmts) gives state after the last statement (before branching).
+func (r *Result) Before(b *Block, i int) *vt.Env {
+	if r.points[b.ID] == nil {
+		return nil
+	}
+	return r.points[b.ID][i]
+}
+
+func (r *Result) In(b *Block) *vt.Env {
+	return r.Before(b, 0)
+}
+
+func (r *Result) Out(b *Block) *vt.Env {
+	return r.Before(b, len(b.Stmts))
+}
+
+func (r *Result) Reachable(b *Block) bool {
+	return r.points[b.ID] != nil
+}
+
+// Exit returns join of states of all reachable exit blocks.
+func (r *Result) Exit() *vt.Env {
+	var res *vt.Env
+	for _, b := range r.graph.Blocks {
+		if !b.IsExit() || !r.Reachable(b) {
+			continue
+		}
+		if res == nil {
+			res = r.Out(b)
+		} else {
+			res = res.Join(r.Out(b))
+		}
+	}
+	return res
+}
+
+func Analyze(g *Graph, entry *vt.Env) (*Result, error) {
+	return AnalyzeWithOpt(g, entry, Options{})
+}
+
+// AnalyzeWithOpt runs analysis of g starting from entry state, nil entry
+// is an empty Env
+func AnalyzeWithOpt(g *Graph, entry *vt.Env, opt Options) (*Result, error) {
+	opt = opt.withDefaults()
+	if entry == nil {
+		entry = vt.NewEnv()
+	}
+	res := &Result{graph: g, points: make([][]*vt.Env, len(g.Blocks))}
+	rpo, heads := g.order()
+	if len(rpo) == 0 {
+		return res, nil
+	}
+
+	index := make(map[*Block]int, len(rpo))
+	for i, b := range rpo {
+		index[b] = i
+	}
+
+	in := make([]*vt.Env, len(g.Blocks))
+	joins := make([]int, len(g.Blocks))
+	visits := make([]int, len(g.Blocks))
+	in[rpo[0].ID] = entry.Fork()
+
+	// worklist is indexed by reverse postorder so inner blocks of loops
+	// are stabilized before blocks following them
+	pending := make([]bool, len(rpo))
+	pending[0] = true
+
+	for {
+		i := 0
+		for i < len(pending) && !pending[i] {
+			i++
+		}
+		if i == len(pending) {
+			break
+		}
+		pending[i] = false
+
+		b := rpo[i]
+		visits[b.ID]++
+		if visits[b.ID] > opt.MaxVisits {
+			return nil, fmt.Errorf("b%d: %w", b.ID, ERR_NO_FIXPOINT)
+		}
+
+		points, err := transfer(b, in[b.ID])
+		if err != nil {
+			return nil, err
+		}
+		res.points[b.ID] = points
+
+		succs, err := edges(b, points[len(points)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		for si, s := range b.Succs {
+			env := succs[si]
+			if env == nil {
+				continue
+			}
+
+			prev := in[s.ID]
+			next := env
+			if prev != nil {
+				next = prev.Join(env)
+				if heads[s] {
+					joins[s.ID]++
+					if joins[s.ID] > opt.WidenDelay {
+						next = prev.Widen(next)
+					}
+				}
+				if next.Equal(prev) {
+					continue
+				}
+			}
+			in[s.ID] = next
+			pending[index[s]] = true
+		}
+	}
+
+	return res, nil
+}
+
+func transfer(b *Block, in *vt.Env) ([]*vt.Env, error) {
+	points := make([]*vt.Env, 0, len(b.Stmts)+1)
+	points = append(points, in)
+
+	env := in
+	for _, s := range b.Stmts {
+		v, err := s.Expr.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("b%d: %s: %s", b.ID, s, err.Error())
+		}
+		env = env.Fork()
+		env.Set(s.Name, v)
+		points = append(points, env)
+	}
+	return points, nil
+}
+
+// edges returns states flowing to each successor of b, nil for edges
+// which are never taken.
+func edges(b *Block, out *vt.Env) ([]*vt.Env, error) {
+	if b.Cond == nil {
+		res := make([]*vt.Env, len(b.Succs))
+		for i := range res {
+			res[i] = out
+		}
+		return res, nil
+	}
+
+	v, err := b.Cond.Eval(out)
+	if err != nil {
+		return nil, fmt.Errorf("b%d: if %s: %s", b.ID, b.Cond, err.Error())
+	}
+	cond, ok := v.(vt.Boolean)
+	if !ok {
+		return nil, fmt.Errorf("b%d: branch condition %s is %s, expected Boolean", b.ID, b.Cond, v.TypeName())
+	}
+
+	switch {
+	case cond.IsTrue():
+		return []*vt.Env{out, nil}, nil
+	case cond.IsFalse():
+		return []*vt.Env{nil, out}, nil
+	}
+
+	then, err := assume(out, cond)
+	if err != nil {
+		return nil, fmt.Errorf("b%d: %s", b.ID, err.Error())
+	}
+	els, err := assume(out, cond.Not())
+	if err != nil {
+		return nil, fmt.Errorf("b%d: %s", b.ID, err.Error())
+	}
+	return []*vt.Env{then, els}, nil
+}
+
+func assume(env *vt.Env, cond vt.Boolean) (*vt.Env, error) {
+	res, err := env.Assume(cond)
+	if err == vt.ERR_UNREACHABLE {
+		return nil, nil
+	}
+	return res, err
+}
@@ -0,0 +1,153 @@
+package flow
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+type FlowSuite struct {
+	suite.Suite
+}
+
+var p = vexpr.MustParse
+
+func numberString(env *vt.Env, name string) string {
+	n, ok := env.Number(name)
+	if !ok {
+		return "<none>"
+	}
+	return n.String()
+}
+
+func (s *FlowSuite) TestStraightLine() {
+	assert := assert.New(s.T())
+
+	g := NewGraph()
+	g.NewBlock().
+		Assign("y", p("x * 2")).
+		Assign("z", p("y + 1"))
+
+	entry := vt.NewEnv()
+	assert.Nil(vexpr.Declare(entry, "x in [0, 10] int"))
+
+	res, err := Analyze(g, entry)
+	assert.Nil(err)
+
+	b := g.Entry()
+	assert.Equal("<none>", numberString(res.Before(b, 0), "y"))
+	assert.Equal("[0, 20] int", numberString(res.Before(b, 1), "y"))
+	assert.Equal("[1, 21] int", numberString(res.Out(b), "z"))
+	assert.Equal("[1, 21] int", numberString(res.Exit(), "z"))
+}
+
+func (s *FlowSuite) TestBranch() {
+	assert := assert.New(s.T())
+
+	// if x < 5 then y = 0 else y = x end
+	g := NewGraph()
+	head, then, els, join := g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock()
+	head.Branch(p("x < 5"), then, els)
+	then.Assign("y", p("0")).Jump(join)
+	els.Assign("y", p("x")).Jump(join)
+
+	entry := vt.NewEnv()
+	assert.Nil(vexpr.Declare(entry, "x in [0, 10] int"))
+
+	res, err := Analyze(g, entry)
+	assert.Nil(err)
+
+	assert.Equal("[0, 4] int", numberString(res.In(then), "x"))
+	assert.Equal("[5, 10] int", numberString(res.In(els), "x"))
+	assert.Equal("[0, 10] int", numberString(res.In(join), "x"))
+	assert.True(res.Reachable(join))
+}
+
+func (s *FlowSuite) TestDeadBranch() {
+	assert := assert.New(s.T())
+
+	g := NewGraph()
+	head, then, els := g.NewBlock(), g.NewBlock(), g.NewBlock()
+	head.Branch(p("x > 10"), then, els)
+	then.Assign("y", p("1"))
+	els.Assign("y", p("2"))
+
+	entry := vt.NewEnv()
+	assert.Nil(vexpr.Declare(entry, "x in [0, 10]"))
+
+	res, err := Analyze(g, entry)
+	assert.Nil(err)
+
+	assert.False(res.Reachable(then))
+	assert.Nil(res.In(then))
+	assert.True(res.Reachable(els))
+	assert.Equal("2", numberString(res.Exit(), "y"))
+}
+
+func (s *FlowSuite) TestLoop() {
+	assert := assert.New(s.T())
+
+	// i = 0; while i < 10 do i = i + 1 end
+	g := NewGraph()
+	init, head, body, exit := g.NewBlock(), g.NewBlock(), g.NewBlock(), g.NewBlock()
+	init.Assign("i", p("0")).Jump(head)
+	head.Branch(p("i < 10"), body, exit)
+	body.Assign("i", p("i + 1")).Jump(head)
+
+	res, err := Analyze(g, vt.NewEnv())
+	assert.Nil(err)
+
+	assert.Equal("[0, 9] int", numberString(res.In(body), "i"))
+	assert.Equal("[1, 10] int", numberString(res.Out(body), "i"))
+	assert.Equal("[10, inf] int", numberString(res.In(exit), "i"))
+}
+
+func (s *FlowSuite) TestErrors() {
+	assert := assert.New(s.T())
+
+	g := NewGraph()
+	g.NewBlock().Assign("y", p("x + 1"))
+	_, err := Analyze(g, vt.NewEnv())
+	assert.NotNil(err)
+
+	g = NewGraph()
+	b := g.NewBlock()
+	b.Branch(p("1 + 1"), b, b)
+	_, err = Analyze(g, vt.NewEnv())
+	assert.NotNil(err)
+
+	// unbounded growth without widening
+	g = NewGraph()
+	init, head := g.NewBlock(), g.NewBlock()
+	init.Assign("i", p("0")).Jump(head)
+	head.Assign("i", p("i + 1")).Jump(head)
+	_, err = AnalyzeWithOpt(g, vt.NewEnv(), Options{WidenDelay: 1000, MaxVisits: 10})
+	assert.True(errors.Is(err, ERR_NO_FIXPOINT))
+
+	res, err := Analyze(g, vt.NewEnv())
+	assert.Nil(err)
+	assert.Nil(res.Exit())
+	assert.Equal("[1, inf] int", numberString(res.Out(head), "i"))
+
+	// zero options are defaults
+	res, err = AnalyzeWithOpt(g, vt.NewEnv(), Options{})
+	assert.Nil(err)
+	assert.Equal("[1, inf] int", numberString(res.Out(head), "i"))
+
+	// nil entry declares nothing
+	res, err = Analyze(g, nil)
+	assert.Nil(err)
+	assert.Equal("[1, inf] int", numberString(res.Out(head), "i"))
+	g = NewGraph()
+	g.NewBlock().Assign("y", p("x + 1"))
+	_, err = Analyze(g, nil)
+	assert.NotNil(err)
+}
+
+func TestFlow(t *testing.T) {
+	suite.Run(t, new(FlowSuite))
+}
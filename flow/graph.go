@@ -0,0 +1,139 @@
+// Package flow runs range analysis of programs over virtual types.
+//
+// Program is a control flow graph of basic blocks. Each block is a list
+// of assignments followed by unconditional jump, conditional branch or
+// exit. Analyze iterates abstract states (environments of virtual values)
+// to a fixpoint, joining states at merge points and widening them at loop
+// heads.
+package flow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+type Assign struct {
+	Name string
+	Expr vexpr.Expr
+}
+
+func (a Assign) String() string {
+	return a.Name + " = " + a.Expr.String()
+}
+
+type Block struct {
+	ID    int
+	Stmts []Assign
+
+	// Cond is set for blocks ending with conditional branch to Succs[0]
+	// (Cond is true) or Succs[1] (Cond is false)
+	Cond  vexpr.Expr
+	Succs []*Block
+}
+
+func (b *Block) Assign(name string, e vexpr.Expr) *Block {
+	b.Stmts = append(b.Stmts, Assign{Name: name, Expr: e})
+	return b
+}
+
+func (b *Block) Jump(target *Block) {
+	b.Cond = nil
+	b.Succs = []*Block{target}
+}
+
+func (b *Block) Branch(cond vexpr.Expr, then, els *Block) {
+	b.Cond = cond
+	b.Succs = []*Block{then, els}
+}
+
+func (b *Block) IsExit() bool {
+	return len(b.Succs) == 0
+}
+
+func (b *Block) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "b%d:\n", b.ID)
+	for _, s := range b.Stmts {
+		fmt.Fprintf(&sb, "  %s\n", s)
+	}
+	switch {
+	case b.Cond != nil:
+		fmt.Fprintf(&sb, "  if %s goto b%d else b%d\n", b.Cond, b.Succs[0].ID, b.Succs[1].ID)
+	case len(b.Succs) == 1:
+		fmt.Fprintf(&sb, "  goto b%d\n", b.Succs[0].ID)
+	default:
+		sb.WriteString("  exit\n")
+	}
+	return sb.String()
+}
+
+// Graph is a control flow graph, the first created block is the entry.
+type Graph struct {
+	Blocks []*Block
+}
+
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+func (g *Graph) NewBlock() *Block {
+	b := &Block{ID: len(g.Blocks)}
+	g.Blocks = append(g.Blocks, b)
+	return b
+}
+
+func (g *Graph) Entry() *Block {
+	if len(g.Blocks) == 0 {
+		return nil
+	}
+	return g.Blocks[0]
+}
+
+func (g *Graph) String() string {
+	var sb strings.Builder
+	for _, b := range g.Blocks {
+		sb.WriteString(b.String())
+	}
+	return sb.String()
+}
+
+// order returns blocks reachable from entry in reverse postorder and marks
+// targets of back edges as loop heads.
+func (g *Graph) order() (rpo []*Block, heads map[*Block]bool) {
+	heads = map[*Block]bool{}
+	if g.Entry() == nil {
+		return nil, heads
+	}
+
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := map[*Block]int{}
+
+	var post []*Block
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		state[b] = onStack
+		for _, s := range b.Succs {
+			switch state[s] {
+			case unvisited:
+				visit(s)
+			case onStack:
+				heads[s] = true
+			}
+		}
+		state[b] = done
+		post = append(post, b)
+	}
+	visit(g.Entry())
+
+	rpo = make([]*Block, len(post))
+	for i, b := range post {
+		rpo[len(post)-1-i] = b
+	}
+	return rpo, heads
+}
@@ -0,0 +1,62 @@
+package virtual_types
+
+import "math"
+
+// ====== Interning ======
+
+// Constants without constraints are immutable and their identity carries
+// no meaning, so identical ones share single private instead of being
+// allocated on each use. Values with ranges are never interned: two
+// unknown values must stay distinguishable (see BooleanPrivate.equal),
+// the same holds for NaN since NaN != NaN. Interned privates must never
+// be mutated.
+
+const (
+	INTERN_MIN_INT = -256
+	INTERN_MAX_INT = 1024
+)
+
+var (
+	_false = Boolean{p: &BooleanPrivate{val: BFalse}}
+	_true  = Boolean{p: &BooleanPrivate{val: BTrue}}
+)
+
+var internedInts = func() []*NumberPrivate {
+	res := make([]*NumberPrivate, INTERN_MAX_INT-INTERN_MIN_INT+1)
+	for i := range res {
+		res[i] = &NumberPrivate{val: float64(INTERN_MIN_INT + i), integer: _true}
+	}
+	return res
+}()
+
+var (
+	internedInf      = &NumberPrivate{val: math.Inf(1), integer: _true}
+	internedMinusInf = &NumberPrivate{val: math.Inf(-1), integer: _true}
+)
+
+// booleanConst returns shared Boolean for known v, unknown Booleans are
+// always fresh
+func booleanConst(v BValue) Boolean {
+	switch v {
+	case BFalse:
+		return _false
+	case BTrue:
+		return _true
+	}
+	return NewBooleanConst(v, nil)
+}
+
+// internedNumber returns shared private for v or nil if v is not interned
+func internedNumber(v float64) *NumberPrivate {
+	switch {
+	case math.IsInf(v, 1):
+		return internedInf
+	case math.IsInf(v, -1):
+		return internedMinusInf
+	case v < INTERN_MIN_INT || v > INTERN_MAX_INT || v != math.Floor(v):
+		return nil
+	case v == 0 && math.Signbit(v):
+		return nil
+	}
+	return internedInts[int(v)-INTERN_MIN_INT]
+}
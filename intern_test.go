@@ -0,0 +1,115 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+)
+
+type InternSuite struct {
+	suite.Suite
+}
+
+func (s *InternSuite) TestNumber() {
+	assert := assert.New(s.T())
+
+	assert.True(NewNumberConst(0).p == NewNumberConst(0).p)
+	assert.True(NewNumberConst(-256).p == NewNumberConst(-256).p)
+	assert.True(NewNumberConst(math.Inf(-1)).p == NewNumberConst(math.Inf(-1)).p)
+	assert.True(NewNumberConst(1).IsInteger().IsTrue())
+
+	// not interned
+	for _, v := range []float64{0.5, 1025, -257, math.Copysign(0, -1), math.NaN()} {
+		assert.False(NewNumberConst(v).p == NewNumberConst(v).p, v)
+	}
+	assert.False(NewNumberSegment(0, 1).p == NewNumberSegment(0, 1).p)
+
+	nan := NewNumberConst(math.NaN())
+	assert.True(nan.Equal(nan).IsFalse())
+	assert.True(NewNumberConst(3).Equal(NewNumberConst(3)).IsTrue())
+}
+
+func (s *InternSuite) TestNotMutated() {
+	assert := assert.New(s.T())
+
+	two := NewNumberConst(2)
+	res, err := NewNumberConst(4).IDiv(NewNumberConst(2))
+	assert.Nil(err)
+	assert.True(res.p == two.p)
+
+	x := NewNumberSegment(-4, 4)
+	abs := x.Abs()
+	assert.Len(abs.Constraints(), 1)
+	assert.Len(two.Constraints(), 0)
+
+	half, err := NewNumberConst(1).Div(NewNumberConst(2))
+	assert.Nil(err)
+	assert.True(half.IsInteger().IsFalse())
+	assert.True(NewNumberConst(1).IsInteger().IsTrue())
+}
+
+func (s *InternSuite) TestBoolean() {
+	assert := assert.New(s.T())
+
+	assert.True(NewNumberConst(1).IsNaN().p == NewNumberConst(2).IsNaN().p)
+	assert.True(NewNumberConst(1).IsInf(1).IsFalse())
+
+	// unknown values are always distinct
+	assert.False(NewBoolean().p == NewBoolean().p)
+	assert.False(booleanConst(BUnknown).p == booleanConst(BUnknown).p)
+
+	lt := NewNumberSegment(0, 1).Less(NewNumberSegment(2, 3))
+	assert.True(lt.IsTrue())
+	assert.False(lt.p == _true.p)
+	assert.Nil(_true.Explain())
+}
+
+func TestIntern(t *testing.T) {
+	suite.Run(t, new(InternSuite))
+}
+
+var benchNumber Number
+
+func BenchmarkNewNumberConst(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchNumber = NewNumberConst(float64(i % 100))
+	}
+}
+
+func BenchmarkIsNaN(b *testing.B) {
+	b.ReportAllocs()
+	n := NewNumberSegment(0, 10)
+	c := NewNumberConst(5)
+	for i := 0; i < b.N; i++ {
+		n.IsNaN()
+		c.IsNaN()
+	}
+}
+
+func BenchmarkAbs(b *testing.B) {
+	b.ReportAllocs()
+	n := NewNumberConst(-5)
+	for i := 0; i < b.N; i++ {
+		n.Abs()
+	}
+}
+
+func BenchmarkOperatorConst(b *testing.B) {
+	b.ReportAllocs()
+	x, y := NewNumberConst(3), NewNumberConst(4)
+	for i := 0; i < b.N; i++ {
+		x.Add(y)
+		x.Mul(y)
+	}
+}
+
+func BenchmarkCompareConst(b *testing.B) {
+	b.ReportAllocs()
+	x, y := NewNumberConst(3), NewNumberConst(4)
+	for i := 0; i < b.N; i++ {
+		x.Less(y)
+		x.Equal(y)
+	}
+}
@@ -0,0 +1,121 @@
+package virtual_types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Values are encoded as graph of nodes (see valueGraph):
+//
+//	{"roots": [0], "nodes": [
+//		{"type": "Number", "range": {"l": 0, "r": "inf", "lIncluding": true}, "integer": 1,
+//		 "constraints": [{"kind": "NumberGreater", "subject": 2}]},
+//		{"type": "Boolean", "val": "true"},
+//		...
+//	]}
+
+// jsonFloat encodes infinities and NaN as strings
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"nan"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-inf"`), nil
+	}
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		switch s {
+		case "nan":
+			*f = jsonFloat(math.NaN())
+		case "inf":
+			*f = jsonFloat(math.Inf(1))
+		case "-inf":
+			*f = jsonFloat(math.Inf(-1))
+		default:
+			return fmt.Errorf("invalid number %q", s)
+		}
+		return nil
+	}
+
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+// MarshalValuesJSON encodes values into single graph preserving sharing
+// between them.
+func MarshalValuesJSON(values ...BasicValue) ([]byte, error) {
+	g, err := encodeGraph(values...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(g)
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	return MarshalValuesJSON(n)
+}
+
+func (b Boolean) MarshalJSON() ([]byte, error) {
+	return MarshalValuesJSON(b)
+}
+
+// UnmarshalValuesJSON decodes values encoded by MarshalValuesJSON.
+func UnmarshalValuesJSON(data []byte) ([]BasicValue, error) {
+	var g valueGraph
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	return decodeGraph(g)
+}
+
+func unmarshalSingleJSON(data []byte) (BasicValue, error) {
+	values, err := UnmarshalValuesJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected single value, got %d", len(values))
+	}
+	return values[0], nil
+}
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalSingleJSON(data)
+	if err != nil {
+		return err
+	}
+	num, ok := v.(Number)
+	if !ok {
+		return fmt.Errorf("expected Number, got %s", v.TypeName())
+	}
+	*n = num
+	return nil
+}
+
+func (b *Boolean) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalSingleJSON(data)
+	if err != nil {
+		return err
+	}
+	bv, ok := v.(Boolean)
+	if !ok {
+		return fmt.Errorf("expected Boolean, got %s", v.TypeName())
+	}
+	*b = bv
+	return nil
+}
@@ -0,0 +1,168 @@
+package virtual_types
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+)
+
+type JSONSuite struct {
+	suite.Suite
+}
+
+func roundTripNumber(assert *assert.Assertions, n Number) Number {
+	data, err := json.Marshal(n)
+	assert.Nil(err)
+
+	var res Number
+	assert.Nil(json.Unmarshal(data, &res))
+	return res
+}
+
+func (s *JSONSuite) TestNumber() {
+	assert := assert.New(s.T())
+
+	for _, n := range []Number{
+		NewNumberConst(1.5),
+		NewNumberConst(math.Inf(-1)),
+		NewNumberSegment(0, 10),
+		NewNumberRange(NewNRange(math.Inf(-1), 0, true, false)),
+		NewNumber(),
+		NewNumberSegment(0, 10).OrNaN(),
+		NewNumberConst(1).OrNaN(),
+	} {
+		res := roundTripNumber(assert, n)
+		assert.Equal(n.String(), res.String())
+		assert.True(n.IsSame(res), n.String())
+	}
+
+	nan := roundTripNumber(assert, NewNumberConst(math.NaN()))
+	assert.True(nan.IsNaN().IsTrue())
+
+	i, err := NewIntegerRange(NewNRange(0, 10, false, true))
+	assert.Nil(err)
+	res := roundTripNumber(assert, i)
+	assert.True(res.IsInteger().IsTrue())
+	assert.Equal("[1, 10] int", res.String())
+
+	chain := NewNumberSegment(0, 1)
+	chain.p.next = NewNumberRange(NewNRange(5, 6, true, false)).p
+	res = roundTripNumber(assert, chain)
+	assert.Equal("[0, 1] | [5, 6)", res.String())
+
+	data, err := json.Marshal(NewNumberRange(NewNRange(math.Inf(-1), 0, true, false)))
+	assert.Nil(err)
+	assert.Contains(string(data), `"l":"-inf"`)
+}
+
+func (s *JSONSuite) TestSharedSubjects() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+	ge := abs.GreaterEqual(x)
+	lt := abs.Less(NewNumberSegment(0, 20))
+	assert.True(lt.IsUnknown())
+
+	data, err := MarshalValuesJSON(x, abs, lt)
+	assert.Nil(err)
+
+	values, err := UnmarshalValuesJSON(data)
+	assert.Nil(err)
+	assert.Len(values, 3)
+
+	x2, abs2, lt2 := values[0].(Number), values[1].(Number), values[2].(Boolean)
+	assert.Equal(abs.String(), abs2.String())
+
+	// constraint subject is the decoded x, not a copy
+	assert.Len(abs2.Constraints(), 1)
+	subject := abs2.Constraints()[0].(NumberGreaterEqual).Subject()
+	assert.True(subject == x2)
+	assert.Equal(ge.IsTrue(), abs2.GreaterEqual(x2).IsTrue())
+
+	cmp := lt2.Constraints()[0].(BooleanNumberCompare)
+	lhs, _ := cmp.Operands()
+	assert.True(lhs == abs2)
+	assert.Equal(NRelationLess, cmp.Relation())
+
+	y := NewNumberSegment(0, 5)
+	y.p.constraints = []NumberConstraint{NewNumberNotEqual(x)}
+	data, err = MarshalValuesJSON(x, y)
+	assert.Nil(err)
+	values, err = UnmarshalValuesJSON(data)
+	assert.Nil(err)
+	x2, y2 := values[0].(Number), values[1].(Number)
+	assert.True(y2.Constraints()[0].(NumberNotEqual).Subject() == x2)
+	assert.True(y2.Equal(x2).IsFalse())
+}
+
+func (s *JSONSuite) TestBoolean() {
+	assert := assert.New(s.T())
+
+	b := NewBoolean()
+	nb := b.Not()
+	and := b.And(NewBoolean())
+
+	data, err := MarshalValuesJSON(b, nb, and)
+	assert.Nil(err)
+	values, err := UnmarshalValuesJSON(data)
+	assert.Nil(err)
+
+	b2, nb2, and2 := values[0].(Boolean), values[1].(Boolean), values[2].(Boolean)
+	assert.True(b2.IsUnknown())
+	assert.True(b2.Equal(nb2).IsFalse())
+	assert.Len(and2.Constraints(), 2)
+
+	for _, v := range []BValue{BFalse, BTrue} {
+		data, err := json.Marshal(NewBooleanConst(v, nil))
+		assert.Nil(err)
+		var res Boolean
+		assert.Nil(json.Unmarshal(data, &res))
+		assert.Equal(v, res.p.val)
+	}
+}
+
+func (s *JSONSuite) TestErrors() {
+	assert := assert.New(s.T())
+
+	var n Number
+	var b Boolean
+	for _, data := range []string{
+		`{"roots": [0], "nodes": [{"type": "Boolean", "val": "true"}]}`,
+		`{"roots": [1], "nodes": [{"type": "Number", "const": 1}]}`,
+		`{"roots": [0], "nodes": [{"type": "Number"}]}`,
+		`{"roots": [0], "nodes": [{"type": "Number", "const": "big"}]}`,
+		`{"roots": [0], "nodes": [{"type": "Number", "const": 1, "next": 0, "integer": 0}]}`,
+		`{"roots": [0], "nodes": [{"type": "Number", "const": 1, "constraints": [{"kind": "NumberLess", "subject": 5}]}]}`,
+		`{"roots": [0], "nodes": [{"type": "String"}]}`,
+		`{"roots": [0, 0], "nodes": [{"type": "Number", "const": 1}]}`,
+	} {
+		assert.NotNil(json.Unmarshal([]byte(data), &n), data)
+	}
+	assert.NotNil(json.Unmarshal([]byte(`{"roots": [0], "nodes": [{"type": "Boolean", "val": "maybe"}]}`), &b))
+
+	_, err := json.Marshal(Number{})
+	assert.NotNil(err)
+
+	for _, c := range []struct {
+		data string
+		err  string
+	}{
+		{`{"roots": [0], "nodes": [{"type": "Number", "const": 1}]}`, "node 0: integer: invalid Boolean reference"},
+		{`{"roots": [0], "nodes": [{"type": "Number", "const": 1, "next": 0, "integer": 1}, {"type": "Boolean", "val": "true"}]}`, "node 0: next: cycle"},
+		{`{"roots": [0], "nodes": [{"type": "Number", "const": 1, "next": 2, "integer": 1}, {"type": "Boolean", "val": "true"}, {"type": "Number", "const": 2, "next": 0, "integer": 1}]}`, "node 0: next: cycle"},
+		{`{"roots": [0], "nodes": [{"type": "Number", "range": {"l": 5, "r": 1}, "integer": 1}, {"type": "Boolean", "val": "unknown"}]}`, "node 0: invalid range of Number"},
+		{`{"roots": [0], "nodes": [{"type": "Number", "range": {"l": "nan", "r": 1}, "integer": 1}, {"type": "Boolean", "val": "unknown"}]}`, "node 0: invalid range of Number"},
+	} {
+		_, err := UnmarshalValuesJSON([]byte(c.data))
+		if assert.NotNil(err, c.data) {
+			assert.Contains(err.Error(), c.err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	suite.Run(t, new(JSONSuite))
+}
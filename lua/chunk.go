@@ -0,0 +1,131 @@
+// Package lua is abstract interpreter of Lua 5.3 chunks over virtual types.
+//
+// Supported subset is numeric and boolean code: locals and globals,
+// assignments, if, while, repeat, numeric for, break, do and return.
+// Calls in statement position are allowed and ignored, math.abs,
+// math.floor and math.ceil are evaluated in expressions.
+package lua
+
+import (
+	"fmt"
+	"strings"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/flow"
+)
+
+type Chunk struct {
+	Graph   *flow.Graph
+	assigns []assignment
+	globals map[string]string
+}
+
+func Compile(src string) (*Chunk, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{
+		tokens:    tokens,
+		graph:     flow.NewGraph(),
+		globals:   map[string]string{},
+		allocated: map[string]bool{},
+		reserved:  map[string]bool{},
+	}
+	for _, t := range tokens {
+		if t.kind == tokName {
+			c.reserved[t.text] = true
+		}
+	}
+	c.cur = c.graph.NewBlock()
+
+	c.openScope()
+	if err := c.block(); err != nil {
+		return nil, err
+	}
+	if t := c.peek(); t.kind != tokEOF {
+		return nil, errorf(t.line, "'<eof>' expected near %s", t)
+	}
+	return &Chunk{Graph: c.graph, assigns: c.assigns, globals: c.globals}, nil
+}
+
+// Line is inferred value of variable after assignment at source line.
+// Value is vt.Any when variable may be nil or have values of different
+// types.
+type Line struct {
+	Line      int
+	Name      string
+	Value     vt.BasicValue
+	Reachable bool
+}
+
+func (l Line) String() string {
+	switch {
+	case !l.Reachable:
+		return fmt.Sprintf("%d: %s unreachable", l.Line, l.Name)
+	case l.Value == nil:
+		return fmt.Sprintf("%d: %s = any", l.Line, l.Name)
+	}
+	return fmt.Sprintf("%d: %s = %s", l.Line, l.Name, l.Value)
+}
+
+type Report struct {
+	Lines []Line
+	// Exit is state at the end of chunk with source names of globals
+	Exit *vt.Env
+}
+
+func (r *Report) String() string {
+	lines := make([]string, len(r.Lines))
+	for i, l := range r.Lines {
+		lines[i] = l.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Analyze runs chunk with globals from env, env may be nil.
+func (c *Chunk) Analyze(env *vt.Env) (*Report, error) {
+	if env == nil {
+		env = vt.NewEnv()
+	}
+	for name, vname := range c.globals {
+		// globals reserved by expression syntax are renamed
+		if v, ok := env.Get(name); ok && vname != name {
+			env = env.Fork()
+			env.Set(vname, v)
+		}
+	}
+	res, err := flow.Analyze(c.Graph, env)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Lines: make([]Line, len(c.assigns))}
+	for i, a := range c.assigns {
+		l := Line{Line: a.line, Name: a.name}
+		if state := res.Before(a.block, a.index+1); state != nil {
+			l.Reachable = true
+			l.Value, _ = state.Get(a.vname)
+		}
+		report.Lines[i] = l
+	}
+	if exit := res.Exit(); exit != nil {
+		report.Exit = vt.NewEnv()
+		for name, vname := range c.globals {
+			if v, ok := exit.Get(vname); ok {
+				report.Exit.Set(name, v)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Analyze compiles and runs src with globals from env.
+func Analyze(src string, env *vt.Env) (*Report, error) {
+	c, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return c.Analyze(env)
+}
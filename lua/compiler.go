@@ -0,0 +1,845 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/flow"
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+func errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func errUnsupported(line int, what string) error {
+	return errorf(line, "%s is not supported", what)
+}
+
+// expr is expression translated to vexpr with variables renamed to their
+// unique names, nil has no vexpr form.
+type expr struct {
+	ve    vexpr.Expr
+	isNil bool
+	line  int
+}
+
+// assignment is a source level assignment reported by analysis
+type assignment struct {
+	line  int
+	name  string
+	vname string
+	block *flow.Block
+	index int
+}
+
+// compiler is a single pass compiler of Lua chunk into control flow graph,
+// it resolves scopes while parsing so every local gets unique name.
+type compiler struct {
+	tokens []token
+	pos    int
+
+	graph *flow.Graph
+	cur   *flow.Block
+
+	scopes []map[string]string
+	// globals map source names to names in graph, which differ only for
+	// names reserved by expression syntax
+	globals   map[string]string
+	allocated map[string]bool
+	reserved  map[string]bool
+
+	// exits of enclosing loops for break
+	loops []*flow.Block
+
+	assigns []assignment
+}
+
+var mathFunctions = map[string]string{
+	"abs":   "abs",
+	"floor": "floor",
+	"ceil":  "ceil",
+}
+
+var mathConstants = map[string]float64{
+	"huge":       math.Inf(1),
+	"pi":         math.Pi,
+	"maxinteger": math.MaxInt64,
+	"mininteger": math.MinInt64,
+}
+
+func (c *compiler) peek() token {
+	return c.tokens[c.pos]
+}
+
+func (c *compiler) next() token {
+	t := c.tokens[c.pos]
+	if t.kind != tokEOF {
+		c.pos++
+	}
+	return t
+}
+
+func (c *compiler) check(texts ...string) bool {
+	t := c.peek()
+	if t.kind != tokOp && t.kind != tokKeyword {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiler) accept(texts ...string) (token, bool) {
+	if c.check(texts...) {
+		return c.next(), true
+	}
+	return c.peek(), false
+}
+
+func (c *compiler) expect(text string) error {
+	if t, ok := c.accept(text); !ok {
+		return errorf(t.line, "'%s' expected near %s", text, t)
+	}
+	return nil
+}
+
+func (c *compiler) name() (token, error) {
+	t := c.next()
+	if t.kind != tokName {
+		return t, errorf(t.line, "<name> expected near %s", t)
+	}
+	return t, nil
+}
+
+// ====== Names ======
+
+// fresh returns unique name for hidden or local variable, globals keep
+// source names (unless reserved by expression syntax) so locals never take
+// names occurring in source
+func (c *compiler) fresh(name string) string {
+	vname := name
+	for i := 1; c.allocated[vname] || c.reserved[vname] || vexpr.IsKeyword(vname); i++ {
+		vname = name + "_" + strconv.Itoa(i)
+	}
+	c.allocated[vname] = true
+	return vname
+}
+
+func (c *compiler) openScope() {
+	c.scopes = append(c.scopes, map[string]string{})
+}
+
+func (c *compiler) closeScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *compiler) declare(name string) string {
+	vname := c.fresh(name)
+	c.scopes[len(c.scopes)-1][name] = vname
+	return vname
+}
+
+func (c *compiler) isLocal(name string) bool {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if _, ok := c.scopes[i][name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiler) resolve(name string) string {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if vname, ok := c.scopes[i][name]; ok {
+			return vname
+		}
+	}
+	vname, ok := c.globals[name]
+	if !ok {
+		vname = name
+		if vexpr.IsKeyword(name) {
+			vname = c.fresh(name)
+		}
+		c.globals[name] = vname
+	}
+	return vname
+}
+
+// ====== Emitting ======
+
+func (c *compiler) newBlock() *flow.Block {
+	return c.graph.NewBlock()
+}
+
+// dead starts unreachable block after return or break
+func (c *compiler) dead() {
+	c.cur = c.newBlock()
+}
+
+// operand translates e without attaching source line to errors
+func operand(e expr) vexpr.Expr {
+	if e.isNil {
+		return nilExpr{}
+	}
+	return e.ve
+}
+
+func (c *compiler) vexpr(e expr, truthy bool) vexpr.Expr {
+	if e.isNil {
+		return nilExpr{}
+	}
+	return luaExpr{Expr: e.ve, line: e.line, truthy: truthy}
+}
+
+func (c *compiler) assign(vname string, e expr) {
+	c.cur.Assign(vname, c.vexpr(e, false))
+}
+
+// assignReported emits assignment of source variable name
+func (c *compiler) assignReported(name, vname string, e expr, line int) {
+	c.assign(vname, e)
+	c.assigns = append(c.assigns, assignment{
+		line:  line,
+		name:  name,
+		vname: vname,
+		block: c.cur,
+		index: len(c.cur.Stmts) - 1,
+	})
+}
+
+func (c *compiler) branch(cond expr, then, els *flow.Block) {
+	c.cur.Branch(c.vexpr(cond, true), then, els)
+}
+
+// ====== Statements ======
+
+func (c *compiler) blockEnd() bool {
+	return c.peek().kind == tokEOF || c.check("end", "else", "elseif", "until")
+}
+
+func (c *compiler) block() error {
+	for !c.blockEnd() {
+		if c.check("return") {
+			return c.retstat()
+		}
+		if err := c.statement(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) scopedBlock() error {
+	c.openScope()
+	defer c.closeScope()
+	return c.block()
+}
+
+func (c *compiler) statement() error {
+	t := c.peek()
+	switch {
+	case c.check(";"):
+		c.next()
+		return nil
+	case c.check("if"):
+		return c.ifstat()
+	case c.check("while"):
+		return c.whilestat()
+	case c.check("do"):
+		c.next()
+		if err := c.scopedBlock(); err != nil {
+			return err
+		}
+		return c.expect("end")
+	case c.check("for"):
+		return c.forstat()
+	case c.check("repeat"):
+		return c.repeatstat()
+	case c.check("local"):
+		return c.localstat()
+	case c.check("break"):
+		c.next()
+		if len(c.loops) == 0 {
+			return errorf(t.line, "break outside a loop")
+		}
+		c.cur.Jump(c.loops[len(c.loops)-1])
+		c.dead()
+		return nil
+	case c.check("function"):
+		return errUnsupported(t.line, "function definition")
+	case c.check("goto", "::"):
+		return errUnsupported(t.line, "goto")
+	}
+	return c.exprstat()
+}
+
+func (c *compiler) retstat() error {
+	c.next()
+	if !c.blockEnd() && !c.check(";") {
+		if _, err := c.exprList(); err != nil {
+			return err
+		}
+	}
+	c.accept(";")
+	if !c.blockEnd() {
+		t := c.peek()
+		return errorf(t.line, "'end' expected near %s", t)
+	}
+	// block without successors is exit of the chunk
+	c.dead()
+	return nil
+}
+
+func (c *compiler) ifstat() error {
+	end := c.newBlock()
+	for {
+		// if or elseif
+		c.next()
+		cond, err := c.expr()
+		if err != nil {
+			return err
+		}
+		if err := c.expect("then"); err != nil {
+			return err
+		}
+
+		then, els := c.newBlock(), c.newBlock()
+		c.branch(cond, then, els)
+		c.cur = then
+		if err := c.scopedBlock(); err != nil {
+			return err
+		}
+		c.cur.Jump(end)
+		c.cur = els
+
+		if !c.check("elseif") {
+			break
+		}
+	}
+
+	if _, ok := c.accept("else"); ok {
+		if err := c.scopedBlock(); err != nil {
+			return err
+		}
+	}
+	c.cur.Jump(end)
+	c.cur = end
+	return c.expect("end")
+}
+
+func (c *compiler) loopBody(exit *flow.Block) error {
+	c.loops = append(c.loops, exit)
+	defer func() { c.loops = c.loops[:len(c.loops)-1] }()
+	return c.block()
+}
+
+func (c *compiler) whilestat() error {
+	c.next()
+	head, body, exit := c.newBlock(), c.newBlock(), c.newBlock()
+	c.cur.Jump(head)
+	c.cur = head
+
+	cond, err := c.expr()
+	if err != nil {
+		return err
+	}
+	if err := c.expect("do"); err != nil {
+		return err
+	}
+	c.branch(cond, body, exit)
+
+	c.cur = body
+	c.openScope()
+	if err := c.loopBody(exit); err != nil {
+		return err
+	}
+	c.closeScope()
+	c.cur.Jump(head)
+	c.cur = exit
+	return c.expect("end")
+}
+
+func (c *compiler) repeatstat() error {
+	c.next()
+	body, exit := c.newBlock(), c.newBlock()
+	c.cur.Jump(body)
+	c.cur = body
+
+	// condition sees locals of the body
+	c.openScope()
+	defer c.closeScope()
+	if err := c.loopBody(exit); err != nil {
+		return err
+	}
+	if err := c.expect("until"); err != nil {
+		return err
+	}
+	cond, err := c.expr()
+	if err != nil {
+		return err
+	}
+	c.branch(cond, exit, body)
+	c.cur = exit
+	return nil
+}
+
+// forstat supports numeric for with constant step only
+func (c *compiler) forstat() error {
+	c.next()
+	name, err := c.name()
+	if err != nil {
+		return err
+	}
+	if c.check(",", "in") {
+		return errUnsupported(name.line, "generic for")
+	}
+	if err := c.expect("="); err != nil {
+		return err
+	}
+
+	exprs, err := c.exprList()
+	if err != nil {
+		return err
+	}
+	if len(exprs) < 2 || len(exprs) > 3 {
+		return errorf(name.line, "'for' expects initial value, limit and optional step")
+	}
+	for _, e := range exprs {
+		if e.isNil {
+			return errorf(name.line, "'for' value must be a number")
+		}
+	}
+
+	step := expr{ve: vexpr.Number(1), line: name.line}
+	if len(exprs) == 3 {
+		step = exprs[2]
+	}
+	// step referring to variables fails as undeclared
+	v, err := step.ve.Eval(vt.NewEnv())
+	n, ok := v.(vt.Number)
+	if err != nil || !ok || !n.IsConstant() {
+		return errUnsupported(name.line, "non constant 'for' step")
+	}
+	zero := vt.NewNumberConst(0)
+	cmp := "<="
+	if n.Less(zero).IsTrue() {
+		cmp = ">="
+	} else if !n.Greater(zero).IsTrue() {
+		return errorf(name.line, "'for' step is zero")
+	}
+
+	limit := c.fresh("_limit")
+	c.assign(limit, exprs[1])
+
+	c.openScope()
+	defer c.closeScope()
+	vname := c.declare(name.text)
+	c.assignReported(name.text, vname, exprs[0], name.line)
+
+	head, body, exit := c.newBlock(), c.newBlock(), c.newBlock()
+	c.cur.Jump(head)
+	c.cur = head
+	cond := expr{ve: vexpr.Binary(cmp, vexpr.Var(vname), vexpr.Var(limit)), line: name.line}
+	c.branch(cond, body, exit)
+
+	if err := c.expect("do"); err != nil {
+		return err
+	}
+	c.cur = body
+	if err := c.loopBody(exit); err != nil {
+		return err
+	}
+	c.assign(vname, expr{ve: vexpr.Binary("+", vexpr.Var(vname), step.ve), line: name.line})
+	c.cur.Jump(head)
+	c.cur = exit
+	return c.expect("end")
+}
+
+func (c *compiler) localstat() error {
+	local := c.next()
+	if c.check("function") {
+		return errUnsupported(local.line, "function definition")
+	}
+
+	var names []token
+	for {
+		name, err := c.name()
+		if err != nil {
+			return err
+		}
+		if c.check("<") {
+			return errUnsupported(name.line, "variable attribute")
+		}
+		names = append(names, name)
+		if _, ok := c.accept(","); !ok {
+			break
+		}
+	}
+
+	var exprs []expr
+	if _, ok := c.accept("="); ok {
+		var err error
+		if exprs, err = c.exprList(); err != nil {
+			return err
+		}
+	}
+
+	// values are evaluated before locals come into scope, fresh names
+	// guarantee they are not overwritten by preceding assignments
+	vnames := make([]string, len(names))
+	for i, name := range names {
+		vnames[i] = c.declare(name.text)
+	}
+	for i, name := range names {
+		e := expr{isNil: true}
+		if i < len(exprs) {
+			e = exprs[i]
+		}
+		c.assignReported(name.text, vnames[i], e, name.line)
+	}
+	return nil
+}
+
+func (c *compiler) exprstat() error {
+	t := c.peek()
+	if t.kind == tokName && c.tokens[c.pos+1].kind == tokOp &&
+		(c.tokens[c.pos+1].text == "=" || c.tokens[c.pos+1].text == ",") {
+		return c.assignstat()
+	}
+
+	e, call, err := c.suffixedExpr(true)
+	if err != nil {
+		return err
+	}
+	if !call {
+		return errorf(e.line, "syntax error near %s", c.peek())
+	}
+	return nil
+}
+
+func (c *compiler) assignstat() error {
+	var names []token
+	for {
+		name, err := c.name()
+		if err != nil {
+			return err
+		}
+		if c.check(".", "[", ":") {
+			return errUnsupported(name.line, "table field assignment")
+		}
+		names = append(names, name)
+		if _, ok := c.accept(","); !ok {
+			break
+		}
+	}
+	if err := c.expect("="); err != nil {
+		return err
+	}
+	exprs, err := c.exprList()
+	if err != nil {
+		return err
+	}
+
+	values := make([]expr, len(names))
+	for i := range names {
+		values[i] = expr{isNil: true}
+		if i < len(exprs) {
+			values[i] = exprs[i]
+		}
+	}
+
+	// all values are evaluated before assignment: a, b = b, a
+	if len(names) > 1 {
+		for i, v := range values {
+			if v.isNil {
+				continue
+			}
+			tmp := c.fresh("_tmp")
+			c.assign(tmp, v)
+			values[i] = expr{ve: vexpr.Var(tmp), line: v.line}
+		}
+	}
+
+	for i, name := range names {
+		c.assignReported(name.text, c.resolve(name.text), values[i], name.line)
+	}
+	return nil
+}
+
+// ====== Expressions ======
+
+func (c *compiler) exprList() ([]expr, error) {
+	var exprs []expr
+	for {
+		e, err := c.expr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+		if _, ok := c.accept(","); !ok {
+			return exprs, nil
+		}
+	}
+}
+
+func (c *compiler) expr() (expr, error) {
+	return c.binary(0)
+}
+
+// binary operators by priority from lowest, nil means operator of this
+// level is not supported
+var binaryLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"<", ">", "<=", ">=", "~=", "=="},
+	{"|", "~", "&", "<<", ">>", ".."},
+	{"+", "-"},
+	{"*", "/", "//", "%"},
+}
+
+var unsupportedOps = map[string]string{
+	"|": "bitwise operator", "~": "bitwise operator", "&": "bitwise operator",
+	"<<": "bitwise operator", ">>": "bitwise operator",
+	"..": "string concatenation", "%": "modulo operator",
+	"#": "length operator",
+}
+
+func binaryOperand(e expr, op token) error {
+	if e.isNil {
+		return errUnsupported(op.line, "nil operand of '"+op.text+"'")
+	}
+	return nil
+}
+
+// logic translates Lua and/or, which operate on values of any type
+func logic(lhs expr, op token, rhs expr) expr {
+	l, r := operand(lhs), operand(rhs)
+	if and, ok := l.(*logicExpr); ok && and.op == "and" && op.text == "or" {
+		return expr{ve: &ternaryExpr{cond: and.lhs, then: and.rhs, els: r}, line: lhs.line}
+	}
+	return expr{ve: &logicExpr{op: op.text, lhs: l, rhs: r}, line: lhs.line}
+}
+
+// nilEqual translates comparison with nil, which is known unless the other
+// operand may be nil
+func nilEqual(lhs expr, op token, rhs expr) expr {
+	negate := op.text == "~="
+	if lhs.isNil && rhs.isNil {
+		return expr{ve: vexpr.Bool(!negate), line: lhs.line}
+	}
+	if lhs.isNil {
+		lhs = rhs
+	}
+	return expr{ve: &nilEqualExpr{operand: lhs.ve, negate: negate}, line: lhs.line}
+}
+
+func (c *compiler) binary(level int) (expr, error) {
+	if level == len(binaryLevels) {
+		return c.unary()
+	}
+
+	lhs, err := c.binary(level + 1)
+	if err != nil {
+		return expr{}, err
+	}
+	for {
+		op, ok := c.accept(binaryLevels[level]...)
+		if !ok {
+			return lhs, nil
+		}
+		if what, ok := unsupportedOps[op.text]; ok {
+			return expr{}, errUnsupported(op.line, what)
+		}
+		rhs, err := c.binary(level + 1)
+		if err != nil {
+			return expr{}, err
+		}
+		if op.text == "and" || op.text == "or" {
+			lhs = logic(lhs, op, rhs)
+			continue
+		}
+		if (op.text == "==" || op.text == "~=") && (lhs.isNil || rhs.isNil) {
+			lhs = nilEqual(lhs, op, rhs)
+			continue
+		}
+		if err := binaryOperand(lhs, op); err != nil {
+			return expr{}, err
+		}
+		if err := binaryOperand(rhs, op); err != nil {
+			return expr{}, err
+		}
+		lhs = expr{ve: vexpr.Binary(op.text, lhs.ve, rhs.ve), line: lhs.line}
+		if op.text == "/" {
+			lhs.ve = floatExpr{lhs.ve}
+		}
+	}
+}
+
+func (c *compiler) unary() (expr, error) {
+	op, ok := c.accept("not", "-", "#", "~")
+	if !ok {
+		return c.pow()
+	}
+	if what, ok := unsupportedOps[op.text]; ok {
+		return expr{}, errUnsupported(op.line, what)
+	}
+	operand, err := c.unary()
+	if err != nil {
+		return expr{}, err
+	}
+	if operand.isNil {
+		// not nil is true, -nil is runtime error
+		if op.text == "not" {
+			return expr{ve: vexpr.Bool(true), line: op.line}, nil
+		}
+		return expr{}, errUnsupported(op.line, "nil operand of '-'")
+	}
+	return expr{ve: vexpr.Unary(op.text, operand.ve), line: op.line}, nil
+}
+
+func (c *compiler) pow() (expr, error) {
+	base, _, err := c.suffixedExpr(false)
+	if err != nil {
+		return expr{}, err
+	}
+	op, ok := c.accept("^")
+	if !ok {
+		return base, nil
+	}
+	exp, err := c.unary()
+	if err != nil {
+		return expr{}, err
+	}
+	if err := binaryOperand(base, op); err != nil {
+		return expr{}, err
+	}
+	if err := binaryOperand(exp, op); err != nil {
+		return expr{}, err
+	}
+	return expr{ve: floatExpr{vexpr.Binary(op.text, base.ve, exp.ve)}, line: base.line}, nil
+}
+
+func parseNumber(text string) (float64, error) {
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(lower, "0x") {
+		if !strings.ContainsAny(lower, ".p") {
+			v, err := strconv.ParseUint(lower[2:], 16, 64)
+			return float64(int64(v)), err
+		}
+		if !strings.Contains(lower, "p") {
+			lower += "p0"
+		}
+		return strconv.ParseFloat(lower, 64)
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// suffixedExpr parses primary expression with calls, arbitrary calls
+// are allowed only in statement position as their results are unknown
+func (c *compiler) suffixedExpr(stmt bool) (expr, bool, error) {
+	t := c.next()
+	switch {
+	case t.kind == tokNumber:
+		v, err := parseNumber(t.text)
+		if err != nil {
+			return expr{}, false, errorf(t.line, "malformed number near %s", t)
+		}
+		return expr{ve: vexpr.Number(v), line: t.line}, false, nil
+	case t.kind == tokString:
+		return expr{}, false, errUnsupported(t.line, "string")
+	case t.kind == tokKeyword:
+		switch t.text {
+		case "nil":
+			return expr{isNil: true, line: t.line}, false, nil
+		case "true", "false":
+			return expr{ve: vexpr.Bool(t.text == "true"), line: t.line}, false, nil
+		case "function":
+			return expr{}, false, errUnsupported(t.line, "function definition")
+		}
+	case t.kind == tokOp:
+		switch t.text {
+		case "(":
+			e, err := c.expr()
+			if err != nil {
+				return expr{}, false, err
+			}
+			if err := c.expect(")"); err != nil {
+				return expr{}, false, err
+			}
+			return e, false, nil
+		case "{":
+			return expr{}, false, errUnsupported(t.line, "table constructor")
+		case "...":
+			return expr{}, false, errUnsupported(t.line, "vararg")
+		}
+	case t.kind == tokName:
+		return c.nameExpr(t, stmt)
+	}
+	return expr{}, false, errorf(t.line, "unexpected symbol near %s", t)
+}
+
+func (c *compiler) nameExpr(name token, stmt bool) (expr, bool, error) {
+	fname := name.text
+	if name.text == "math" && !c.isLocal("math") && c.check(".") {
+		c.next()
+		field, err := c.name()
+		if err != nil {
+			return expr{}, false, err
+		}
+		if v, ok := mathConstants[field.text]; ok {
+			return expr{ve: vexpr.Number(v), line: name.line}, false, nil
+		}
+		fname = "math." + field.text
+	}
+
+	if !c.check("(") {
+		if c.check(".", "[", ":", "{") || c.peek().kind == tokString {
+			return expr{}, false, errUnsupported(name.line, "table access")
+		}
+		if fname != name.text {
+			return expr{}, false, errUnsupported(name.line, fname)
+		}
+		return expr{ve: vexpr.Var(c.resolve(name.text)), line: name.line}, false, nil
+	}
+
+	c.next()
+	var args []expr
+	if !c.check(")") {
+		var err error
+		if args, err = c.exprList(); err != nil {
+			return expr{}, false, err
+		}
+	}
+	if err := c.expect(")"); err != nil {
+		return expr{}, false, err
+	}
+	if c.check("(", ".", "[", ":") {
+		return expr{}, false, errUnsupported(name.line, "call of call result")
+	}
+
+	if stmt {
+		// result of statement call is dropped, arguments have no effect
+		// on locals
+		return expr{line: name.line}, true, nil
+	}
+
+	vname, ok := mathFunctions[strings.TrimPrefix(fname, "math.")]
+	if !ok || fname == strings.TrimPrefix(fname, "math.") {
+		return expr{}, false, errUnsupported(name.line, "call of "+fname)
+	}
+	ves := make([]vexpr.Expr, len(args))
+	for i, arg := range args {
+		if arg.isNil {
+			return expr{}, false, errUnsupported(name.line, "nil argument of "+fname)
+		}
+		ves[i] = arg.ve
+	}
+	return expr{ve: vexpr.Call(vname, ves...), line: name.line}, true, nil
+}
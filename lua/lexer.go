@@ -0,0 +1,239 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokName
+	tokKeyword
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "<eof>"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true, "or": true,
+	"repeat": true, "return": true, "then": true, "true": true, "until": true,
+	"while": true,
+}
+
+// longest first
+var operators = []string{
+	"...", "==", "~=", "<=", ">=", "//", "..", "::", "<<", ">>",
+	"+", "-", "*", "/", "%", "^", "#", "&", "~", "|", "<", ">", "=",
+	"(", ")", "{", "}", "[", "]", ";", ":", ",", ".",
+}
+
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) peekRune(offset int) rune {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(l.src[l.pos:]), prefix)
+}
+
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+		}
+		l.pos++
+	}
+}
+
+// longBracket returns level of long bracket [==[ at current position or -1
+func (l *lexer) longBracket() int {
+	if l.peekRune(0) != '[' {
+		return -1
+	}
+	level := 0
+	for l.peekRune(level+1) == '=' {
+		level++
+	}
+	if l.peekRune(level+1) != '[' {
+		return -1
+	}
+	return level
+}
+
+func (l *lexer) skipLong(level int) error {
+	closing := "]" + strings.Repeat("=", level) + "]"
+	l.advance(level + 2)
+	for l.pos < len(l.src) {
+		if l.hasPrefix(closing) {
+			l.advance(len(closing))
+			return nil
+		}
+		l.advance(1)
+	}
+	return l.errorf("unfinished long string or comment")
+}
+
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		switch {
+		case unicode.IsSpace(r):
+			l.advance(1)
+		case l.hasPrefix("--"):
+			l.advance(2)
+			if level := l.longBracket(); level >= 0 {
+				if err := l.skipLong(level); err != nil {
+					return err
+				}
+				continue
+			}
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isNameRune(r rune, first bool) bool {
+	if r == '_' || (r < unicode.MaxASCII && unicode.IsLetter(r)) {
+		return true
+	}
+	return !first && r >= '0' && r <= '9'
+}
+
+func (l *lexer) number() token {
+	start, line := l.pos, l.line
+	hex := l.hasPrefix("0x") || l.hasPrefix("0X")
+	if hex {
+		l.advance(2)
+	}
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		exp := (!hex && (r == 'e' || r == 'E')) || (hex && (r == 'p' || r == 'P'))
+		if exp && (l.peekRune(1) == '+' || l.peekRune(1) == '-') {
+			l.advance(2)
+		} else if r == '.' || isNameRune(r, false) {
+			l.advance(1)
+		} else {
+			break
+		}
+	}
+	return token{kind: tokNumber, text: string(l.src[start:l.pos]), line: line}
+}
+
+func (l *lexer) str() (token, error) {
+	start, line := l.pos, l.line
+	if level := l.longBracket(); level >= 0 {
+		if err := l.skipLong(level); err != nil {
+			return token{}, err
+		}
+		return token{kind: tokString, text: string(l.src[start:l.pos]), line: line}, nil
+	}
+
+	quote := l.src[l.pos]
+	l.advance(1)
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return token{}, l.errorf("unfinished string")
+		}
+		r := l.src[l.pos]
+		if r == '\\' {
+			l.advance(2)
+			continue
+		}
+		l.advance(1)
+		if r == quote {
+			return token{kind: tokString, text: string(l.src[start:l.pos]), line: line}, nil
+		}
+	}
+}
+
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: []rune(src), line: 1}
+	var tokens []token
+
+	// shebang line
+	if l.hasPrefix("#") {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.advance(1)
+		}
+	}
+
+	for {
+		if err := l.skipSpaceAndComments(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.src) {
+			return append(tokens, token{kind: tokEOF, line: l.line}), nil
+		}
+
+		r := l.src[l.pos]
+		switch {
+		case r >= '0' && r <= '9' || (r == '.' && l.peekRune(1) >= '0' && l.peekRune(1) <= '9'):
+			tokens = append(tokens, l.number())
+		case isNameRune(r, true):
+			start := l.pos
+			for l.pos < len(l.src) && isNameRune(l.src[l.pos], false) {
+				l.advance(1)
+			}
+			text := string(l.src[start:l.pos])
+			kind := tokName
+			if keywords[text] {
+				kind = tokKeyword
+			}
+			tokens = append(tokens, token{kind: kind, text: text, line: l.line})
+		case r == '"' || r == '\'' || l.longBracket() >= 0:
+			t, err := l.str()
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if l.hasPrefix(candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, l.errorf("unexpected symbol %q", r)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, line: l.line})
+			l.advance(len(op))
+		}
+	}
+}
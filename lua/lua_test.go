@@ -0,0 +1,341 @@
+package lua
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+type LuaSuite struct {
+	suite.Suite
+}
+
+func (s *LuaSuite) analyze(src string) string {
+	report, err := Analyze(src, nil)
+	if !assert.New(s.T()).Nil(err) {
+		return ""
+	}
+	return report.String()
+}
+
+func (s *LuaSuite) TestLocals() {
+	assert := assert.New(s.T())
+
+	assert.Equal(strings.Join([]string{
+		"1: a = 5",
+		"1: b = true",
+		"2: c = 2.5",
+		"3: d = nil",
+		"4: a = 0.5",
+		"5: e = 2",
+		"6: f = 0",
+	}, "\n"), s.analyze(`local a, b = 5, 1 < 2 -- comment
+local c = a / 2
+local d
+a = a ^ -1 * 2.5 --[[ long
+comment ]] local e = 7 // 3
+local f = math.abs(-math.floor(e * 10.5) + 21) -- 0
+`))
+}
+
+func (s *LuaSuite) TestScopes() {
+	assert := assert.New(s.T())
+
+	assert.Equal(strings.Join([]string{
+		"1: x = 1",
+		"3: x = 2",
+		"4: y = 3",
+		"6: z = 1",
+		"7: x = 2",
+		"7: y = 1",
+	}, "\n"), s.analyze(`local x = 1
+do
+	local x = 2
+	y = x + 1
+end
+local z = x
+x, y = x + 1, x
+`))
+}
+
+func (s *LuaSuite) TestIf() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "n in [-10, 10] int"))
+
+	report, err := Analyze(`
+local sign
+if n < 0 then
+	sign = -1
+elseif n == 0 then
+	sign = 0
+else
+	sign = 1
+end
+local m = n + sign
+if m > 11 then
+	m = 0
+end
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: sign = nil",
+		"4: sign = -1",
+		"6: sign = 0",
+		"8: sign = 1",
+		"10: m = [-11, 11] int",
+		"12: m unreachable",
+	}, "\n"), report.String())
+}
+
+func (s *LuaSuite) TestLoops() {
+	assert := assert.New(s.T())
+
+	assert.Equal(strings.Join([]string{
+		"1: s = 0",
+		"2: i = 1",
+		"3: s = [1, inf] int",
+		"5: k = 0",
+		"7: k = [1, inf] int",
+		"10: j = 10",
+		"11: k = [102, inf] int",
+		"14: done = true",
+	}, "\n"), s.analyze(`local s = 0
+for i = 1, 10 do
+	s = s + i
+end
+local k = 0
+while true do
+	k = k + 1
+	if k >= 100 then break end
+end
+for j = 10, 1, -1 do
+	k = k + 2
+end
+repeat
+	done = k > 1
+until done
+`))
+
+	// constant step may be any expression
+	assert.Equal(strings.Join([]string{
+		"1: n = 0",
+		"2: i = 1",
+		"3: n = [1, 5] int",
+	}, "\n"), s.analyze(`local n = 0
+for i = 1, 5, true and 2 or 1 do
+	n = i
+end
+`))
+}
+
+func (s *LuaSuite) TestReturn() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [0, 10]"))
+
+	report, err := Analyze(`
+if x > 5 then
+	return x
+end
+y = x * 2
+return
+`, env)
+	assert.Nil(err)
+
+	assert.Equal("5: y = [0, 10]", report.String())
+
+	// y is not assigned on the first return
+	assert.Equal([]string{"x", "y"}, report.Exit.Names())
+	y, _ := report.Exit.Get("y")
+	assert.Equal(vt.Any{}, y)
+	x, _ := report.Exit.Number("x")
+	assert.Equal("[0, 10]", x.String())
+}
+
+func (s *LuaSuite) TestJoinUndefined() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [0, 10]"))
+
+	report, err := Analyze(`
+local y
+if x > 5 then
+	y = 1
+	z = 2
+end
+local w = y
+local v = z
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: y = nil",
+		"4: y = 1",
+		"5: z = 2",
+		"7: w = any",
+		"8: v = any",
+	}, "\n"), report.String())
+}
+
+func (s *LuaSuite) TestReservedGlobals() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [0, 10]"))
+	env.Set("inf", vt.NewNumberConst(1))
+
+	report, err := Analyze(`
+local y = inf + 1
+inf = 3
+local z = inf + x
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: y = 2",
+		"3: inf = 3",
+		"4: z = [3, 13]",
+	}, "\n"), report.String())
+
+	inf, _ := report.Exit.Number("inf")
+	assert.Equal("3", inf.String())
+	_, ok := report.Exit.Get("inf_1")
+	assert.False(ok)
+}
+
+func (s *LuaSuite) TestAndOr() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [-5, 5]"))
+	assert.Nil(vexpr.Declare(env, "b: bool"))
+
+	report, err := Analyze(`
+local y = x > 0 and 1 or 2
+local z = (x > 0 and 1 or 2) + 10
+local w = x > 0 and 7
+local v = nil or x
+local u = b and x > 2
+local s = x and 3 or 4
+local q = false and x
+if x > 1 and x < 3 then
+	local p = x
+end
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: y = [1, 2] int",
+		"3: z = [11, 12] int",
+		"4: w = any",
+		"5: v = [-5, 5]",
+		"6: u = unknown",
+		"7: s = 3",
+		"8: q = false",
+		"10: p = (1, 3)",
+	}, "\n"), report.String())
+
+	// Lua operators are operands of arithmetic without hidden variables
+	c, err := Compile("local z = (x > 0 and 1 or 2) + 10")
+	assert.Nil(err)
+	assert.Equal("z_1 = (((x > 0) and 1 or 2) + 10)", c.Graph.Entry().Stmts[0].String())
+}
+
+func (s *LuaSuite) TestNilEqual() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [-5, 5]"))
+
+	report, err := Analyze(`
+local a = x == nil
+local b = nil ~= x
+local c = nil == nil
+local y
+if x > 0 then
+	y = 1
+end
+local d = y == nil
+if y ~= nil then
+	local e = 1
+end
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: a = false",
+		"3: b = true",
+		"4: c = true",
+		"5: y = nil",
+		"7: y = 1",
+		"9: d = unknown",
+		"11: e = 1",
+	}, "\n"), report.String())
+}
+
+func (s *LuaSuite) TestFloatOperators() {
+	assert := assert.New(s.T())
+
+	env := vt.NewEnv()
+	assert.Nil(vexpr.Declare(env, "x in [0, 10] int"))
+
+	// / and ^ give floats, // keeps integers
+	report, err := Analyze(`
+a = 3 / 3
+b = x / 1
+c = x // 1
+d = 2 ^ 2
+`, env)
+	assert.Nil(err)
+	assert.Equal(strings.Join([]string{
+		"2: a = 1",
+		"3: b = [0, 10]",
+		"4: c = [0, 10] int",
+		"5: d = 4",
+	}, "\n"), report.String())
+
+	a, _ := report.Exit.Number("a")
+	assert.Equal("1.0", a.ToString().Value())
+	d, _ := report.Exit.Number("d")
+	assert.Equal("4.0", d.ToString().Value())
+}
+
+func (s *LuaSuite) TestErrors() {
+	assert := assert.New(s.T())
+
+	for _, src := range []string{
+		`local s = "str"`,
+		`local t = {}`,
+		`function f() end`,
+		`local x = 1 % 2`,
+		`local x = #t`,
+		`for k, v in pairs(t) do end`,
+		`t.x = 1`,
+		`local x = 1 +`,
+		`if x then`,
+		`break`,
+		`local x = nil + 1`,
+		`local x = print(1)`,
+		`for i = 1, 10, n do end`,
+		`return 1 local x = 2`,
+	} {
+		_, err := Compile(src)
+		assert.NotNil(err, src)
+	}
+
+	_, err := Analyze("local y = x + 1", nil)
+	assert.NotNil(err)
+
+	_, err = Analyze("local x = nil\nlocal y = x + 1", nil)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "line 2")
+	}
+}
+
+func TestLua(t *testing.T) {
+	suite.Run(t, new(LuaSuite))
+}
@@ -0,0 +1,227 @@
+package lua
+
+import (
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+	"github.com/alexeyknyshev/virtual-reasoning-types/vexpr"
+)
+
+// Nil is Lua nil, it is stored in environment as any other value.
+type Nil struct{}
+
+func (_ Nil) TypeName() string {
+	return "nil"
+}
+
+func (_ Nil) IsValid() bool {
+	return true
+}
+
+func (_ Nil) IsConstant() bool {
+	return true
+}
+
+func (_ Nil) ToBoolean() vt.Boolean {
+	return vt.NewBooleanConst(vt.BFalse, nil)
+}
+
+func (_ Nil) String() string {
+	return "nil"
+}
+
+type nilExpr struct{}
+
+func (_ nilExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	return Nil{}, nil
+}
+
+func (_ nilExpr) String() string {
+	return "nil"
+}
+
+// luaExpr attaches source line to errors of wrapped expression and converts
+// result to Boolean when used as condition (Lua truthiness).
+type luaExpr struct {
+	vexpr.Expr
+	line   int
+	truthy bool
+}
+
+func (e luaExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	v, err := e.Expr.Eval(env)
+	if err != nil {
+		return nil, errorf(e.line, "%s", err.Error())
+	}
+	if e.truthy {
+		if _, ok := v.(vt.Boolean); !ok {
+			return v.ToBoolean(), nil
+		}
+	}
+	return v, nil
+}
+
+// floatExpr is Lua / or ^, which give floats even for integer operands
+type floatExpr struct {
+	vexpr.Expr
+}
+
+func (e floatExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	v, err := e.Expr.Eval(env)
+	if n, ok := v.(vt.Number); ok && err == nil {
+		return n.ToFloat(), nil
+	}
+	return v, err
+}
+
+// truthy is Lua truthiness of v: only nil and false are false
+func truthy(v vt.BasicValue) vt.Boolean {
+	if b, ok := v.(vt.Boolean); ok {
+		return b
+	}
+	return v.ToBoolean()
+}
+
+// selectValue is then when cond holds and els otherwise, vt.Any when
+// they have different types
+func selectValue(cond vt.Boolean, then, els vt.BasicValue) vt.BasicValue {
+	if cond.IsTrue() {
+		return then
+	} else if cond.IsFalse() {
+		return els
+	}
+
+	switch then := then.(type) {
+	case vt.Number:
+		if els, ok := els.(vt.Number); ok {
+			return vt.Select(cond, then, els)
+		}
+	case vt.Boolean:
+		if els, ok := els.(vt.Boolean); ok {
+			return cond.And(then).Or(cond.Not().And(els))
+		}
+	case Nil:
+		if _, ok := els.(Nil); ok {
+			return then
+		}
+	}
+	return vt.Any{}
+}
+
+// nilEqualExpr is Lua comparison operand == nil (or ~= nil when negate).
+type nilEqualExpr struct {
+	operand vexpr.Expr
+	negate  bool
+}
+
+func (e *nilEqualExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	v, err := e.operand.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var eq vt.Boolean
+	switch v.(type) {
+	case Nil:
+		eq = vt.NewBooleanConst(vt.BTrue, nil)
+	case vt.Any:
+		eq = vt.NewBoolean()
+	default:
+		eq = vt.NewBooleanConst(vt.BFalse, nil)
+	}
+	if e.negate {
+		return eq.Not(), nil
+	}
+	return eq, nil
+}
+
+func (e *nilEqualExpr) String() string {
+	if e.negate {
+		return "(" + e.operand.String() + " ~= nil)"
+	}
+	return "(" + e.operand.String() + " == nil)"
+}
+
+// logicExpr is Lua and/or on operands of any type: the result is lhs when
+// it decides the result and rhs otherwise, rhs is evaluated only if needed.
+type logicExpr struct {
+	op  string
+	lhs vexpr.Expr
+	rhs vexpr.Expr
+}
+
+func (e *logicExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	lhs, err := e.lhs.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// rhs is the result when it holds
+	cond := truthy(lhs)
+	if e.op == "or" {
+		cond = cond.Not()
+	}
+	if cond.IsFalse() {
+		return lhs, nil
+	}
+
+	rhs, err := e.rhs.Eval(env)
+	if err != nil || cond.IsTrue() {
+		return rhs, err
+	}
+
+	lb, lok := lhs.(vt.Boolean)
+	rb, rok := rhs.(vt.Boolean)
+	if lok && rok {
+		if e.op == "and" {
+			return lb.And(rb), nil
+		}
+		return lb.Or(rb), nil
+	}
+	return selectValue(cond, rhs, lhs), nil
+}
+
+func (e *logicExpr) String() string {
+	return "(" + e.lhs.String() + " " + e.op + " " + e.rhs.String() + ")"
+}
+
+// ternaryExpr is Lua idiom cond and then or els, which is then when both
+// cond and then hold and els otherwise.
+type ternaryExpr struct {
+	cond vexpr.Expr
+	then vexpr.Expr
+	els  vexpr.Expr
+}
+
+func (e *ternaryExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	c, err := e.cond.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := truthy(c)
+	var then vt.BasicValue
+	if !cond.IsFalse() {
+		if then, err = e.then.Eval(env); err != nil {
+			return nil, err
+		}
+		cond = cond.And(truthy(then))
+		if cond.IsTrue() {
+			return then, nil
+		}
+	}
+
+	els, err := e.els.Eval(env)
+	if err != nil || cond.IsFalse() {
+		return els, err
+	}
+
+	if eb, ok := els.(vt.Boolean); ok {
+		if _, ok := then.(vt.Boolean); ok {
+			return cond.Or(eb), nil
+		}
+	}
+	return selectValue(cond, then, els), nil
+}
+
+func (e *ternaryExpr) String() string {
+	return "(" + e.cond.String() + " and " + e.then.String() + " or " + e.els.String() + ")"
+}
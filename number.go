@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -30,6 +32,14 @@ const (
 	NEdgeRight NEdge = 1
 )
 
+type NRelation int
+
+const (
+	NRelationLess NRelation = iota
+	NRelationLessEqual
+	NRelationEqual
+)
+
 type NRange struct {
 	lVal float64
 	rVal float64
@@ -55,6 +65,28 @@ func minmax(s []float64) (min float64, max float64) {
 	return min, max
 }
 
+// sameValue is a == b telling -0 and +0 apart
+func sameValue(a, b float64) bool {
+	return a == b && math.Signbit(a) == math.Signbit(b)
+}
+
+// zeroCmp compares a and b ordering -0 before +0, so a range with +0 right
+// edge holds both zeros and a range with +0 left edge holds only +0
+func zeroCmp(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	case a == 0 && b == 0 && math.Signbit(a) != math.Signbit(b):
+		if math.Signbit(a) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
 func newRangeSegment(l, r float64) *NRange {
 	return &NRange{
 		lVal: l,
@@ -77,7 +109,7 @@ func floor(v float64, arithmeticallyCorrect bool) float64 {
 }
 
 func (r *NRange) IsSame(o *NRange) bool {
-	return r.lVal == o.lVal && r.rVal == o.rVal &&
+	return sameValue(r.lVal, o.lVal) && sameValue(r.rVal, o.rVal) &&
 		r.lIncluding == o.lIncluding && r.rIncluding == o.rIncluding
 }
 
@@ -262,18 +294,18 @@ func (r *NRange) IsInf(sign int) Boolean {
 		if math.IsInf(r.lVal, sign) {
 			return NewBoolean()
 		}
-		return NewBooleanConst(BFalse, nil)
+		return _false
 	} else if sign > 0 {
 		if math.IsInf(r.rVal, sign) {
 			return NewBoolean()
 		}
-		return NewBooleanConst(BFalse, nil)
+		return _false
 	}
 
 	if math.IsInf(r.lVal, -1) || math.IsInf(r.rVal, 1) {
 		return NewBoolean()
 	}
-	return NewBooleanConst(BFalse, nil)
+	return _false
 }
 
 func (r *NRange) ToIntegerRange() *NRange {
@@ -315,7 +347,7 @@ func (r *NRange) ToIntegerRange() *NRange {
 
 func (r *NRange) Extend(o *NRange, leftExtend bool) *NRange {
 	if leftExtend {
-		if r.lVal > o.lVal {
+		if cmp := zeroCmp(r.lVal, o.lVal); cmp > 0 {
 			return &NRange{
 				lVal: o.lVal,
 				rVal: r.rVal,
@@ -323,7 +355,7 @@ func (r *NRange) Extend(o *NRange, leftExtend bool) *NRange {
 				lIncluding: o.lIncluding,
 				rIncluding: r.rIncluding,
 			}
-		} else if r.lVal == o.lVal && !r.lIncluding && o.lIncluding {
+		} else if cmp == 0 && !r.lIncluding && o.lIncluding {
 			return &NRange{
 				lVal: r.lVal,
 				rVal: r.rVal,
@@ -333,7 +365,7 @@ func (r *NRange) Extend(o *NRange, leftExtend bool) *NRange {
 			}
 		}
 	} else {
-		if r.rVal < o.rVal {
+		if cmp := zeroCmp(r.rVal, o.rVal); cmp < 0 {
 			return &NRange{
 				lVal: r.lVal,
 				rVal: o.rVal,
@@ -341,7 +373,7 @@ func (r *NRange) Extend(o *NRange, leftExtend bool) *NRange {
 				lIncluding: r.lIncluding,
 				rIncluding: o.rIncluding,
 			}
-		} else if r.rVal == o.rVal && !r.rIncluding && o.rIncluding {
+		} else if cmp == 0 && !r.rIncluding && o.rIncluding {
 			return &NRange{
 				lVal: r.lVal,
 				rVal: r.rVal,
@@ -354,6 +386,20 @@ func (r *NRange) Extend(o *NRange, leftExtend bool) *NRange {
 	return r
 }
 
+// extremum returns range of max (min) of values from r and o
+func (r *NRange) extremum(o *NRange, max bool) *NRange {
+	res := &NRange{}
+	pick := func(left bool) (float64, bool) {
+		if cmp, _ := edge_cmp(r, o, left, left); (cmp >= 0) == max {
+			return edge_pick(r, left)
+		}
+		return edge_pick(o, left)
+	}
+	res.lVal, res.lIncluding = pick(true)
+	res.rVal, res.rIncluding = pick(false)
+	return res
+}
+
 func edge_pick(r *NRange, left bool) (float64, bool) {
 	if left {
 		return r.lVal, r.lIncluding
@@ -412,20 +458,30 @@ func (r *NRange) Order(o *NRange) (*NRange, *NRange) {
 
 func (r *NRange) Less(o *NRange) Boolean {
 	if r.rVal < o.lVal {
-		return NewBooleanConst(BTrue, nil)
+		return _true
 	} else if r.rVal == o.lVal {
 		if r.rIncluding && o.lIncluding {
 			return NewBoolean()
 		}
-		return NewBooleanConst(BTrue, nil)
+		return _true
 	} else if o.rVal <= r.lVal {
-		return NewBooleanConst(BFalse, nil)
+		return _false
 	}
 
 	return NewBoolean()
 }
 
+// rangeComputer is implemented by operations computing result range by
+// themselves instead of the generic edge to edge computation
+type rangeComputer interface {
+	computeRange(x, y *NRange) []*NRange
+}
+
 func (r *NRange) ArithmeticOperation(o *NRange, op ArithmeticOperationBinary) ([]*NRange, error) {
+	if rc, ok := op.(rangeComputer); ok {
+		return rc.computeRange(r, o), nil
+	}
+
 	if !op.IsClosedField() {
 		lSplit, rSplit := o.Split(0)
 		if lSplit != nil && rSplit != nil {
@@ -433,8 +489,6 @@ func (r *NRange) ArithmeticOperation(o *NRange, op ArithmeticOperationBinary) ([
 			if err != nil {
 				return nil, err
 			}
-			fmt.Printf("%+v / %+v = %+v\n", r, lSplit, lRes[0])
-			fmt.Printf("left done\n")
 			rRes, err := r.ArithmeticOperation(rSplit, op)
 			if err != nil {
 				return nil, err
@@ -527,7 +581,7 @@ func (r *NRange) ArithmeticOperation(o *NRange, op ArithmeticOperationBinary) ([
 }
 
 func (r *NRange) IsConstant() bool {
-	return r.lVal == r.rVal && r.lIncluding && r.lIncluding
+	return sameValue(r.lVal, r.rVal) && r.lIncluding && r.lIncluding
 }
 
 func (r *NRange) IsNaN() bool {
@@ -537,26 +591,139 @@ func (r *NRange) IsNaN() bool {
 	return math.IsNaN(r.lVal) || math.IsNaN(r.rVal)
 }
 
+// holdsZero tells whether r holds zero z of the given sign, see zeroCmp
+func (r *NRange) holdsZero(z float64) bool {
+	l, rr := zeroCmp(r.lVal, z), zeroCmp(z, r.rVal)
+	return (l < 0 || l == 0 && r.lIncluding) && (rr < 0 || rr == 0 && r.rIncluding)
+}
+
+// Sign returns possible signs of values from r, zeros keep their sign
 func (r *NRange) Sign() []float64 {
 	if r.IsNaN() {
 		return nil
 	}
-	if r.lVal > 0 {
-		return []float64{1}
-	} else if r.lVal == 0 {
-		if r.lIncluding {
-			return []float64{0, 1}
+
+	var signs []float64
+	if r.lVal < 0 {
+		signs = append(signs, -1)
+	}
+	for _, z := range []float64{math.Copysign(0, -1), 0} {
+		if r.holdsZero(z) {
+			signs = append(signs, z)
 		}
-		return []float64{1}
-	} else if r.rVal < 0 {
-		return []float64{-1}
-	} else if r.rVal == 0 {
-		if r.rIncluding {
-			return []float64{-1, 0}
+	}
+	if r.rVal > 0 {
+		signs = append(signs, 1)
+	}
+	return signs
+}
+
+// splitZero splits r into parts of values with negative and positive sign
+// bit, -0 belongs to the former and +0 to the latter
+func (r *NRange) splitZero() (*NRange, *NRange) {
+	negZero := math.Copysign(0, -1)
+
+	var neg, pos *NRange
+	if r.lVal < 0 || r.holdsZero(negZero) {
+		neg = r
+		if zeroCmp(r.rVal, negZero) > 0 {
+			neg = &NRange{
+				lVal: r.lVal,
+				rVal: negZero,
+
+				lIncluding: r.lIncluding,
+				rIncluding: true,
+			}
 		}
-		return []float64{-1}
 	}
-	return []float64{-1, 0, 1}
+	if r.rVal > 0 || r.holdsZero(0) {
+		pos = r
+		if zeroCmp(r.lVal, 0) < 0 {
+			pos = &NRange{
+				lVal: 0,
+				rVal: r.rVal,
+
+				lIncluding: true,
+				rIncluding: r.rIncluding,
+			}
+		}
+	}
+	return neg, pos
+}
+
+// inner is the value of r closest to its edge
+func inner(r *NRange, left bool) float64 {
+	v, including := edge_pick(r, left)
+	if including {
+		return v
+	}
+	other, _ := edge_pick(r, !left)
+	return math.Nextafter(v, other)
+}
+
+// divSameSign is r / o for o of a single sign bit: quotient is monotonic
+// in both operands there, so its edges are quotients of edges
+func (r *NRange) divSameSign(o *NRange) *NRange {
+	var res *NRange
+	for _, lhsLeft := range []bool{true, false} {
+		a, aIncluding := edge_pick(r, lhsLeft)
+		for _, rhsLeft := range []bool{true, false} {
+			b, bIncluding := edge_pick(o, rhsLeft)
+
+			v := a / b
+			if math.IsNaN(v) {
+				// 0 / 0 and inf / inf, neighbouring edges cover the limits
+				continue
+			}
+
+			including := aIncluding && bIncluding
+			if math.IsInf(v, 0) {
+				// open edge may still overflow, e.g. 1 / (0, 1] reaches inf
+				including = sameValue(inner(r, lhsLeft)/inner(o, rhsLeft), v)
+			}
+
+			if res == nil {
+				res = &NRange{lVal: v, rVal: v, lIncluding: including, rIncluding: including}
+				continue
+			}
+			if cmp := zeroCmp(v, res.lVal); cmp < 0 {
+				res.lVal, res.lIncluding = v, including
+			} else if cmp == 0 {
+				res.lIncluding = res.lIncluding || including
+			}
+			if cmp := zeroCmp(v, res.rVal); cmp > 0 {
+				res.rVal, res.rIncluding = v, including
+			} else if cmp == 0 {
+				res.rIncluding = res.rIncluding || including
+			}
+		}
+	}
+	return res
+}
+
+// div is r / o by IEEE rules but NaN: dividing by a zero edge gives
+// infinity of the sign of that zero, divisors of both signs give a hull
+func (r *NRange) div(o *NRange) *NRange {
+	var res *NRange
+	neg, pos := o.splitZero()
+	for _, part := range []*NRange{neg, pos} {
+		if part == nil {
+			continue
+		}
+		q := r.divSameSign(part)
+		if q == nil {
+			continue
+		} else if res == nil {
+			res = q
+		} else {
+			res = res.Extend(q, true).Extend(q, false)
+		}
+	}
+	if res == nil {
+		// only NaN is possible, caller marks the result as such
+		return newRange()
+	}
+	return res
 }
 
 func (r *NRange) Overlaps(o *NRange) (*NRange, NEdge) {
@@ -607,6 +774,30 @@ func (r *NRange) Overlaps(o *NRange) (*NRange, NEdge) {
 	}, edge
 }
 
+func (r *NRange) Intersect(o *NRange) *NRange {
+	res := r.Clone()
+
+	if o.lVal > res.lVal {
+		res.lVal, res.lIncluding = o.lVal, o.lIncluding
+	} else if o.lVal == res.lVal {
+		res.lIncluding = res.lIncluding && o.lIncluding
+	}
+
+	if o.rVal < res.rVal {
+		res.rVal, res.rIncluding = o.rVal, o.rIncluding
+	} else if o.rVal == res.rVal {
+		res.rIncluding = res.rIncluding && o.rIncluding
+	}
+
+	if res.IsNaN() {
+		return nil
+	}
+	if res.IsSame(r) {
+		return r
+	}
+	return res
+}
+
 type Number struct {
 	p *NumberPrivate
 }
@@ -617,6 +808,10 @@ type NumberPrivate struct {
 	valRange    *NRange
 	next        *NumberPrivate
 	constraints []NumberConstraint
+	// value may also be NaN besides valRange, constants are NaN by val
+	nan bool
+	// cached constraintDepth, see ConstraintBudget
+	depth int
 }
 
 func newNumberPrivate(r *NRange) *NumberPrivate {
@@ -642,6 +837,7 @@ func (p *NumberPrivate) Clone() *NumberPrivate {
 	res := &NumberPrivate{
 		val:     p.val,
 		integer: p.integer,
+		nan:     p.nan,
 	}
 
 	if p.valRange != nil {
@@ -654,6 +850,10 @@ func (p *NumberPrivate) Clone() *NumberPrivate {
 }
 
 func (p *NumberPrivate) IsInteger() Boolean {
+	if p.nan && p.integer.IsTrue() {
+		// integer is about valRange, NaN is not known to be integer
+		return NewBoolean()
+	}
 	if p.next == nil {
 		return p.integer
 	}
@@ -662,7 +862,7 @@ func (p *NumberPrivate) IsInteger() Boolean {
 
 func (p *NumberPrivate) IsConstant() bool {
 	if p.valRange != nil {
-		if p.valRange.IsConstant() {
+		if p.valRange.IsConstant() && !p.nan {
 			p.val = p.valRange.lVal
 			p.valRange = nil
 			return true
@@ -676,13 +876,92 @@ func (p *NumberPrivate) IsConstant() bool {
 	return true
 }
 
+// isNaN tells whether p is NaN: BUnknown for ranges which may be NaN
+func (p *NumberPrivate) isNaN() BValue {
+	for curr := p; curr != nil; curr = curr.next {
+		if curr.nan {
+			return BUnknown
+		}
+		if curr.valRange == nil && math.IsNaN(curr.val) {
+			if curr == p && p.next == nil {
+				return BTrue
+			}
+			return BUnknown
+		}
+	}
+	return BFalse
+}
+
 func (p *NumberPrivate) Sign() []float64 {
 	if p.IsConstant() {
+		if p.val == 0 {
+			return []float64{p.val}
+		}
 		return []float64{math.Copysign(1, p.val)}
 	}
 	return p.valRange.Sign()
 }
 
+func (n Number) TypeName() string {
+	return "Number"
+}
+
+// ToBoolean follows Lua truthiness: every number is true.
+func (n Number) ToBoolean() Boolean {
+	return _true
+}
+
+func formatNumberValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (r *NRange) String() string {
+	l, rr := "(", ")"
+	if r.lIncluding {
+		l = "["
+	}
+	if r.rIncluding {
+		rr = "]"
+	}
+	return l + formatNumberValue(r.lVal) + ", " + formatNumberValue(r.rVal) + rr
+}
+
+func (n Number) String() string {
+	if n.p == nil {
+		return "invalid"
+	}
+
+	var parts []string
+	for curr := n.p; curr != nil; curr = curr.next {
+		if curr.valRange == nil {
+			parts = append(parts, formatNumberValue(curr.val))
+		} else {
+			parts = append(parts, curr.valRange.String())
+		}
+		if curr.nan {
+			parts = append(parts, "nan")
+		}
+	}
+
+	res := strings.Join(parts, " | ")
+	if n.p.valRange != nil && n.IsInteger().IsTrue() {
+		res += " int"
+	}
+	return res
+}
+
+func (n Number) Constraints() []NumberConstraint {
+	return n.p.constraints
+}
+
 func (n Number) IsValid() bool {
 	return n.p != nil
 }
@@ -696,9 +975,9 @@ func (n Number) IsSame(o Number) bool {
 	oConst := o.IsConstant()
 
 	if nConst && oConst {
-		return n.p.val == o.p.val || (math.IsNaN(n.p.val) && math.IsNaN(o.p.val))
+		return sameValue(n.p.val, o.p.val) || (math.IsNaN(n.p.val) && math.IsNaN(o.p.val))
 	} else if !nConst && !oConst {
-		if !n.p.valRange.IsSame(o.p.valRange) {
+		if !n.p.valRange.IsSame(o.p.valRange) || n.p.nan != o.p.nan {
 			return false
 		}
 		return n.p.integer.IsSame(o.p.integer)
@@ -739,7 +1018,7 @@ func (n Number) RangeAdjust() (Number, error) {
 
 	if r.lVal > r.rVal {
 		r = r.Invert()
-	} else if r.lVal == r.rVal {
+	} else if sameValue(r.lVal, r.rVal) && !n.p.nan {
 		return newNumberConstWithIntegerHint(r.lVal, integer), nil
 	} else if math.IsNaN(r.lVal) || math.IsNaN(r.rVal) {
 		return Number{}, errors.New("NaN edge un NRange")
@@ -800,9 +1079,9 @@ func (n Number) IsUnknown() bool {
 func (n Number) IsInf(sign int) Boolean {
 	if n.IsConstant() {
 		if math.IsInf(n.p.val, sign) {
-			return NewBooleanConst(BTrue, nil)
+			return _true
 		}
-		return NewBooleanConst(BFalse, nil)
+		return _false
 	}
 
 	return n.p.valRange.IsInf(sign)
@@ -819,23 +1098,34 @@ func (n Number) IsUniversum() bool {
 }
 
 func (n Number) IsNaN() Boolean {
-	r := n.p.valRange
-	if r == nil {
-		if math.IsNaN(n.p.val) {
-			return NewBooleanConst(BTrue, nil)
-		}
-		return NewBooleanConst(BFalse, nil)
-	}
+	return booleanConst(n.p.isNaN())
+}
 
-	if n.IsUnknown() {
-		return NewBoolean()
+// OrNaN returns n which may also be NaN
+func (n Number) OrNaN() Number {
+	if n.p.isNaN() != BFalse {
+		return n
 	}
 
-	if math.IsNaN(r.lVal) || math.IsNaN(r.rVal) {
-		return NewBooleanConst(BTrue, nil)
+	// the whole chain is kept, NaN is marked on its last element
+	p := n.p.Clone()
+	last := p
+	for last.next != nil {
+		last = last.next
+	}
+	if last.valRange == nil {
+		last.valRange = newRangeSegment(last.val, last.val)
 	}
+	last.nan = true
+	return Number{p: p}
+}
 
-	return NewBooleanConst(BFalse, nil)
+// withNaNOf makes res maybe NaN when n may be NaN
+func withNaNOf(res, n Number) Number {
+	if n.p.isNaN() != BFalse {
+		return res.OrNaN()
+	}
+	return res
 }
 
 func (n Number) IsInteger() Boolean {
@@ -913,12 +1203,15 @@ func (n Number) extendTo(o Number, leftExtend bool) []Number {
 
 func (p *NumberPrivate) less(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 	if p == o {
-		return &BooleanPrivate{val: BFalse}, NEdgeNo
+		return decided(BFalse, compareReason(ReasonIdentity, NRelationLess, p, o)), NEdgeNo
 	}
 
 	if p.next != nil || o.next != nil {
 		panic("next is unsupported yet")
 	}
+	if p.isNaN() == BTrue || o.isNaN() == BTrue {
+		return decided(BFalse, compareReason(ReasonNaN, NRelationLess, p, o)), NEdgeNo
+	}
 
 	pConst := p.IsConstant()
 	oConst := o.IsConstant()
@@ -935,22 +1228,24 @@ func (p *NumberPrivate) less(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 		} else {
 			bVal = BFalse
 		}
+		return decided(bVal, compareReason(ReasonConstant, NRelationLess, p, o)), on_edge
 	} else {
+		var c NumberConstraint
 		var err error
-		bVal, err = checkConstraintsNumber(p, o, constraintNumberLessAllVisitor)
+		bVal, c, err = checkConstraintsNumber(p, o, constraintNumberLessAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if isBValConst(bVal) {
-			return &BooleanPrivate{val: bVal}, on_edge
+			return decided(bVal, constraintReason(NRelationLess, p, o, p, c)), on_edge
 		}
 
-		bVal, err = checkConstraintsNumber(o, p, constraintNumberGreaterAllVisitor)
+		bVal, c, err = checkConstraintsNumber(o, p, constraintNumberGreaterAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if isBValConst(bVal) {
-			return &BooleanPrivate{val: bVal}, on_edge
+			return decided(bVal, constraintReason(NRelationLess, p, o, o, c)), on_edge
 		}
 
 		if pConst {
@@ -979,43 +1274,69 @@ func (p *NumberPrivate) less(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 			less := pRange.Less(oRange)
 			bVal = less.p.val
 		}
+
+		// ranges order values but NaN which compares false
+		if p.nan || o.nan {
+			if bVal == BTrue {
+				bVal = BUnknown
+			}
+			on_edge = NEdgeNo
+		}
 	}
 
-	return &BooleanPrivate{val: bVal}, on_edge
+	return decided(bVal, compareReason(ReasonRange, NRelationLess, p, o)), on_edge
+}
+
+func withNumberCompare(b Boolean, rel NRelation, lhs, rhs Number) Boolean {
+	if b.p.val == BUnknown {
+		b.p.addConstraint(NewBooleanNumberCompare(rel, lhs, rhs))
+	}
+	return b
 }
 
 func (n Number) Less(o Number) Boolean {
 	lt, _ := n.p.less(o.p)
-	return Boolean{p: lt}
+	return withNumberCompare(Boolean{p: lt}, NRelationLess, n, o)
 }
 
 func (n Number) LessEqual(o Number) Boolean {
+	return withNumberCompare(n.lessEqual(o), NRelationLessEqual, n, o)
+}
+
+func (n Number) lessEqual(o Number) Boolean {
+	if n.p.isNaN() == BTrue || o.p.isNaN() == BTrue {
+		return Boolean{p: decided(BFalse, compareReason(ReasonNaN, NRelationLessEqual, n.p, o.p))}
+	}
 	if n.p == o.p {
-		return NewBooleanConst(BTrue, nil)
+		return Boolean{p: decided(notBValue(n.p.isNaN()), compareReason(ReasonIdentity, NRelationLessEqual, n.p, o.p))}
+	}
+	if n.p.valRange == nil && o.p.valRange == nil && n.p.next == nil && o.p.next == nil {
+		return Boolean{p: decided(bvalueOf(n.p.val <= o.p.val), compareReason(ReasonConstant, NRelationLessEqual, n.p, o.p))}
 	}
 
-	bVal, err := checkConstraintsNumber(n.p, o.p, constraintNumberLessEqualAllVisitor)
+	bVal, c, err := checkConstraintsNumber(n.p, o.p, constraintNumberLessEqualAllVisitor)
 	if err != nil {
 		panic(err.Error())
 	}
 	if isBValConst(bVal) {
-		return NewBooleanConst(bVal, nil)
+		return Boolean{p: decided(bVal, constraintReason(NRelationLessEqual, n.p, o.p, n.p, c))}
 	}
 
-	bVal, err = checkConstraintsNumber(o.p, n.p, constraintNumberGreaterEqualAllVisitor)
+	bVal, c, err = checkConstraintsNumber(o.p, n.p, constraintNumberGreaterEqualAllVisitor)
 	if err != nil {
 		panic(err.Error())
 	}
 	if isBValConst(bVal) {
-		return NewBooleanConst(bVal, nil)
+		return Boolean{p: decided(bVal, constraintReason(NRelationLessEqual, n.p, o.p, o.p, c))}
 	}
 
 	lt, on_edge := n.p.less(o.p)
 	if lt.val == BTrue {
 		return Boolean{p: lt}
 	}
-	if on_edge == NEdgeLeft {
-		return NewBooleanConst(BTrue, nil)
+	// constant on including edge of the other range
+	if on_edge != NEdgeNo {
+		return Boolean{p: decided(BTrue, compareReason(ReasonRange, NRelationLessEqual, n.p, o.p))}
 	}
 
 	eq, _ := n.p.equal(o.p)
@@ -1027,32 +1348,42 @@ func (n Number) Greater(o Number) Boolean {
 }
 
 func (n Number) GreaterEqual(o Number) Boolean {
+	return withNumberCompare(n.greaterEqual(o), NRelationLessEqual, o, n)
+}
+
+func (n Number) greaterEqual(o Number) Boolean {
+	if n.p.isNaN() == BTrue || o.p.isNaN() == BTrue {
+		return Boolean{p: decided(BFalse, compareReason(ReasonNaN, NRelationLessEqual, o.p, n.p))}
+	}
 	if n.p == o.p {
-		return NewBooleanConst(BTrue, nil)
+		return Boolean{p: decided(notBValue(n.p.isNaN()), compareReason(ReasonIdentity, NRelationLessEqual, o.p, n.p))}
+	}
+	if n.p.valRange == nil && o.p.valRange == nil && n.p.next == nil && o.p.next == nil {
+		return Boolean{p: decided(bvalueOf(o.p.val <= n.p.val), compareReason(ReasonConstant, NRelationLessEqual, o.p, n.p))}
 	}
 
-	bVal, err := checkConstraintsNumber(n.p, o.p, constraintNumberGreaterEqualAllVisitor)
+	bVal, c, err := checkConstraintsNumber(n.p, o.p, constraintNumberGreaterEqualAllVisitor)
 	if err != nil {
 		panic(err.Error())
 	}
 	if isBValConst(bVal) {
-		return NewBooleanConst(bVal, nil)
+		return Boolean{p: decided(bVal, constraintReason(NRelationLessEqual, o.p, n.p, n.p, c))}
 	}
 
-	bVal, err = checkConstraintsNumber(o.p, n.p, constraintNumberLessEqualAllVisitor)
+	bVal, c, err = checkConstraintsNumber(o.p, n.p, constraintNumberLessEqualAllVisitor)
 	if err != nil {
 		panic(err.Error())
 	}
 	if isBValConst(bVal) {
-		return NewBooleanConst(bVal, nil)
+		return Boolean{p: decided(bVal, constraintReason(NRelationLessEqual, o.p, n.p, o.p, c))}
 	}
 
 	gt, on_edge := o.p.less(n.p)
 	if gt.val == BTrue {
 		return Boolean{p: gt}
 	}
-	if on_edge == NEdgeRight {
-		return NewBooleanConst(BTrue, nil)
+	if on_edge != NEdgeNo {
+		return Boolean{p: decided(BTrue, compareReason(ReasonRange, NRelationLessEqual, o.p, n.p))}
 	}
 
 	eq, _ := n.p.equal(o.p)
@@ -1077,18 +1408,20 @@ func rangeContains(r *NRange, val float64) (BValue, NEdge) {
 
 type constraintFuncNumber func(c NumberConstraint, o *NumberPrivate) (BValue, error)
 
-func checkConstraintsNumber(p, o *NumberPrivate, f constraintFuncNumber) (BValue, error) {
+// checkConstraintsNumber also returns constraint deciding the result
+func checkConstraintsNumber(p, o *NumberPrivate, f constraintFuncNumber) (BValue, NumberConstraint, error) {
 	res := BUnknown
+	var decisive NumberConstraint
 	for _, c := range p.constraints {
 		r, err := f(c, o)
 		if err != nil || r == BFalse {
-			return r, err
+			return r, c, err
 		}
-		if r == BTrue {
-			res = BTrue
+		if r == BTrue && res != BTrue {
+			res, decisive = BTrue, c
 		}
 	}
-	return res, nil
+	return res, decisive, nil
 }
 
 func constraintNumberEqualAllVisitor(c NumberConstraint, o *NumberPrivate) (BValue, error) {
@@ -1118,17 +1451,20 @@ func isBValConst(val BValue) bool {
 func (p *NumberPrivate) equal(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 	if p == o {
 		if p.IsConstant() && math.IsNaN(p.val) {
-			return &BooleanPrivate{val: BFalse}, NEdgeNo
+			return decided(BFalse, compareReason(ReasonConstant, NRelationEqual, p, o)), NEdgeNo
 		}
-		return &BooleanPrivate{val: BTrue}, NEdgeNo
+		return decided(notBValue(p.isNaN()), compareReason(ReasonIdentity, NRelationEqual, p, o)), NEdgeNo
 	}
 
 	if p.next != nil || o.next != nil {
 		panic("next is unsupported yet")
 	}
+	if p.isNaN() == BTrue || o.isNaN() == BTrue {
+		return decided(BFalse, compareReason(ReasonNaN, NRelationEqual, p, o)), NEdgeNo
+	}
 
 	if p.integer.Equal(o.integer).IsFalse() {
-		return &BooleanPrivate{val: BFalse}, NEdgeNo
+		return decided(BFalse, compareReason(ReasonInteger, NRelationEqual, p, o)), NEdgeNo
 	}
 
 	bVal := BUnknown
@@ -1146,21 +1482,23 @@ func (p *NumberPrivate) equal(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 		} else {
 			bVal = BFalse
 		}
+		return decided(bVal, compareReason(ReasonConstant, NRelationEqual, p, o)), on_edge
 	} else {
+		var c NumberConstraint
 		var err error
-		bVal, err = checkConstraintsNumber(p, o, constraintNumberEqualAllVisitor)
+		bVal, c, err = checkConstraintsNumber(p, o, constraintNumberEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if isBValConst(bVal) {
-			return &BooleanPrivate{val: bVal}, on_edge
+			return decided(bVal, constraintReason(NRelationEqual, p, o, p, c)), on_edge
 		}
-		bVal, err = checkConstraintsNumber(o, p, constraintNumberEqualAllVisitor)
+		bVal, c, err = checkConstraintsNumber(o, p, constraintNumberEqualAllVisitor)
 		if err != nil {
 			panic(err.Error())
 		}
 		if isBValConst(bVal) {
-			return &BooleanPrivate{val: bVal}, on_edge
+			return decided(bVal, constraintReason(NRelationEqual, p, o, o, c)), on_edge
 		}
 
 		if !pConst && !oConst {
@@ -1172,12 +1510,12 @@ func (p *NumberPrivate) equal(o *NumberPrivate) (*BooleanPrivate, NEdge) {
 		}
 	}
 
-	return &BooleanPrivate{val: bVal}, on_edge
+	return decided(bVal, compareReason(ReasonRange, NRelationEqual, p, o)), on_edge
 }
 
 func (n Number) Equal(o Number) Boolean {
 	eq, _ := n.p.equal(o.p)
-	return Boolean{p: eq}
+	return withNumberCompare(Boolean{p: eq}, NRelationEqual, n, o)
 }
 
 func (n Number) Negate() Number {
@@ -1201,6 +1539,9 @@ type ArithmeticOperationBinary interface {
 	DetectEdgeCaseRight(n Number, val float64) Number
 
 	DetectEdgeCaseSame(n Number) Number
+	DetectEdgeCaseNaN(x, y Number) Number
+
+	MayProduceNaN(x, y Number) bool
 
 	PreprocessRangeLeft(r *NRange) *NRange
 	PreprocessRangeRight(r *NRange) *NRange
@@ -1210,6 +1551,24 @@ type ArithmeticOperationBinary interface {
 	ResultConstraints(x, y, result Number) Number
 }
 
+// nanResult is the result of operations with NaN operand
+func nanResult(x, y Number) Number {
+	return NewNumberConst(math.NaN())
+}
+
+func mayBeBoth(x, y Number, xVal, yVal float64) bool {
+	return x.mayBe(xVal) != BFalse && y.mayBe(yVal) != BFalse
+}
+
+func mayBeInf(n Number) bool {
+	return n.mayBe(math.Inf(1)) != BFalse || n.mayBe(math.Inf(-1)) != BFalse
+}
+
+// 0 / 0 and inf / inf
+func divMayProduceNaN(x, y Number) bool {
+	return mayBeBoth(x, y, 0, 0) || (mayBeInf(x) && mayBeInf(y))
+}
+
 type OpAdd struct{}
 
 func (_ OpAdd) Compute(x, y float64) (float64, error) { return x + y, nil }
@@ -1234,10 +1593,16 @@ func (o OpAdd) DetectEdgeCaseRight(n Number, val float64) Number {
 }
 
 func (_ OpAdd) DetectEdgeCaseSame(n Number) Number     { return Number{} }
+func (_ OpAdd) DetectEdgeCaseNaN(x, y Number) Number   { return nanResult(x, y) }
 func (_ OpAdd) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpAdd) PreprocessRangeRight(r *NRange) *NRange { return r }
 func (_ OpAdd) IsResultInt() Boolean                   { return Boolean{} }
 
+// inf + -inf
+func (_ OpAdd) MayProduceNaN(x, y Number) bool {
+	return mayBeBoth(x, y, math.Inf(1), math.Inf(-1)) || mayBeBoth(x, y, math.Inf(-1), math.Inf(1))
+}
+
 func (_ OpAdd) ResultConstraints(x, y, result Number) Number {
 	if result.IsConstant() || x.IsNaN().IsTrue() || y.IsNaN().IsTrue() {
 		return result
@@ -1282,7 +1647,7 @@ func (_ OpAdd) ResultConstraints(x, y, result Number) Number {
 		}
 	}
 
-	result.p.constraints = append(result.p.constraints, constraints...)
+	result.p.addConstraints(constraints...)
 	return result
 }
 
@@ -1325,10 +1690,16 @@ func (_ OpSub) DetectEdgeCaseSame(n Number) Number {
 	return Number{}
 }
 
+func (_ OpSub) DetectEdgeCaseNaN(x, y Number) Number   { return nanResult(x, y) }
 func (_ OpSub) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpSub) PreprocessRangeRight(r *NRange) *NRange { return r.Invert() }
 func (_ OpSub) IsResultInt() Boolean                   { return Boolean{} }
 
+// inf - inf
+func (_ OpSub) MayProduceNaN(x, y Number) bool {
+	return mayBeBoth(x, y, math.Inf(1), math.Inf(1)) || mayBeBoth(x, y, math.Inf(-1), math.Inf(-1))
+}
+
 func (_ OpSub) ResultConstraints(x, y, result Number) Number {
 	return result
 }
@@ -1353,32 +1724,29 @@ func (o OpMul) DetectEdgeCaseRight(n Number, val float64) Number {
 }
 
 func (_ OpMul) DetectEdgeCaseSame(n Number) Number     { return Number{} }
+func (_ OpMul) DetectEdgeCaseNaN(x, y Number) Number   { return nanResult(x, y) }
 func (_ OpMul) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpMul) PreprocessRangeRight(r *NRange) *NRange { return r }
 func (_ OpMul) IsResultInt() Boolean                   { return Boolean{} }
 
+// 0 * inf
+func (_ OpMul) MayProduceNaN(x, y Number) bool {
+	return (x.mayBe(0) != BFalse && mayBeInf(y)) || (mayBeInf(x) && y.mayBe(0) != BFalse)
+}
+
 func (_ OpMul) ResultConstraints(x, y, result Number) Number {
 	return result
 }
 
 type OpDiv struct{}
 
-func (_ OpDiv) Compute(x, y float64) (float64, error) {
-	if y == 0 {
-		return 0, ERR_DIV_BY_ZERO
-	}
-	res := x / y
-	print(x, "/", y, "=", res, "\n")
-	return res, nil
-}
-
-func (_ OpDiv) IsClosedField() bool       { return false }
-func (_ OpDiv) IsStrictClosedField() bool { return false }
+// Compute follows IEEE: x / ±0 is infinity of the sign of x * ±0
+func (_ OpDiv) Compute(x, y float64) (float64, error) { return x / y, nil }
+func (_ OpDiv) IsClosedField() bool                   { return false }
+func (_ OpDiv) IsStrictClosedField() bool             { return false }
 
+// DetectEdgeCaseLeft has no shortcut for 0: sign of 0 / y depends on y
 func (_ OpDiv) DetectEdgeCaseLeft(val float64, n Number) Number {
-	if val == 0 {
-		return _zero
-	}
 	return Number{}
 }
 
@@ -1400,6 +1768,8 @@ func divDetectEdgeCaseSame(n Number) Number {
 }
 
 func (_ OpDiv) DetectEdgeCaseSame(n Number) Number     { return divDetectEdgeCaseSame(n) }
+func (_ OpDiv) DetectEdgeCaseNaN(x, y Number) Number   { return nanResult(x, y) }
+func (_ OpDiv) MayProduceNaN(x, y Number) bool         { return divMayProduceNaN(x, y) }
 func (_ OpDiv) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpDiv) PreprocessRangeRight(r *NRange) *NRange { return r.Invert() }
 func (_ OpDiv) IsResultInt() Boolean                   { return Boolean{} }
@@ -1408,6 +1778,10 @@ func (_ OpDiv) ResultConstraints(x, y, result Number) Number {
 	return result
 }
 
+func (_ OpDiv) computeRange(x, y *NRange) []*NRange {
+	return []*NRange{x.div(y)}
+}
+
 type OpIDiv struct{}
 
 func (_ OpIDiv) Compute(x, y float64) (float64, error) {
@@ -1438,10 +1812,12 @@ func (_ OpIDiv) DetectEdgeCaseRight(n Number, val float64) Number {
 }
 
 func (_ OpIDiv) DetectEdgeCaseSame(n Number) Number     { return divDetectEdgeCaseSame(n) }
+func (_ OpIDiv) DetectEdgeCaseNaN(x, y Number) Number   { return nanResult(x, y) }
+func (_ OpIDiv) MayProduceNaN(x, y Number) bool         { return divMayProduceNaN(x, y) }
 func (_ OpIDiv) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpIDiv) PreprocessRangeRight(r *NRange) *NRange { return r.Invert() }
 
-var numberIsInteger = NewBooleanConst(BTrue, nil)
+var numberIsInteger = _true
 
 func (_ OpIDiv) IsResultInt() Boolean { return numberIsInteger }
 
@@ -1478,7 +1854,21 @@ func (_ OpPow) DetectEdgeCaseRight(n Number, val float64) Number {
 	return Number{}
 }
 
-func (_ OpPow) DetectEdgeCaseSame(n Number) Number     { return Number{} }
+func (_ OpPow) DetectEdgeCaseSame(n Number) Number { return Number{} }
+
+// 1 ^ NaN and NaN ^ 0 are 1
+func (_ OpPow) DetectEdgeCaseNaN(x, y Number) Number {
+	if (x.p.isNaN() == BTrue && y.mayBe(0) != BFalse) || (y.p.isNaN() == BTrue && x.mayBe(1) != BFalse) {
+		return _one.OrNaN()
+	}
+	return nanResult(x, y)
+}
+
+// negative ^ non integer
+func (_ OpPow) MayProduceNaN(x, y Number) bool {
+	r := x.p.hull()
+	return r != nil && r.lVal < 0 && r.rVal > math.Inf(-1) && !y.IsInteger().IsTrue()
+}
 func (_ OpPow) PreprocessRangeLeft(r *NRange) *NRange  { return r }
 func (_ OpPow) PreprocessRangeRight(r *NRange) *NRange { return r }
 func (_ OpPow) IsResultInt() Boolean                   { return Boolean{} }
@@ -1487,31 +1877,53 @@ func (_ OpPow) ResultConstraints(x, y, result Number) Number {
 	return result
 }
 
+// constOperator is fast path for constant operands, it allocates only
+// if result is not interned
+func constOperator(x, y Number, op ArithmeticOperationBinary) (Number, error) {
+	xpVal, ypVal := x.p.val, y.p.val
+	res, err := op.Compute(xpVal, ypVal)
+	if err != nil {
+		if err == ERR_NAN {
+			return NewNumberConst(math.NaN()), nil
+		}
+		return Number{}, err
+	}
+	if sameValue(res, xpVal) {
+		return x, nil
+	} else if sameValue(res, ypVal) {
+		return y, nil
+	}
+	return NewNumberConst(res), nil
+}
+
 func operator(x, y Number, op ArithmeticOperationBinary) (Number, error) {
 	if x.p.next != nil || y.p.next != nil {
 		panic("next is unsupported yet")
 	}
 
+	if x.p.valRange == nil && y.p.valRange == nil {
+		return constOperator(x, y, op)
+	}
+
 	xConstant := x.IsConstant()
 	yConstant := y.IsConstant()
 
 	if xConstant && yConstant {
-		xpVal, ypVal := x.p.val, y.p.val
-		res, err := op.Compute(xpVal, ypVal)
-		if err != nil {
-			if err.Error() == NAN_STR {
-				return NewNumberConst(math.NaN()), nil
-			}
-			return Number{}, err
-		}
-		if res == xpVal {
-			return x, nil
-		} else if res == ypVal {
-			return y, nil
-		}
-		return NewNumberConst(res), nil
+		return constOperator(x, y, op)
 	}
 
+	if x.p.isNaN() == BTrue || y.p.isNaN() == BTrue {
+		return op.DetectEdgeCaseNaN(x, y), nil
+	}
+
+	res, err := rangeOperator(x, y, xConstant, yConstant, op)
+	if err == nil && (x.p.nan || y.p.nan || op.MayProduceNaN(x, y)) {
+		res = res.OrNaN()
+	}
+	return res, err
+}
+
+func rangeOperator(x, y Number, xConstant, yConstant bool, op ArithmeticOperationBinary) (Number, error) {
 	xp, yp := x.p, y.p
 
 	if xp == yp {
@@ -1575,7 +1987,8 @@ func operator(x, y Number, op ArithmeticOperationBinary) (Number, error) {
 		}
 	}
 
-	if resInt.IsValid() {
+	// constant results may be interned, their integer flag is exact anyway
+	if resInt.IsValid() && res.p.valRange != nil {
 		res.p.integer = resInt
 		needAdjust = true
 	}
@@ -1595,55 +2008,198 @@ func (n Number) Pow(o Number) (Number, error) { return operator(n, o, OpPow{}) }
 
 func (n Number) IDiv(o Number) (Number, error) {
 	res, err := operator(n, o, OpIDiv{})
-	if err == nil && !res.IsNaN().IsTrue() {
-		res.p.integer = NewBooleanConst(BTrue, nil)
+	if err == nil && !res.IsNaN().IsTrue() && !res.IsInteger().IsTrue() {
+		// res may be operand or interned constant
+		p := res.p.Clone()
+		p.integer = _true
+		p.constraints = res.p.constraints
+		res = Number{p: p}
 	}
 	return res, err
 }
 
 func (n Number) Max(numbers []Number) Number {
-	if n.p.next != nil {
-		panic("next is unsupported yet")
-	}
+	return n.extremum(numbers, true)
+}
+
+func (n Number) Min(numbers []Number) Number {
+	return n.extremum(numbers, false)
+}
+
+// MaxOf returns the greatest of numbers, like Lua math.max
+func MaxOf(n Number, numbers ...Number) Number {
+	return n.Max(numbers)
+}
+
+// MinOf returns the least of numbers, like Lua math.min
+func MinOf(n Number, numbers ...Number) Number {
+	return n.Min(numbers)
+}
+
+// extremum returns argument known to be the greatest (least) one. When
+// order is unknown result spans both candidates: max([0, 5], [3, 8]) is
+// [3, 8], and it is bound to every argument by constraints.
+func (n Number) extremum(numbers []Number, max bool) Number {
 	if n.IsNaN().IsTrue() {
 		return n
 	}
 
+	res, joined := n.collapsed(), false
 	for _, num := range numbers {
 		if num.IsNaN().IsTrue() {
 			return num
 		}
+		num = num.collapsed()
 
-		if gt := num.Greater(n); gt.IsTrue() {
-			n = num
-		} else if gt.IsUnknown() {
+		var wins *BooleanPrivate
+		if max {
+			wins, _ = res.p.less(num.p)
+		} else {
+			wins, _ = num.p.less(res.p)
+		}
+		switch wins.val {
+		case BTrue:
+			res, joined = num, false
+		case BUnknown:
+			r := res.p.hull().extremum(num.p.hull(), max)
+			joinedRes := newNumberJoined(r, joinInteger(res, num))
+			res, joined = withNaNOf(withNaNOf(joinedRes, res), num), true
+		}
+	}
+	if !joined || res.p.valRange == nil || res.p.nan {
+		// constants may be interned and need no constraints anyway,
+		// NaN is not ordered with numbers
+		return res
+	}
 
-			panic("NYI")
+	constraints := make([]NumberConstraint, 0, len(numbers)+1)
+	for _, num := range append([]Number{n}, numbers...) {
+		if max {
+			constraints = append(constraints, NewNumberGreaterEqual(num))
+		} else {
+			constraints = append(constraints, NewNumberLessEqual(num))
 		}
 	}
-	return n
+	res.p.setConstraints(constraints)
+	return res
 }
 
-func (n Number) Min(numbers []Number) Number {
-	if n.p.next != nil {
-		panic("next is unsupported yet")
+// Select returns then when cond is true and els when it is false, like
+// Lua "cond and then or els" idiom. Unknown cond gives join of both
+// bound to cond by NumberSelect constraint, so assuming cond in Context
+// resolves it back.
+func Select(cond Boolean, then, els Number) Number {
+	switch cond.p.val {
+	case BTrue:
+		return then
+	case BFalse:
+		return els
 	}
-	if n.IsNaN().IsTrue() {
-		return n
+
+	if then.p == els.p {
+		return then
 	}
+	res := then.Join(els)
+	if res.p.valRange == nil {
+		// constants may be interned and need no constraints anyway
+		return res
+	}
+	if res.p == then.p || res.p == els.p {
+		// branches of the same range are still different values
+		res = Number{p: res.p.Clone()}
+	}
+	res.p.setConstraints([]NumberConstraint{NewNumberSelect(cond, then, els)})
+	return res
+}
 
-	for _, num := range numbers {
-		if num.IsNaN().IsTrue() {
-			return num
-		}
+// Clamp limits n to [lo, hi] as math.min(math.max(n, lo), hi) does, hi
+// wins when lo > hi
+func (n Number) Clamp(lo, hi Number) Number {
+	return MinOf(MaxOf(n, lo), hi)
+}
 
-		if lt := num.Less(n); lt.IsTrue() {
-			n = num
-		} else { // TODO: False & Unknown
-			panic("NYI")
+// hull is the smallest range containing values of p but NaN, nil for NaN
+func (p *NumberPrivate) hull() *NRange {
+	var res *NRange
+	for curr := p; curr != nil; curr = curr.next {
+		r := curr.valRange
+		if r == nil {
+			if math.IsNaN(curr.val) {
+				continue
+			}
+			r = newRangeSegment(curr.val, curr.val)
+		}
+		if res == nil {
+			res = r
+		} else {
+			res = res.Extend(r, true).Extend(r, false)
 		}
 	}
-	return n
+	return res
+}
+
+// collapsed replaces next chain of n with its hull, so it can be compared
+func (n Number) collapsed() Number {
+	if n.p.next == nil {
+		return n
+	}
+	return withNaNOf(newNumberJoined(n.p.hull(), n.IsInteger()), n)
+}
+
+func joinInteger(n, o Number) Boolean {
+	nInt, oInt := n.IsInteger(), o.IsInteger()
+	if nInt.IsConstant() && nInt.IsSame(oInt) {
+		return booleanConst(nInt.p.val)
+	}
+	return NewBoolean()
+}
+
+func newNumberJoined(r *NRange, integer Boolean) Number {
+	p := newNumberPrivate(r)
+	p.integer = integer
+	res, _ := Number{p: p}.RangeAdjust()
+	return res
+}
+
+// Join returns the smallest single range containing both n and o.
+func (n Number) Join(o Number) Number {
+	if n.p == o.p || n.IsSame(o) {
+		return n
+	}
+
+	nRange, oRange := n.p.hull(), o.p.hull()
+	if nRange == nil {
+		return o.OrNaN()
+	} else if oRange == nil {
+		return n.OrNaN()
+	}
+
+	r := nRange.Extend(oRange, true).Extend(oRange, false)
+	return withNaNOf(withNaNOf(newNumberJoined(r, joinInteger(n, o)), n), o)
+}
+
+// Widen extrapolates unstable edges of n (previous loop iteration value)
+// to infinity, so iterating over o (next iteration value) terminates.
+func (n Number) Widen(o Number) Number {
+	if n.p == o.p || n.IsSame(o) {
+		return n
+	}
+
+	nRange, oRange := n.p.hull(), o.p.hull()
+	if nRange == nil {
+		return o.OrNaN()
+	} else if oRange == nil {
+		return n.OrNaN()
+	}
+
+	r := nRange.Clone()
+	if lCmp, _ := edge_cmp(oRange, nRange, true, true); lCmp < 0 {
+		r.lVal, r.lIncluding = math.Inf(-1), true
+	}
+	if rCmp, _ := edge_cmp(oRange, nRange, false, false); rCmp > 0 {
+		r.rVal, r.rIncluding = math.Inf(1), true
+	}
+	return withNaNOf(withNaNOf(newNumberJoined(r, joinInteger(n, o)), n), o)
 }
 
 func (n Number) Floor() Number {
@@ -1675,14 +2231,15 @@ func (n Number) FloorWithOpt(arithmeticallyCorrect, inverted bool) Number {
 		}
 		p = &NumberPrivate{
 			val:     newVal,
-			integer: NewBooleanConst(BTrue, nil),
+			integer: _true,
 		}
 	} else if n.p.valRange != nil {
 		newRange := n.p.valRange.Floor(arithmeticallyCorrect, inverted)
 		p = &NumberPrivate{
 			val:      0,
-			integer:  NewBooleanConst(BTrue, nil),
+			integer:  _true,
 			valRange: newRange,
+			nan:      n.p.nan,
 		}
 	}
 
@@ -1690,6 +2247,365 @@ func (n Number) FloorWithOpt(arithmeticallyCorrect, inverted bool) Number {
 	return res
 }
 
+// ====== Conversions ======
+
+// RoundMode selects how Round maps values to integers
+type RoundMode int
+
+const (
+	// RoundHalfAwayFromZero is math.Round
+	RoundHalfAwayFromZero RoundMode = iota
+	// RoundHalfEven is math.RoundToEven
+	RoundHalfEven
+	// RoundTowardZero is math.Trunc
+	RoundTowardZero
+	// RoundDown is math.Floor
+	RoundDown
+	// RoundUp is math.Ceil
+	RoundUp
+)
+
+func (m RoundMode) apply(v float64) float64 {
+	switch m {
+	case RoundHalfEven:
+		return math.RoundToEven(v)
+	case RoundTowardZero:
+		return math.Trunc(v)
+	case RoundDown:
+		return math.Floor(v)
+	case RoundUp:
+		return math.Ceil(v)
+	}
+	return math.Round(v)
+}
+
+// round rounds every value of r, all modes are monotonic so edges map to
+// edges. Open edge maps as the nearest value inside r does.
+func (r *NRange) round(m RoundMode) *NRange {
+	l, rr := r.lVal, r.rVal
+	if !r.lIncluding {
+		l = math.Nextafter(l, math.Inf(1))
+	}
+	if !r.rIncluding {
+		rr = math.Nextafter(rr, math.Inf(-1))
+	}
+	return newRangeSegment(m.apply(l), m.apply(rr))
+}
+
+// int64 range as float64, MaxInt64 itself rounds up to 2^63 so the
+// right edge is the greatest float64 below it
+var int64Range = newRangeSegment(math.MinInt64, math.Nextafter(math.MaxInt64, 0))
+
+// fitsInt64 tells whether values of r are representable as int64
+func fitsInt64(r *NRange) Boolean {
+	if r.Intersect(int64Range) == nil {
+		return _false
+	}
+	if r.lVal >= int64Range.lVal && r.rVal <= int64Range.rVal {
+		return _true
+	}
+	return NewBoolean()
+}
+
+// Round returns n rounded with mode and whether result fits int64, like
+// math.floor gives Lua integer only when it does. NaN and infinities stay
+// as they are and never fit.
+func (n Number) Round(mode RoundMode) (Number, Boolean) {
+	if n.p.next != nil {
+		panic("next is unsupported yet")
+	}
+	if n.IsConstant() {
+		v := mode.apply(n.p.val)
+		if math.IsNaN(v) {
+			return n, _false
+		}
+		res := n
+		if v != n.p.val {
+			res = NewNumberConst(v)
+		}
+		return res, fitsInt64(newRangeSegment(v, v))
+	}
+
+	r := n.p.valRange.round(mode)
+	integer := _true
+	if r.lIncluding && math.IsInf(r.lVal, 0) || r.rIncluding && math.IsInf(r.rVal, 0) {
+		// infinities stay as they are and are not integers
+		integer = NewBoolean()
+	}
+	res := newNumberJoined(r, integer)
+	if fits := fitsInt64(r); !n.p.nan || fits.IsFalse() {
+		return res, fits
+	}
+	return res.OrNaN(), NewBoolean()
+}
+
+// Trunc rounds toward zero, see Round
+func (n Number) Trunc() (Number, Boolean) {
+	return n.Round(RoundTowardZero)
+}
+
+// ToInteger converts n to integer like Lua math.tointeger: conversion
+// fails for non-integral values, NaN and values out of int64 range. Result
+// holds values converted successfully and is invalid when conversion
+// always fails, ok tells whether it succeeds.
+func (n Number) ToInteger() (Number, Boolean) {
+	if n.p.next != nil {
+		panic("next is unsupported yet")
+	}
+	if n.IsConstant() {
+		v := n.p.val
+		if math.IsNaN(v) || v != math.Floor(v) || fitsInt64(newRangeSegment(v, v)).IsFalse() {
+			return Number{}, _false
+		}
+		return n, _true
+	}
+
+	integer := n.IsInteger()
+	if integer.IsFalse() {
+		return Number{}, _false
+	}
+	r := n.p.valRange.Intersect(int64Range)
+	if r != nil {
+		r = r.ToIntegerRange()
+	}
+	if r == nil {
+		return Number{}, _false
+	}
+
+	fits := fitsInt64(n.p.valRange)
+	ok := NewBoolean()
+	if integer.IsTrue() && fits.IsTrue() {
+		ok = _true
+	}
+	if ok.IsTrue() && r.IsSame(n.p.valRange) {
+		return n, ok
+	}
+	return newNumberJoined(r, _true), ok
+}
+
+// ToFloat converts n to float like Lua 1.0 * n: values are kept, result
+// is never integer
+func (n Number) ToFloat() Number {
+	integer := false
+	for curr := n.p; curr != nil; curr = curr.next {
+		integer = integer || !curr.integer.IsFalse()
+	}
+	if !integer {
+		return n
+	}
+
+	var res, prev *NumberPrivate
+	for curr := n.p; curr != nil; curr = curr.next {
+		p := *curr
+		p.integer, p.next = _false, nil
+		if prev == nil {
+			res = &p
+		} else {
+			prev.next = &p
+		}
+		prev = &p
+	}
+	return Number{p: res}
+}
+
+// the longest %.14g output, e.g. -1.2345678901235e-308
+const luaMaxFloatLen = 21
+
+// formatLuaNumber formats v as Lua 5.3 tostring does: integers with %d,
+// floats with %.14g and ".0" appended when that looks like an integer.
+// Integer subtype holds int64 values only, others are floats anyway.
+func formatLuaNumber(v float64, integer bool) string {
+	if integer && fitsInt64(newRangeSegment(v, v)).IsTrue() {
+		return strconv.FormatInt(int64(v), 10)
+	}
+
+	switch {
+	case math.IsNaN(v):
+		if math.Signbit(v) {
+			return "-nan"
+		}
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+
+	s := fmt.Sprintf("%.14g", v)
+	// looks like an int
+	if strings.Trim(s, "-0123456789") == "" {
+		s += ".0"
+	}
+	return s
+}
+
+// ToString formats n as Lua tostring does (see formatLuaNumber). Result
+// is constant for constant n of known subtype, otherwise its length is
+// bounded by range.
+func (n Number) ToString() AbstractString {
+	if n.p.next != nil {
+		panic("next is unsupported yet")
+	}
+	if n.IsConstant() {
+		integer := n.IsInteger()
+		if integer.IsConstant() {
+			return NewStringConst(formatLuaNumber(n.p.val, integer.IsTrue()))
+		}
+		i, f := len(formatLuaNumber(n.p.val, true)), len(formatLuaNumber(n.p.val, false))
+		return newStringLen(min(i, f), max(i, f))
+	}
+
+	r := n.p.valRange
+	if n.IsInteger().IsFalse() || fitsInt64(r).IsFalse() {
+		// "0.5" at least
+		return newStringLen(3, luaMaxFloatLen)
+	} else if n.IsInteger().IsUnknown() || fitsInt64(r).IsUnknown() {
+		return newStringLen(1, luaMaxFloatLen)
+	}
+
+	// integers have no negative zero
+	r = r.ToIntegerRange()
+	l, h := len(formatLuaNumber(r.lVal, true)), len(formatLuaNumber(r.rVal, true))
+	if r.lVal <= 0 && r.rVal >= 0 {
+		return newStringLen(1, max(l, h))
+	} else if r.lVal > 0 {
+		return newStringLen(l, h)
+	}
+	return newStringLen(h, l)
+}
+
+// whitespace as of C isspace
+const luaSpaces = " \t\n\v\f\r"
+
+// ParseNumber converts s to Number following Lua tonumber. With base 0
+// s is a Lua numeral: decimal or hexadecimal integer or float. Otherwise
+// s is an integer in the given base, 2 to 36. Integers wrap around int64
+// as in Lua. ok is false when s is not a number or base is out of range,
+// Number is invalid then. String with unknown contents gives unknown
+// Number which may fail.
+func ParseNumber(s AbstractString, base int) (Number, Boolean) {
+	if base != 0 && (base < 2 || base > 36) {
+		return Number{}, _false
+	}
+
+	if !s.IsConstant() {
+		if base == 0 {
+			return NewNumber(), NewBoolean()
+		}
+		res, _ := NewIntegerRange(newRangeSegment(int64Range.lVal, int64Range.rVal))
+		return res, NewBoolean()
+	}
+
+	var v float64
+	var ok bool
+	if base == 0 {
+		v, ok = parseNumeral(strings.Trim(s.Value(), luaSpaces))
+	} else {
+		v, ok = parseBaseInteger(strings.Trim(s.Value(), luaSpaces), base)
+	}
+	if !ok {
+		return Number{}, _false
+	}
+	return NewNumberConst(v), _true
+}
+
+func cutSign(s string) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	return strings.TrimPrefix(s, "+"), false
+}
+
+func cutHexPrefix(s string) (string, bool) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return s[2:], true
+	}
+	return s, false
+}
+
+// digitValue returns 36 for non alphanumeric c, so it is never a digit
+func digitValue(c byte) uint64 {
+	switch {
+	case '0' <= c && c <= '9':
+		return uint64(c - '0')
+	case 'a' <= c && c <= 'z':
+		return uint64(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		return uint64(c-'A') + 10
+	}
+	return 36
+}
+
+func wrapInt64(v uint64, neg bool) float64 {
+	if neg {
+		v = -v
+	}
+	return float64(int64(v))
+}
+
+func parseNumeral(s string) (float64, bool) {
+	if v, ok := parseInteger(s); ok {
+		return v, true
+	}
+
+	// inf and nan are not numerals, underscores are not allowed either
+	if strings.ContainsAny(s, "nN_") {
+		return 0, false
+	}
+	// hexadecimal exponent is optional in Lua
+	if body, _ := cutSign(s); strings.ContainsAny(body, "xX") && !strings.ContainsAny(body, "pP") {
+		s += "p0"
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	return v, true
+}
+
+// parseInteger parses decimal or hexadecimal integer, decimals that
+// overflow int64 are floats
+func parseInteger(s string) (float64, bool) {
+	s, neg := cutSign(s)
+	s, hex := cutHexPrefix(s)
+	if s == "" {
+		return 0, false
+	}
+
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		d := digitValue(s[i])
+		if hex && d < 16 {
+			v = v*16 + d
+		} else if !hex && d < 10 {
+			if v > (math.MaxInt64-d)/10 && !(neg && v*10+d == -math.MinInt64) {
+				return 0, false
+			}
+			v = v*10 + d
+		} else {
+			return 0, false
+		}
+	}
+	return wrapInt64(v, neg), true
+}
+
+func parseBaseInteger(s string, base int) (float64, bool) {
+	s, neg := cutSign(s)
+	if s == "" {
+		return 0, false
+	}
+
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		d := digitValue(s[i])
+		if d >= uint64(base) {
+			return 0, false
+		}
+		v = v*uint64(base) + d
+	}
+	return wrapInt64(v, neg), true
+}
+
 func (n Number) Abs() Number {
 	if n.p.next != nil {
 		panic("next is unsupported yet")
@@ -1702,26 +2618,31 @@ func (n Number) Abs() Number {
 		newRange := n.p.valRange.Abs()
 		if newRange != n.p.valRange {
 			res := NewNumberRange(newRange)
+			if n.p.nan {
+				return res.OrNaN()
+			}
 			constraints := make([]NumberConstraint, 1)
 			if res.Greater(_zero).IsTrue() {
 				constraints[0] = NewNumberGreater(n)
 			} else {
 				constraints[0] = NewNumberGreaterEqual(n)
 			}
-			res.p.constraints = constraints
+			res.p.setConstraints(constraints)
 			return res
 		}
 	}
 	return n
 }
 
-func removeOnce(s []float64, val float64) []float64 {
-	for i, v := range s {
-		if v == val {
-			return append(s[:i], s[i+1:]...)
+// removeZeros removes both -0 and +0 from s
+func removeZeros(s []float64) []float64 {
+	res := s[:0]
+	for _, v := range s {
+		if v != 0 {
+			res = append(res, v)
 		}
 	}
-	return s
+	return res
 }
 
 func (n Number) Sign() Number {
@@ -1729,14 +2650,14 @@ func (n Number) Sign() Number {
 	canBeZero := !n.IsInteger().IsFalse()
 
 	if !canBeZero {
-		possibleSigns = removeOnce(possibleSigns, 0)
+		possibleSigns = removeZeros(possibleSigns)
 	}
 
 	var p, curr *NumberPrivate
 	for _, sign := range possibleSigns {
 		next := &NumberPrivate{
 			val:     sign,
-			integer: NewBooleanConst(BTrue, nil),
+			integer: _true,
 		}
 
 		if curr == nil {
@@ -1792,6 +2713,10 @@ func NewNumber() Number {
 }
 
 func NewNumberConst(v float64) Number {
+	if p := internedNumber(v); p != nil {
+		return Number{p: p}
+	}
+
 	is_integer := BUnknown
 	if !math.IsNaN(v) {
 		if v == math.Floor(v) {
@@ -1802,7 +2727,7 @@ func NewNumberConst(v float64) Number {
 	}
 	return Number{p: &NumberPrivate{
 		val:     v,
-		integer: NewBooleanConst(is_integer, nil),
+		integer: booleanConst(is_integer),
 	}}
 }
 
@@ -1828,3 +2753,27 @@ func NewNumberRange(rVec ...*NRange) Number {
 func NewNumberSegment(l, r float64) Number {
 	return NewNumberRange(newRangeSegment(l, r))
 }
+
+func NewNRange(lVal, rVal float64, lIncluding, rIncluding bool) *NRange {
+	return &NRange{
+		lVal: lVal,
+		rVal: rVal,
+
+		lIncluding: lIncluding,
+		rIncluding: rIncluding,
+	}
+}
+
+func NewIntegerRange(rVec ...*NRange) (Number, error) {
+	res := NewNumberRange(rVec...)
+	if res.IsConstant() {
+		if res.p.val != math.Floor(res.p.val) {
+			return Number{}, errors.New("no integer representation for NRange")
+		}
+		return res, nil
+	}
+
+	p := res.p.Clone()
+	p.integer = _true
+	return Number{p: p}.RangeAdjust()
+}
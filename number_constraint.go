@@ -1,151 +1,131 @@
 package virtual_types
 
-import "fmt"
-
-type NumberConstraint interface {
-	Equal(object interface{}) (BValue, error)
-	NotEqual(object interface{}) (BValue, error)
-	Inverse(subject interface{}) (NumberConstraint, error)
-	Name() string
-	Less(object interface{}) (BValue, error)
-	Greater(object interface{}) (BValue, error)
-	LessEqual(object interface{}) (BValue, error)
-	GreaterEqual(object interface{}) (BValue, error)
-	//Unbox() []interface{}
-}
-
-func errApplyInvalidNumber(action string) error {
-	return fmt.Errorf("could not apply %s constraint to non-Number value", action)
-}
-
-func not(val BValue, err error) (BValue, error) {
-	switch val {
-	case BTrue:
-		return BFalse, err
-	case BFalse:
-		return BTrue, err
-	}
-	return val, err
-}
-
-func or(l, r BValue) BValue {
-	if l == BTrue || r == BFalse {
-		return l
-	}
-	return r
-}
-
-func unknown(object interface{}, name string) (BValue, error) {
-	if _, ok := object.(*NumberPrivate); ok {
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber(name)
-}
+import "math"
 
 // ====== NumberOr ======
 
-type NumberOr struct {
-	variants []NumberConstraint
-}
+type NumberOr = Or[*NumberPrivate, NumberConstraint]
 
 func NewNumberOr(variants ...NumberConstraint) NumberOr {
-	variantsLen := len(variants)
-	if variantsLen < 2 {
-		panic("expected atleast 2 variants for NumberOr construction")
-	}
-	variantsArr := make([]NumberConstraint, variantsLen)
-	for i, v := range variants {
-		variantsArr[i] = v
-	}
-	return NumberOr{variants: variants}
+	return NewOr[*NumberPrivate](variants...)
 }
 
-func (_ NumberOr) Name() string {
-	return "NumberOr"
+// ====== NumberAnd ======
+
+type NumberAnd = And[*NumberPrivate, NumberConstraint]
+
+func NewNumberAnd(operands ...NumberConstraint) NumberAnd {
+	return NewAnd[*NumberPrivate](operands...)
 }
 
-func (c NumberOr) Equal(object interface{}) (BValue, error) {
-	result := BFalse
-	for _, v := range c.variants {
-		res, err := v.Equal(object)
-		if err != nil || res == BTrue {
-			return res, err
-		} else if res == BUnknown {
-			result = BUnknown
-		}
-	}
-	return result, nil
+// ====== NumberNot ======
+
+type NumberNot = Not[*NumberPrivate, NumberConstraint]
+
+func NewNumberNot(operand NumberConstraint) NumberNot {
+	return NewNot[*NumberPrivate](operand)
 }
 
-func (c NumberOr) NotEqual(object interface{}) (BValue, error) {
-	result := BFalse
-	for _, v := range c.variants {
-		res, err := v.NotEqual(object)
-		if err != nil || res == BTrue {
-			return res, err
-		} else if res == BUnknown {
-			result = BUnknown
+// ====== Order derivation ======
+
+// numberOrder is set of orders possible between two Numbers. Constraint
+// of each kind is set of orders between its holder and subject, query is
+// answered by composing it with order between subject and queried object:
+// holder >= subject and subject > 5 leave only holder > 5.
+type numberOrder uint8
+
+const (
+	orderLess numberOrder = 1 << iota
+	orderEqual
+	orderGreater
+
+	orderNotEqual     = orderLess | orderGreater
+	orderLessEqual    = orderLess | orderEqual
+	orderGreaterEqual = orderEqual | orderGreater
+	orderAny          = orderLess | orderEqual | orderGreater
+)
+
+// compose returns orders possible between a and c given a <o> b and b <n> c
+func (o numberOrder) compose(n numberOrder) numberOrder {
+	res := numberOrder(0)
+	for x := orderLess; x <= orderGreater; x <<= 1 {
+		for y := orderLess; y <= orderGreater; y <<= 1 {
+			if o&x == 0 || n&y == 0 {
+				continue
+			}
+			switch {
+			case x == orderEqual:
+				res |= y
+			case y == orderEqual || x == y:
+				res |= x
+			default:
+				res |= orderAny
+			}
 		}
 	}
-	return result, nil
+	return res
+}
+
+// answer tells whether possible orders satisfy query
+func (o numberOrder) answer(query numberOrder) BValue {
+	switch {
+	case o == 0:
+		// contradicting constraint, nothing sound to say
+		return BUnknown
+	case o&^query == 0:
+		return BTrue
+	case o&query == 0:
+		return BFalse
+	}
+	return BUnknown
 }
 
-func (c NumberOr) Less(object interface{}) (BValue, error) {
-	result := BFalse
-	for _, v := range c.variants {
-		res, err := v.Less(object)
-		if err != nil || res == BTrue {
-			return res, err
-		} else if res == BUnknown {
-			result = BUnknown
+// rangeOrder returns orders possible between p and o judging by their
+// values only, constraints are not consulted so queries never recurse
+func rangeOrder(p, o *NumberPrivate) numberOrder {
+	if p == o {
+		if p.valRange == nil && p.next == nil && math.IsNaN(p.val) {
+			return orderAny
 		}
+		return orderEqual
 	}
-	return result, nil
-}
-
-func (c NumberOr) Greater(object interface{}) (BValue, error) {
-	result := BFalse
-	for _, v := range c.variants {
-		res, err := v.Greater(object)
-		if err != nil || res == BTrue {
-			return res, err
-		} else if res == BUnknown {
-			result = BUnknown
+	if p.valRange == nil && o.valRange == nil && p.next == nil && o.next == nil {
+		switch {
+		case math.IsNaN(p.val) || math.IsNaN(o.val):
+			return orderAny
+		case p.val < o.val:
+			return orderLess
+		case p.val > o.val:
+			return orderGreater
 		}
+		return orderEqual
 	}
-	return result, nil
-}
 
-func (c NumberOr) LessEqual(object interface{}) (BValue, error) {
-	lt, err := c.Less(object)
-	if err != nil || lt == BTrue {
-		return lt, err
+	// NaN is unordered, so no order is excluded
+	if p.isNaN() != BFalse || o.isNaN() != BFalse {
+		return orderAny
 	}
-
-	eq, err := c.Equal(object)
-	if err != nil || eq == BTrue {
-		return eq, err
+	pRange, oRange := p.hull(), o.hull()
+	if pRange == nil || oRange == nil {
+		return orderAny
 	}
-
-	return or(lt, eq), nil
-}
-
-func (c NumberOr) GreaterEqual(object interface{}) (BValue, error) {
-	gt, err := c.Greater(object)
-	if err != nil || gt == BTrue {
-		return gt, err
+	lt, gt := pRange.Less(oRange).p.val, oRange.Less(pRange).p.val
+	res := numberOrder(0)
+	if lt != BFalse {
+		res |= orderLess
 	}
-
-	eq, err := c.Equal(object)
-	if err != nil || eq == BTrue {
-		return eq, err
+	if gt != BFalse {
+		res |= orderGreater
 	}
-
-	return or(gt, eq), nil
+	if lt != BTrue && gt != BTrue {
+		res |= orderEqual
+	}
+	return res
 }
 
-func (c NumberOr) Inverse(object interface{}) (NumberConstraint, error) {
-	panic("NYI")
+// deriveOrder answers whether holder <query> object given holder <rel> subject
+func deriveOrder(rel numberOrder, subject, object *NumberPrivate, query numberOrder) (BValue, error) {
+	return rel.compose(rangeOrder(subject, object)).answer(query), nil
 }
 
 // ====== NumberEqual ======
@@ -162,29 +142,37 @@ func (_ NumberEqual) Name() string {
 	return "NumberEqual"
 }
 
-func (c NumberEqual) Equal(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber("NumberEqual")
+func (c NumberEqual) Subject() Number {
+	return Number{p: c.subject}
 }
 
-func (c NumberEqual) NotEqual(object interface{}) (BValue, error)     { return not(c.Equal(object)) }
-func (c NumberEqual) Less(object interface{}) (BValue, error)         { return not(c.Equal(object)) }
-func (c NumberEqual) Greater(object interface{}) (BValue, error)      { return not(c.Equal(object)) }
-func (c NumberEqual) LessEqual(object interface{}) (BValue, error)    { return c.Equal(object) }
-func (c NumberEqual) GreaterEqual(object interface{}) (BValue, error) { return c.Equal(object) }
+func (c NumberEqual) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderEqual)
+}
 
-func (c NumberEqual) Inverse(subject interface{}) (NumberConstraint, error) {
-	panic("NYI")
+func (c NumberEqual) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderNotEqual)
 }
 
-/*func (c NumberEqual) Unbox() []interface{} {
-	panic("NYI")
-}*/
+func (c NumberEqual) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderLess)
+}
+
+func (c NumberEqual) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderGreater)
+}
+
+func (c NumberEqual) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderLessEqual)
+}
+
+func (c NumberEqual) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderEqual, c.subject, object, orderGreaterEqual)
+}
+
+func (c NumberEqual) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberEqual{subject: subject}, nil
+}
 
 // ====== NumberNotEqual ======
 
@@ -200,24 +188,36 @@ func (_ NumberNotEqual) Name() string {
 	return "NumberNotEqual"
 }
 
-func (c NumberNotEqual) Equal(object interface{}) (BValue, error) { return not(c.NotEqual(object)) }
+func (c NumberNotEqual) Subject() Number {
+	return Number{p: c.subject}
+}
 
-func (c NumberNotEqual) NotEqual(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber("NumberNotEqual")
+func (c NumberNotEqual) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderEqual)
+}
+
+func (c NumberNotEqual) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderNotEqual)
+}
+
+func (c NumberNotEqual) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderLess)
+}
+
+func (c NumberNotEqual) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderGreater)
+}
+
+func (c NumberNotEqual) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderLessEqual)
 }
 
-func (_ NumberNotEqual) Less(object interface{}) (BValue, error) {
-	return unknown(object, "NumberNotEqual")
+func (c NumberNotEqual) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderNotEqual, c.subject, object, orderGreaterEqual)
 }
 
-func (_ NumberNotEqual) Greater(object interface{}) (BValue, error) {
-	return unknown(object, "NumberNotEqual")
+func (c NumberNotEqual) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberNotEqual{subject: subject}, nil
 }
 
 // ====== NumberLess ======
@@ -234,25 +234,36 @@ func (_ NumberLess) Name() string {
 	return "NumberLess"
 }
 
-func (c NumberLess) Equal(object interface{}) (BValue, error)    { return not(c.Less(object)) }
-func (c NumberLess) NotEqual(object interface{}) (BValue, error) { return c.Less(object) }
+func (c NumberLess) Subject() Number {
+	return Number{p: c.subject}
+}
 
-func (c NumberLess) Less(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber("NumberLess")
+func (c NumberLess) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderEqual)
 }
 
-func (c NumberLess) Greater(object interface{}) (BValue, error)      { return not(c.Less(object)) }
-func (c NumberLess) LessEqual(object interface{}) (BValue, error)    { return c.Less(object) }
-func (c NumberLess) GreaterEqual(object interface{}) (BValue, error) { return not(c.Less(object)) }
+func (c NumberLess) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderNotEqual)
+}
+
+func (c NumberLess) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderLess)
+}
 
-func (c NumberLess) Inverse(subject interface{}) (NumberConstraint, error) {
-	panic("NYI")
+func (c NumberLess) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderGreater)
+}
+
+func (c NumberLess) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderLessEqual)
+}
+
+func (c NumberLess) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLess, c.subject, object, orderGreaterEqual)
+}
+
+func (c NumberLess) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberGreater{subject: subject}, nil
 }
 
 // ====== NumberLessEqual ======
@@ -269,38 +280,36 @@ func (_ NumberLessEqual) Name() string {
 	return "NumberLessEqual"
 }
 
-func (_ NumberLessEqual) Equal(object interface{}) (BValue, error) {
-	return unknown(object, "NumberLessEqual")
+func (c NumberLessEqual) Subject() Number {
+	return Number{p: c.subject}
 }
 
-func (_ NumberLessEqual) NotEqual(object interface{}) (BValue, error) {
-	return unknown(object, "NumberLessEqual")
+func (c NumberLessEqual) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderEqual)
 }
 
-func (_ NumberLessEqual) Less(object interface{}) (BValue, error) {
-	return unknown(object, "NumberLessEqual")
+func (c NumberLessEqual) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderNotEqual)
 }
 
-func (c NumberLessEqual) Greater(object interface{}) (BValue, error) {
-	return not(c.LessEqual(object))
+func (c NumberLessEqual) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderLess)
 }
 
-func (c NumberLessEqual) LessEqual(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber("NumberLessEqual")
+func (c NumberLessEqual) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderGreater)
+}
+
+func (c NumberLessEqual) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderLessEqual)
 }
 
-func (_ NumberLessEqual) GreaterEqual(object interface{}) (BValue, error) {
-	return unknown(object, "NumberLessEqual")
+func (c NumberLessEqual) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderLessEqual, c.subject, object, orderGreaterEqual)
 }
 
-func (c NumberLessEqual) Inverse(subject interface{}) (NumberConstraint, error) {
-	panic("NYI")
+func (c NumberLessEqual) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberGreaterEqual{subject: subject}, nil
 }
 
 // ====== NumberGreater ======
@@ -317,31 +326,37 @@ func (_ NumberGreater) Name() string {
 	return "NumberGreater"
 }
 
-func (c NumberGreater) Equal(object interface{}) (BValue, error)    { return not(c.Greater(object)) }
-func (c NumberGreater) NotEqual(object interface{}) (BValue, error) { return c.Greater(object) }
-func (c NumberGreater) Less(object interface{}) (BValue, error)     { return not(c.Greater(object)) }
+func (c NumberGreater) Subject() Number {
+	return Number{p: c.subject}
+}
 
-func (c NumberGreater) Greater(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
-		return BUnknown, nil
-	}
-	return -1, errApplyInvalidNumber("NumberGreater")
+func (c NumberGreater) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderEqual)
 }
 
-func (c NumberGreater) LessEqual(object interface{}) (BValue, error)    { return not(c.Greater(object)) }
-func (c NumberGreater) GreaterEqual(object interface{}) (BValue, error) { return c.Greater(object) }
+func (c NumberGreater) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderNotEqual)
+}
+
+func (c NumberGreater) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderLess)
+}
+
+func (c NumberGreater) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderGreater)
+}
+
+func (c NumberGreater) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderLessEqual)
+}
 
-func (c NumberGreater) Inverse(subject interface{}) (NumberConstraint, error) {
-	panic("NYI")
+func (c NumberGreater) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreater, c.subject, object, orderGreaterEqual)
 }
 
-/*func (c NumberGreater) Unbox() []interface{} {
-	panic("NYI")
-	return nil
-}*/
+func (c NumberGreater) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberLess{subject: subject}, nil
+}
 
 // ====== NumberGreaterEqual ======
 
@@ -357,36 +372,99 @@ func (_ NumberGreaterEqual) Name() string {
 	return "NumberGreaterEqual"
 }
 
-func (_ NumberGreaterEqual) Equal(object interface{}) (BValue, error) {
-	return unknown(object, "NumberGreaterEqual")
+func (c NumberGreaterEqual) Subject() Number {
+	return Number{p: c.subject}
 }
 
-func (_ NumberGreaterEqual) NotEqual(object interface{}) (BValue, error) {
-	return unknown(object, "NumberGreaterEqual")
+func (c NumberGreaterEqual) Equal(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderEqual)
 }
 
-func (c NumberGreaterEqual) Less(object interface{}) (BValue, error) {
-	return not(c.GreaterEqual(object))
+func (c NumberGreaterEqual) NotEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderNotEqual)
 }
 
-func (_ NumberGreaterEqual) Greater(object interface{}) (BValue, error) {
-	return unknown(object, "NumberGreaterEqual")
+func (c NumberGreaterEqual) Less(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderLess)
 }
 
-func (_ NumberGreaterEqual) LessEqual(object interface{}) (BValue, error) {
-	return unknown(object, "NumberGreaterEqual")
+func (c NumberGreaterEqual) Greater(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderGreater)
 }
 
-func (c NumberGreaterEqual) GreaterEqual(object interface{}) (BValue, error) {
-	if obj, ok := object.(*NumberPrivate); ok {
-		if c.subject == obj {
-			return BTrue, nil
-		}
+func (c NumberGreaterEqual) LessEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderLessEqual)
+}
+
+func (c NumberGreaterEqual) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return deriveOrder(orderGreaterEqual, c.subject, object, orderGreaterEqual)
+}
+
+func (c NumberGreaterEqual) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NumberLessEqual{subject: subject}, nil
+}
+
+// ====== NumberSelect ======
+
+// NumberSelect binds Number to then when cond holds and to els otherwise,
+// see Select.
+type NumberSelect struct {
+	cond *BooleanPrivate
+	then *NumberPrivate
+	els  *NumberPrivate
+}
+
+func NewNumberSelect(cond Boolean, then, els Number) NumberSelect {
+	return NumberSelect{cond: cond.p, then: then.p, els: els.p}
+}
+
+func (_ NumberSelect) Name() string {
+	return "NumberSelect"
+}
+
+func (c NumberSelect) Operands() (Boolean, Number, Number) {
+	return Boolean{p: c.cond}, Number{p: c.then}, Number{p: c.els}
+}
+
+// branches answers query when both branches agree on it
+func (c NumberSelect) branches(object *NumberPrivate, query numberOrder) (BValue, error) {
+	then, _ := deriveOrder(orderEqual, c.then, object, query)
+	els, _ := deriveOrder(orderEqual, c.els, object, query)
+	if then != els {
 		return BUnknown, nil
 	}
-	return -1, errApplyInvalidNumber("NumberGreaterEqual")
+	return then, nil
+}
+
+func (c NumberSelect) Equal(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderEqual)
+}
+
+func (c NumberSelect) NotEqual(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderNotEqual)
+}
+
+func (c NumberSelect) Less(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderLess)
+}
+
+func (c NumberSelect) Greater(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderGreater)
+}
+
+func (c NumberSelect) LessEqual(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderLessEqual)
+}
+
+func (c NumberSelect) GreaterEqual(object *NumberPrivate) (BValue, error) {
+	return c.branches(object, orderGreaterEqual)
 }
 
-func (c NumberGreaterEqual) Inverse(subject interface{}) (NumberConstraint, error) {
-	panic("NYI")
+// Inverse holder is then or els, which one is unknown: then is subject when
+// cond holds and itself otherwise, els is itself when cond holds
+func (c NumberSelect) Inverse(subject *NumberPrivate) (Constraint[*NumberPrivate], error) {
+	return NewNumberOr(
+		NumberSelect{cond: c.cond, then: subject, els: c.then},
+		NumberSelect{cond: c.cond, then: c.els, els: subject},
+	), nil
 }
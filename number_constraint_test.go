@@ -0,0 +1,237 @@
+package virtual_types
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"testing"
+)
+
+type NumberConstraintSuite struct {
+	suite.Suite
+}
+
+var numberConstraintKinds = []struct {
+	name  string
+	make  func(Number) NumberConstraint
+	holds func(h, s float64) bool
+}{
+	{"Equal", func(s Number) NumberConstraint { return NewNumberEqual(s) }, func(h, s float64) bool { return h == s }},
+	{"NotEqual", func(s Number) NumberConstraint { return NewNumberNotEqual(s) }, func(h, s float64) bool { return h != s }},
+	{"Less", func(s Number) NumberConstraint { return NewNumberLess(s) }, func(h, s float64) bool { return h < s }},
+	{"LessEqual", func(s Number) NumberConstraint { return NewNumberLessEqual(s) }, func(h, s float64) bool { return h <= s }},
+	{"Greater", func(s Number) NumberConstraint { return NewNumberGreater(s) }, func(h, s float64) bool { return h > s }},
+	{"GreaterEqual", func(s Number) NumberConstraint { return NewNumberGreaterEqual(s) }, func(h, s float64) bool { return h >= s }},
+}
+
+var numberConstraintQueries = []struct {
+	name  string
+	ask   func(c NumberConstraint, o *NumberPrivate) (BValue, error)
+	holds func(h, o float64) bool
+}{
+	{"Equal", NumberConstraint.Equal, func(h, o float64) bool { return h == o }},
+	{"NotEqual", NumberConstraint.NotEqual, func(h, o float64) bool { return h != o }},
+	{"Less", NumberConstraint.Less, func(h, o float64) bool { return h < o }},
+	{"Greater", NumberConstraint.Greater, func(h, o float64) bool { return h > o }},
+	{"LessEqual", NumberConstraint.LessEqual, func(h, o float64) bool { return h <= o }},
+	{"GreaterEqual", NumberConstraint.GreaterEqual, func(h, o float64) bool { return h >= o }},
+}
+
+// holder <kind> subject, queried about subject itself
+func (s *NumberConstraintSuite) TestIdentityTable() {
+	assert := assert.New(s.T())
+
+	const T, F, U = BTrue, BFalse, BUnknown
+	expected := [][]BValue{
+		//  ==  !=  <  >  <=  >=
+		{T, F, F, F, T, T}, // Equal
+		{F, T, U, U, U, U}, // NotEqual
+		{F, T, T, F, T, F}, // Less
+		{U, U, U, F, T, U}, // LessEqual
+		{F, T, F, T, F, T}, // Greater
+		{U, U, F, U, U, T}, // GreaterEqual
+	}
+
+	subject := NewNumber()
+	for i, k := range numberConstraintKinds {
+		c := k.make(subject)
+		assert.Equal("Number"+k.name, c.Name())
+		for j, q := range numberConstraintQueries {
+			res, err := q.ask(c, subject.p)
+			assert.Nil(err)
+			assert.Equal(expected[i][j], res, k.name+" "+q.name)
+		}
+	}
+}
+
+// every decided answer must agree with all holders satisfying constraint
+func (s *NumberConstraintSuite) TestTruthTable() {
+	assert := assert.New(s.T())
+
+	values := func(n Number) []float64 {
+		if n.p.valRange == nil {
+			return []float64{n.p.val}
+		}
+		var res []float64
+		for v := -2.0; v <= 4; v += 0.5 {
+			if ok, _ := n.p.valRange.Contains(v); ok {
+				res = append(res, v)
+			}
+		}
+		return res
+	}
+	numbers := []Number{
+		NewNumberConst(0),
+		NewNumberConst(1),
+		NewNumberSegment(0, 1),
+		NewNumberRange(NewNRange(1, 2, false, true)),
+		NewNumberRange(NewNRange(-1, 1, true, false)),
+	}
+	holders := []float64{-3, -2, -1.5, -1, -0.5, 0, 0.25, 0.5, 0.75, 1, 1.5, 2, 2.5, 3, 4, 5}
+
+	for _, k := range numberConstraintKinds {
+		for si, subject := range numbers {
+			c := k.make(subject)
+			for oi, object := range append([]Number{subject}, numbers...) {
+				if oi > 0 && oi-1 == si {
+					continue
+				}
+				for _, q := range numberConstraintQueries {
+					res, err := q.ask(c, object.p)
+					assert.Nil(err)
+
+					sometimes, always := false, true
+					for _, sv := range values(subject) {
+						for _, h := range holders {
+							if !k.holds(h, sv) {
+								continue
+							}
+							ovs := values(object)
+							if oi == 0 {
+								ovs = []float64{sv}
+							}
+							for _, ov := range ovs {
+								if q.holds(h, ov) {
+									sometimes = true
+								} else {
+									always = false
+								}
+							}
+						}
+					}
+
+					msg := fmt.Sprintf("h %s %s, h %s %s", k.name, subject, q.name, object)
+					switch res {
+					case BTrue:
+						assert.True(always, msg)
+					case BFalse:
+						assert.False(sometimes, msg)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *NumberConstraintSuite) TestRanges() {
+	assert := assert.New(s.T())
+
+	// x >= y and y > 5 => x > 5
+	y := NewNumberRange(NewNRange(5, math.Inf(1), false, true))
+	x := NewNumber()
+	x.p.constraints = []NumberConstraint{NewNumberGreaterEqual(y)}
+	five := NewNumberConst(5)
+	assert.True(x.Greater(five).IsTrue())
+	assert.True(x.GreaterEqual(five).IsTrue())
+	assert.True(x.LessEqual(five).IsFalse())
+	assert.True(x.Equal(five).IsFalse())
+	assert.True(x.Greater(NewNumberConst(6)).IsUnknown())
+
+	// x < y and y <= 0 => x < 0
+	y = NewNumberRange(NewNRange(math.Inf(-1), 0, true, true))
+	x = NewNumber()
+	x.p.constraints = []NumberConstraint{NewNumberLess(y)}
+	zero := NewNumberConst(0)
+	assert.True(x.Less(zero).IsTrue())
+	assert.True(zero.Greater(x).IsTrue())
+	assert.True(x.Less(NewNumberConst(-1)).IsUnknown())
+
+	// x != y says nothing unless y is known
+	x = NewNumber()
+	x.p.constraints = []NumberConstraint{NewNumberNotEqual(NewNumberConst(3))}
+	assert.True(x.Equal(NewNumberConst(3)).IsFalse())
+	assert.True(x.Equal(NewNumberConst(4)).IsUnknown())
+
+	// NaN subject implies nothing
+	x = NewNumber()
+	x.p.constraints = []NumberConstraint{NewNumberLess(NewNumberConst(math.NaN()))}
+	assert.True(x.Less(NewNumberConst(0)).IsUnknown())
+}
+
+func (s *NumberConstraintSuite) TestInverse() {
+	assert := assert.New(s.T())
+
+	h, subject := NewNumber(), NewNumber()
+	for _, c := range []struct {
+		c   NumberConstraint
+		inv string
+	}{
+		{NewNumberEqual(subject), "NumberEqual"},
+		{NewNumberNotEqual(subject), "NumberNotEqual"},
+		{NewNumberLess(subject), "NumberGreater"},
+		{NewNumberLessEqual(subject), "NumberGreaterEqual"},
+		{NewNumberGreater(subject), "NumberLess"},
+		{NewNumberGreaterEqual(subject), "NumberLessEqual"},
+	} {
+		inv, err := c.c.Inverse(h.p)
+		assert.Nil(err)
+		assert.Equal(c.inv, inv.Name())
+		assert.True(inv.(interface{ Subject() Number }).Subject() == h)
+
+		// holder <rel> subject seen from subject side
+		for _, q := range numberConstraintQueries[2:] {
+			res, _ := q.ask(c.c, subject.p)
+			var mirrored BValue
+			switch q.name {
+			case "Less":
+				mirrored, _ = inv.(NumberConstraint).Greater(h.p)
+			case "Greater":
+				mirrored, _ = inv.(NumberConstraint).Less(h.p)
+			case "LessEqual":
+				mirrored, _ = inv.(NumberConstraint).GreaterEqual(h.p)
+			case "GreaterEqual":
+				mirrored, _ = inv.(NumberConstraint).LessEqual(h.p)
+			}
+			assert.Equal(res, mirrored, c.inv+" "+q.name)
+		}
+	}
+
+	or := NewNumberOr(NewNumberLess(subject), NewNumberEqual(subject))
+	inv, err := or.Inverse(h.p)
+	assert.Nil(err)
+	res, _ := inv.(NumberConstraint).GreaterEqual(h.p)
+	assert.Equal(BTrue, res)
+
+	// holder of Select inverse is either branch
+	cond := NewBoolean()
+	a, b := NewNumberSegment(0, 5), NewNumberSegment(3, 8)
+	or = NewNumberOr(NewNumberSelect(cond, a, b), NewNumberLess(subject))
+	inv, err = or.Inverse(h.p)
+	assert.Nil(err)
+	variants := inv.(NumberOr).Variants()
+	assert.Equal("NumberOr", variants[0].Name())
+	assert.Equal("NumberGreater", variants[1].Name())
+	for _, v := range variants[0].(NumberOr).Variants() {
+		c, then, els := v.(NumberSelect).Operands()
+		assert.True(c == cond)
+		assert.True(then == h || els == h)
+		assert.True(then == b || els == a)
+	}
+	res, _ = inv.(NumberConstraint).Equal(h.p)
+	assert.Equal(BUnknown, res)
+}
+
+func TestNumberConstraint(t *testing.T) {
+	suite.Run(t, new(NumberConstraintSuite))
+}
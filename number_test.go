@@ -269,6 +269,12 @@ func (s *NumberSuite) TestLessEqualRange() {
 
 	assert.True(s.OneFiveSeg.LessEqual(s.ZeroOneSegOpen).IsFalse())
 	assert.True(s.ZeroOneSegOpen.LessEqual(s.OneFiveSeg).IsTrue())
+
+	// constant on including edge
+	assert.True(s.One.LessEqual(s.OneFiveSeg).IsTrue())
+	assert.True(s.OneFiveSeg.LessEqual(s.Five).IsTrue())
+	assert.True(s.OneFiveSeg.LessEqual(s.One).IsUnknown())
+	assert.True(s.Five.LessEqual(s.OneFiveSeg).IsUnknown())
 }
 
 func (s *NumberSuite) TestGreaterEqualSimple() {
@@ -305,6 +311,12 @@ func (s *NumberSuite) TestGreaterEqualRange() {
 
 	assert.True(s.OneFiveSeg.GreaterEqual(s.ZeroOneSegOpen).IsTrue())
 	assert.True(s.ZeroOneSegOpen.GreaterEqual(s.OneFiveSeg).IsFalse())
+
+	// constant on including edge
+	assert.True(s.Five.GreaterEqual(s.OneFiveSeg).IsTrue())
+	assert.True(s.OneFiveSeg.GreaterEqual(s.One).IsTrue())
+	assert.True(s.OneFiveSeg.GreaterEqual(s.Five).IsUnknown())
+	assert.True(s.One.GreaterEqual(s.OneFiveSeg).IsUnknown())
 }
 
 func (s *NumberSuite) TestAddSimple() {
@@ -481,15 +493,16 @@ func (s *NumberSuite) TestSubSameEdgeCases() {
 	assert.Nil(err)
 	assert.Equal(NewNumberSegment(-4, 4), withoutIntegerConstrints(minus_4_to_4_seg))
 
+	// inf - inf is NaN
 	zero, err = s.Unknown.Sub(s.Unknown_copy)
 
 	assert.Nil(err)
-	assert.Equal(s.Zero, zero)
+	assert.True(s.Zero.OrNaN().IsSame(zero))
 
 	unknown, err := s.Unknown.Sub(s.Unknown_other)
 
 	assert.Nil(err)
-	assert.Equal(s.Unknown, withoutIntegerConstrints(unknown))
+	assert.True(s.Unknown.OrNaN().IsSame(unknown))
 	assert.True(unknown.Equal(s.Unknown).IsUnknown())
 	assert.True(unknown.Equal(s.Unknown_other).IsUnknown())
 }
@@ -597,15 +610,16 @@ func (s *NumberSuite) TestMulOneEdgeCases() {
 func (s *NumberSuite) TestMulZeroEdgeCases() {
 	assert := assert.New(s.T())
 
+	// 0 * inf is NaN
 	zero, err := s.Zero.Mul(s.Unknown)
 
 	assert.Nil(err)
-	assert.Equal(s.Zero, zero)
+	assert.True(s.Zero.OrNaN().IsSame(zero))
 
 	zero, err = s.Unknown.Mul(s.Zero)
 
 	assert.Nil(err)
-	assert.Equal(s.Zero, zero)
+	assert.True(s.Zero.OrNaN().IsSame(zero))
 
 	zero, err = s.Zero.Mul(s.OneFiveSeg)
 
@@ -655,10 +669,11 @@ func (s *NumberSuite) TestDivPositive() {
 	assert.False(s.Positive.p == positive.p)
 	//assert.True(positive.Less(s.Positive).IsTrue())
 
+	// inf / inf is NaN
 	positive, err = s.Positive.Div(s.Positive_other)
 
 	assert.Nil(err)
-	assert.Equal(s.Positive, positive)
+	assert.True(s.Positive.OrNaN().IsSame(positive))
 	assert.False(s.Positive.p == positive.p)
 	assert.True(positive.Less(s.Positive).IsUnknown())
 }
@@ -726,26 +741,88 @@ func (s *NumberSuite) TestDivOneEdgeCases() {
 func (s *NumberSuite) TestDivZeroEdgeCases() {
 	assert := assert.New(s.T())
 
-	invalid_num, err := s.One.Div(s.Zero)
+	inf, err := s.One.Div(s.Zero)
 
-	if assert.NotNil(err) {
-		assert.Equal("division by zero", err.Error())
-	}
-	assert.False(invalid_num.IsValid())
+	assert.Nil(err)
+	assert.True(s.Inf.IsSame(inf))
 
-	invalid_num, err = s.OneFiveSeg.Div(s.Zero)
+	minus_inf, err := s.One.Div(NewNumberConst(math.Copysign(0, -1)))
 
-	if assert.NotNil(err) {
-		assert.Equal("division by zero", err.Error())
-	}
-	assert.False(invalid_num.IsValid())
+	assert.Nil(err)
+	assert.True(s.Inf.Negate().IsSame(minus_inf))
 
-	invalid_num, err = s.ZeroOneSegOpen.Div(s.Zero)
+	inf, err = s.OneFiveSeg.Div(s.Zero)
 
-	if assert.NotNil(err) {
-		assert.Equal("division by zero", err.Error())
-	}
-	assert.False(invalid_num.IsValid())
+	assert.Nil(err)
+	assert.True(s.Inf.IsSame(inf))
+
+	// 0 / 0 is NaN
+	inf, err = s.ZeroOneSegOpen.Div(s.Zero)
+
+	assert.Nil(err)
+	assert.True(s.Inf.OrNaN().IsSame(inf))
+}
+
+func (s *NumberSuite) TestDivSignedZero() {
+	assert := assert.New(s.T())
+
+	negZero := NewNumberConst(math.Copysign(0, -1))
+
+	minus_inf_to_minus_one, err := s.One.Div(NewNumberSegment(-1, negZero.p.val))
+
+	assert.Nil(err)
+	assert.True(NewNumberSegment(math.Inf(-1), -1).IsSame(minus_inf_to_minus_one))
+
+	one_to_inf, err := s.One.Div(s.ZeroOneSeg)
+
+	assert.Nil(err)
+	assert.True(NewNumberSegment(1, math.Inf(1)).IsSame(one_to_inf))
+
+	minus_inf_to_minus_one, err = s.One.Negate().Div(s.ZeroOneSeg)
+
+	assert.Nil(err)
+	assert.True(NewNumberSegment(math.Inf(-1), -1).IsSame(minus_inf_to_minus_one))
+
+	// +0 right edge holds -0 as well
+	unknown, err := s.One.Div(NewNumberSegment(-1, 0))
+
+	assert.Nil(err)
+	assert.True(s.Unknown.IsSame(unknown))
+
+	unknown, err = s.One.Div(s.MinusTenTenSeg)
+
+	assert.Nil(err)
+	assert.True(s.Unknown.IsSame(unknown))
+
+	// 1 / (0, 1] may overflow to inf
+	one_to_inf, err = s.One.Div(NewNumberRange(NewNRange(0, 1, false, true)))
+
+	assert.Nil(err)
+	assert.True(NewNumberSegment(1, math.Inf(1)).IsSame(one_to_inf))
+
+	zero, err := s.Zero.Div(s.OneFiveSeg)
+
+	assert.Nil(err)
+	assert.True(s.Zero.IsSame(zero))
+
+	neg_zero, err := s.Zero.Div(s.OneFiveSeg.Negate())
+
+	assert.Nil(err)
+	assert.True(negZero.IsSame(neg_zero))
+	assert.False(s.Zero.IsSame(neg_zero))
+	assert.True(s.Zero.Equal(neg_zero).IsTrue())
+
+	zeros := s.Zero.Join(negZero)
+	assert.Equal("[-0, 0] int", zeros.String())
+	assert.Equal("-0 | 0", zeros.Sign().String())
+
+	unknown, err = s.One.Div(zeros)
+
+	assert.Nil(err)
+	assert.True(s.Unknown.IsSame(unknown))
+
+	assert.Equal("-0", negZero.Sign().String())
+	assert.Equal("0 | 1", s.ZeroOneSeg.Sign().String())
 }
 
 func (s *NumberSuite) TestDivSameEdgeCases() {
@@ -765,7 +842,7 @@ func (s *NumberSuite) TestDivSameEdgeCases() {
 	one, err = s.Unknown.Div(s.Unknown_copy)
 
 	assert.Nil(err)
-	assert.Equal(s.One, one)
+	assert.True(s.One.OrNaN().IsSame(one))
 }
 
 func (s *NumberSuite) TestPowSimple() {
@@ -920,12 +997,12 @@ func (s *NumberSuite) TestIDivSimple() {
 	unknownInt.p.integer = NewBooleanConst(BTrue, nil)
 
 	assert.Nil(err)
-	assert.Equal(unknownInt, unknown_int)
+	assert.True(unknownInt.OrNaN().IsSame(unknown_int))
 
 	one, err := s.Unknown.IDiv(s.Unknown_copy)
 
 	assert.Nil(err)
-	assert.Equal(s.One, one)
+	assert.True(s.One.OrNaN().IsSame(one))
 }
 
 func (s *NumberSuite) TestIDivRange() {
@@ -1254,6 +1331,572 @@ func (s *NumberSuite) TestAbsRange() {
 	assert.True(positive.Less(s.Negative).IsFalse())
 }
 
+func (s *NumberSuite) TestJoin() {
+	assert := assert.New(s.T())
+
+	assert.True(s.One.Join(s.Five).p.valRange.IsSame(newRangeSegment(1, 5)))
+	assert.True(s.One.Join(s.Five).IsInteger().IsTrue())
+	assert.True(s.One.Join(s.One).IsSame(s.One))
+	assert.True(s.OneFiveSeg.Join(s.OneFiveSeg_copy) == s.OneFiveSeg)
+
+	assert.True(s.ZeroOneSegOpen.Join(s.TwoFourSegOpen).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: 4, rIncluding: false,
+	})))
+	assert.True(s.MinusTenTwoAndHalfSeg.Join(s.OneFiveSeg).IsSame(NewNumberSegment(-10, 5)))
+	assert.True(s.Negative.Join(s.Positive).IsUnknown())
+	assert.True(s.Zero.Join(s.Positive).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: math.Inf(1), rIncluding: true,
+	})))
+	assert.True(s.One.Join(NewNumberConst(math.NaN())).IsSame(s.One.OrNaN()))
+}
+
+func (s *NumberSuite) TestWiden() {
+	assert := assert.New(s.T())
+
+	assert.True(s.Zero.Widen(s.Zero).IsSame(s.Zero))
+	assert.True(s.ZeroOneSeg.Widen(s.ZeroOneSeg).IsSame(s.ZeroOneSeg))
+
+	widened := s.Zero.Widen(s.ZeroOneSeg)
+	assert.True(widened.IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: math.Inf(1), rIncluding: true,
+	})))
+	assert.True(widened.Widen(s.OneFiveSeg).IsSame(widened))
+
+	assert.True(s.OneFiveSeg.Widen(s.ZeroOneSeg).IsSame(NewNumberRange(&NRange{
+		lVal: math.Inf(-1), lIncluding: true,
+		rVal: 5, rIncluding: true,
+	})))
+	assert.True(s.ZeroOneSegOpen.Widen(s.ZeroOneSeg).IsSame(NewNumberRange(&NRange{
+		lVal: 0, lIncluding: true,
+		rVal: math.Inf(1), rIncluding: true,
+	})))
+	assert.True(s.ZeroOneSeg.Widen(s.ZeroOneSegOpen).IsSame(s.ZeroOneSeg))
+}
+
+func (s *NumberSuite) TestMaxMin() {
+	assert := assert.New(s.T())
+
+	// decided orderings return argument itself
+	assert.True(s.One.Max([]Number{s.Five}) == s.Five)
+	assert.True(s.Five.Min([]Number{s.One, s.ZeroOneSegOpen}) == s.ZeroOneSegOpen)
+	assert.True(MaxOf(s.ZeroOneSeg, s.TwoFourSegOpen, s.MinusOne) == s.TwoFourSegOpen)
+	assert.True(MaxOf(s.One) == s.One)
+	assert.True(s.One.Max([]Number{NewNumberConst(math.NaN())}).IsNaN().IsTrue())
+
+	a, b := NewNumberSegment(0, 5), NewNumberSegment(3, 8)
+	max := MaxOf(a, b)
+	assert.True(max.IsSame(NewNumberSegment(3, 8)))
+	assert.True(max != b)
+	assert.True(max.GreaterEqual(a).IsTrue())
+	assert.True(max.GreaterEqual(b).IsTrue())
+	assert.True(max.Less(a).IsFalse())
+	assert.True(max.Equal(b).IsUnknown())
+
+	min := MinOf(a, b)
+	assert.True(min.IsSame(NewNumberSegment(0, 5)))
+	assert.True(min.LessEqual(a).IsTrue())
+	assert.True(min.LessEqual(b).IsTrue())
+	assert.True(b.GreaterEqual(min).IsTrue())
+
+	// open edges
+	open := MaxOf(s.ZeroOneSegOpen, s.ZeroOneSeg)
+	assert.Equal("[0, 1]", open.String())
+	assert.Equal("[0, 1)", MinOf(s.ZeroOneSegOpen, s.ZeroOneSeg).String())
+
+	// every argument is linked, including ones decided on the way
+	c := NewNumberSegment(-10, -5)
+	max = MaxOf(c, a, b)
+	assert.True(max.IsSame(NewNumberSegment(3, 8)))
+	assert.Len(max.Constraints(), 3)
+	assert.True(max.Greater(c).IsTrue())
+
+	// result joins integer flags
+	i, err := NewIntegerRange(NewNRange(0, 5, true, true))
+	assert.Nil(err)
+	j, err := NewIntegerRange(NewNRange(3, 8, true, true))
+	assert.Nil(err)
+	assert.Equal("[3, 8] int", MaxOf(i, j).String())
+	assert.True(MaxOf(i, b).IsInteger().IsUnknown())
+
+	// single possible value is constant
+	assert.Equal("5", MaxOf(a, s.Five).String())
+	assert.True(s.Five.Constraints() == nil)
+
+	// chained numbers are compared by their hull
+	sign := s.MinusTenTenSeg.Sign()
+	max = MaxOf(sign, s.Zero)
+	assert.Equal("[0, 1] int", max.String())
+	assert.True(max.GreaterEqual(s.Zero).IsTrue())
+	assert.Equal("[3, 8]", sign.Max([]Number{b}).String())
+	assert.Equal("[-1, 0] int", s.Zero.Min([]Number{sign}).String())
+}
+
+func (s *NumberSuite) TestSelect() {
+	assert := assert.New(s.T())
+
+	a, b := NewNumberSegment(0, 5), NewNumberSegment(3, 8)
+	assert.True(Select(_true, a, b) == a)
+	assert.True(Select(_false, a, b) == b)
+	assert.True(Select(NewBoolean(), a, a) == a)
+
+	cond := NewBoolean()
+	sel := Select(cond, a, b)
+	assert.True(sel.IsSame(NewNumberSegment(0, 8)))
+	assert.Len(sel.Constraints(), 1)
+	c := sel.Constraints()[0].(NumberSelect)
+	cond2, then, els := c.Operands()
+	assert.True(cond2 == cond && then == a && els == b)
+
+	// branches of the same range are not the same value
+	a2 := NewNumberSegment(0, 5)
+	sel = Select(cond, a, a2)
+	assert.True(sel.IsSame(a))
+	assert.True(sel != a && sel != a2)
+	assert.True(sel.Equal(a).IsUnknown())
+
+	// answers both branches agree on
+	sel = Select(cond, s.One, s.Five)
+	assert.True(sel.Equal(NewNumberConst(3)).IsFalse())
+	assert.True(sel.Greater(s.Zero).IsTrue())
+	assert.True(sel.Equal(s.One).IsUnknown())
+
+	// same constants
+	assert.True(Select(cond, s.One, NewNumberConst(1)).IsSame(s.One))
+	assert.True(Select(cond, s.One, NewNumberConst(math.NaN())).IsSame(s.One.OrNaN()))
+}
+
+func (s *NumberSuite) TestClamp() {
+	assert := assert.New(s.T())
+
+	lo, hi := NewNumberConst(0), NewNumberConst(10)
+	assert.True(NewNumberConst(-3).Clamp(lo, hi) == lo)
+	assert.True(NewNumberConst(12).Clamp(lo, hi) == hi)
+	assert.Equal("5", s.Five.Clamp(lo, hi).String())
+	assert.Equal("[0, 10]", s.Unknown.Clamp(lo, hi).String())
+	assert.True(s.TwoAndHalfFourInterval.Clamp(lo, hi) == s.TwoAndHalfFourInterval)
+	assert.Equal("[0, 5]", s.MinusTenTenSeg.Clamp(lo, s.Five).String())
+
+	x := NewNumberSegment(-20, 20)
+	clamped := x.Clamp(lo, hi)
+	assert.True(clamped.LessEqual(hi).IsTrue())
+	assert.True(clamped.GreaterEqual(lo).IsTrue())
+
+	// hi wins over lo
+	assert.True(x.Clamp(hi, lo) == lo)
+}
+
+func (s *NumberSuite) TestNaN() {
+	assert := assert.New(s.T())
+
+	nan := NewNumberConst(math.NaN())
+	maybeNaN := NewNumberSegment(1, 2).OrNaN()
+	assert.True(nan.IsNaN().IsTrue())
+	assert.True(s.Unknown.IsNaN().IsFalse())
+	assert.True(maybeNaN.IsNaN().IsUnknown())
+	assert.True(nan.OrNaN() == nan)
+	assert.True(maybeNaN.OrNaN() == maybeNaN)
+	assert.Equal("[1, 2] | nan", maybeNaN.String())
+
+	// point range which may be NaN is not a constant
+	one := s.One.OrNaN()
+	assert.False(one.IsConstant())
+	assert.True(one.IsInteger().IsUnknown())
+	assert.Equal("[1, 1] | nan", one.String())
+
+	// chained numbers keep all their elements
+	chain := NewNumberRange(NewNRange(0, 1, true, true))
+	chain.p.next = NewNumberRange(NewNRange(5, 6, true, true)).p
+	assert.True(chain.OrNaN().IsNaN().IsUnknown())
+	assert.Equal("[0, 1] | [5, 6] | nan", chain.OrNaN().String())
+	assert.Equal("[0, 1] | [5, 6]", chain.String())
+	assert.Equal("[0, 1] | [5, 6] | nan", chain.Join(nan).String())
+	assert.Equal("[0, 1] | [5, 6] | nan", nan.Join(chain).String())
+
+	// NaN compares false
+	for _, o := range []Number{s.One, s.Unknown, maybeNaN, nan} {
+		assert.True(nan.Less(o).IsFalse())
+		assert.True(o.Less(nan).IsFalse())
+		assert.True(nan.LessEqual(o).IsFalse())
+		assert.True(o.GreaterEqual(nan).IsFalse())
+		assert.True(nan.Equal(o).IsFalse())
+		assert.True(o.Equal(nan).IsFalse())
+	}
+	le := nan.LessEqual(s.Unknown)
+	assert.Equal(ReasonNaN, le.Explain()[0].Kind)
+	assert.Equal("nan <= [-inf, inf] is false because NaN is unordered", le.Explain()[0].String())
+
+	// ranges decide only false
+	assert.True(maybeNaN.Less(s.Five).IsUnknown())
+	assert.True(maybeNaN.LessEqual(s.Two).IsUnknown())
+	assert.True(s.Two.GreaterEqual(maybeNaN).IsUnknown())
+	assert.True(maybeNaN.Greater(s.Five).IsFalse())
+	assert.True(maybeNaN.Equal(s.Five).IsFalse())
+	assert.True(maybeNaN.Less(maybeNaN).IsFalse())
+	assert.True(maybeNaN.LessEqual(maybeNaN).IsUnknown())
+	assert.True(maybeNaN.Equal(maybeNaN).IsUnknown())
+	assert.True(one.Equal(s.One).IsUnknown())
+}
+
+func (s *NumberSuite) TestNaNPropagation() {
+	assert := assert.New(s.T())
+
+	nan := NewNumberConst(math.NaN())
+	maybeNaN := NewNumberSegment(1, 2).OrNaN()
+
+	for _, op := range []ArithmeticOperationBinary{OpAdd{}, OpSub{}, OpMul{}, OpDiv{}, OpIDiv{}, OpPow{}} {
+		res, err := operator(NewNumberSegment(2, 5), nan, op)
+		assert.Nil(err)
+		assert.True(res.IsNaN().IsTrue())
+
+		res, err = operator(maybeNaN, s.Two, op)
+		assert.Nil(err)
+		assert.True(res.IsNaN().IsUnknown())
+	}
+
+	for _, c := range []struct {
+		x, y Number
+		op   ArithmeticOperationBinary
+		nan  bool
+	}{
+		{s.Unknown, s.Unknown_other, OpAdd{}, true},
+		{s.Positive, s.Positive_other, OpAdd{}, false},
+		{s.Unknown, s.One, OpAdd{}, false},
+		{s.Positive, s.Positive_other, OpSub{}, true},
+		{s.Positive, s.Negative, OpSub{}, false},
+		{s.Zero, s.Unknown, OpMul{}, true},
+		{s.ZeroOneSeg, s.OneFiveSeg, OpMul{}, false},
+		{s.Positive, s.Positive_other, OpDiv{}, true},
+		{s.OneFiveSeg, s.Positive, OpDiv{}, false},
+		{NewNumberSegment(-2, -1), s.ZeroOneSeg, OpPow{}, true},
+		{NewNumberSegment(-2, -1), s.Two, OpPow{}, false},
+		{NewNumberConst(math.Inf(-1)), s.ZeroOneSeg, OpPow{}, false},
+		{s.OneFiveSeg, s.ZeroOneSeg, OpPow{}, false},
+	} {
+		res, err := operator(c.x, c.y, c.op)
+		assert.Nil(err)
+		assert.Equal(c.nan, res.IsNaN().IsUnknown(), c.x.String(), c.y.String())
+	}
+
+	// 1 ^ NaN and NaN ^ 0 are 1
+	res, err := s.ZeroOneSeg.Pow(nan)
+	assert.Nil(err)
+	assert.True(res.IsSame(s.One.OrNaN()))
+	res, err = nan.Pow(s.ZeroOneSeg)
+	assert.Nil(err)
+	assert.True(res.IsSame(s.One.OrNaN()))
+	res, err = NewNumberSegment(2, 5).Pow(nan)
+	assert.Nil(err)
+	assert.True(res.IsNaN().IsTrue())
+
+	// results which may be NaN carry no order constraints
+	res, err = maybeNaN.Add(s.OneFiveSeg)
+	assert.Nil(err)
+	assert.Empty(res.Constraints())
+	assert.True(res.Greater(maybeNaN).IsUnknown())
+
+	assert.True(maybeNaN.Join(s.Five).IsSame(NewNumberSegment(1, 5).OrNaN()))
+	assert.True(maybeNaN.Floor().IsNaN().IsUnknown())
+	assert.True(maybeNaN.Abs().IsNaN().IsUnknown())
+	assert.True(MaxOf(maybeNaN, s.ZeroOneSeg).IsNaN().IsUnknown())
+	r, ok := maybeNaN.Round(RoundDown)
+	assert.Equal("[1, 2] | nan", r.String())
+	assert.True(ok.IsUnknown())
+	_, ok = maybeNaN.ToInteger()
+	assert.True(ok.IsUnknown())
+}
+
+func (s *NumberSuite) TestRound() {
+	assert := assert.New(s.T())
+
+	for _, c := range []struct {
+		mode RoundMode
+		in   float64
+		out  float64
+	}{
+		{RoundHalfAwayFromZero, 2.5, 3},
+		{RoundHalfAwayFromZero, -2.5, -3},
+		{RoundHalfEven, 2.5, 2},
+		{RoundHalfEven, 3.5, 4},
+		{RoundTowardZero, -2.7, -2},
+		{RoundDown, -2.2, -3},
+		{RoundUp, 2.2, 3},
+	} {
+		res, ok := NewNumberConst(c.in).Round(c.mode)
+		assert.Equal(c.out, res.p.val, c.in)
+		assert.True(res.IsInteger().IsTrue())
+		assert.True(ok.IsTrue())
+	}
+
+	res, ok := s.Five.Round(RoundHalfEven)
+	assert.True(res == s.Five && ok.IsTrue())
+	res, ok = NewNumberConst(math.NaN()).Round(RoundDown)
+	assert.True(res.IsNaN().IsTrue() && ok.IsFalse())
+	res, ok = s.Inf.Trunc()
+	assert.True(res == s.Inf && ok.IsFalse())
+	_, ok = NewNumberConst(math.Pow(2, 63)).Trunc()
+	assert.True(ok.IsFalse())
+	_, ok = NewNumberConst(-math.Pow(2, 63)).Trunc()
+	assert.True(ok.IsTrue())
+
+	// open edges
+	res, ok = s.TwoAndHalfFourInterval.Round(RoundHalfEven)
+	assert.Equal("[3, 4] int", res.String())
+	assert.True(ok.IsTrue())
+	res, _ = s.TwoAndHalfFourInterval.Round(RoundDown)
+	assert.Equal("[2, 3] int", res.String())
+	res, _ = s.ZeroOneSegOpen.Round(RoundUp)
+	assert.Equal("[0, 1] int", res.String())
+	res, _ = NewNumberRange(NewNRange(-1.5, 1.5, false, false)).Trunc()
+	assert.Equal("[-1, 1] int", res.String())
+	res, _ = NewNumberRange(NewNRange(0.2, 0.4, true, true)).Round(RoundHalfAwayFromZero)
+	assert.Equal("0", res.String())
+
+	res, ok = s.Unknown.Round(RoundDown)
+	assert.True(res.IsInteger().IsUnknown())
+	assert.True(ok.IsUnknown())
+	res, _ = NewNumberSegment(0, math.Inf(1)).Round(RoundDown)
+	assert.True(res.IsInteger().IsUnknown())
+	res, _ = NewNumberRange(NewNRange(0, math.Inf(1), true, false)).Round(RoundDown)
+	assert.True(res.IsInteger().IsTrue())
+	_, ok = NewNumberSegment(1e19, 1e20).Round(RoundDown)
+	assert.True(ok.IsFalse())
+}
+
+func (s *NumberSuite) TestToInteger() {
+	assert := assert.New(s.T())
+
+	res, ok := s.Five.ToInteger()
+	assert.True(res == s.Five && ok.IsTrue())
+	for _, v := range []float64{2.5, math.NaN(), math.Inf(1), math.Pow(2, 63)} {
+		res, ok = NewNumberConst(v).ToInteger()
+		assert.False(res.IsValid(), v)
+		assert.True(ok.IsFalse(), v)
+	}
+	res, ok = NewNumberConst(-math.Pow(2, 63)).ToInteger()
+	assert.True(res.IsValid() && ok.IsTrue())
+
+	res, ok = s.OneFiveSegInt.ToInteger()
+	assert.True(res == s.OneFiveSegInt && ok.IsTrue())
+
+	// might be non-integral
+	res, ok = s.ZeroOneSeg.ToInteger()
+	assert.Equal("[0, 1] int", res.String())
+	assert.True(ok.IsUnknown())
+	res, ok = s.TwoAndHalfFourInterval.ToInteger()
+	assert.Equal("3", res.String())
+	assert.True(ok.IsUnknown())
+	res, ok = NewNumberRange(NewNRange(2.2, 2.8, true, true)).ToInteger()
+	assert.False(res.IsValid())
+	assert.True(ok.IsFalse())
+
+	// might be out of range
+	i, err := NewIntegerRange(NewNRange(0, math.Inf(1), true, true))
+	assert.Nil(err)
+	res, ok = i.ToInteger()
+	assert.True(res.p.valRange.rVal < math.Pow(2, 63))
+	assert.True(ok.IsUnknown())
+
+}
+
+func (s *NumberSuite) TestToFloat() {
+	assert := assert.New(s.T())
+
+	one := NewNumberConst(1).ToFloat()
+	assert.True(one.IsInteger().IsFalse())
+	assert.True(one.IsConstant())
+	assert.Equal(1.0, one.p.val)
+	assert.True(NewNumberConst(1).IsInteger().IsTrue())
+
+	seg := s.ZeroOneSeg.ToFloat()
+	assert.True(seg.IsInteger().IsFalse())
+	assert.True(seg.p.valRange.IsSame(s.ZeroOneSeg.p.valRange))
+
+	nan := NewNumberSegment(0, 1).OrNaN().ToFloat()
+	assert.True(nan.IsNaN().IsUnknown())
+	assert.True(nan.IsInteger().IsFalse())
+
+	half := NewNumberConst(0.5)
+	assert.True(half.ToFloat() == half)
+}
+
+func (s *NumberSuite) TestParseNumber() {
+	assert := assert.New(s.T())
+
+	for _, c := range []struct {
+		in   string
+		base int
+		out  float64
+	}{
+		{"10", 0, 10},
+		{" \t-7\n", 0, -7},
+		{"+7", 0, 7},
+		{"0x1F", 0, 31},
+		{"-0X10", 0, -16},
+		{"0xffffffffffffffff", 0, -1},
+		{"9223372036854775807", 0, math.MaxInt64},
+		{"-9223372036854775808", 0, math.MinInt64},
+		{"9223372036854775808", 0, 9223372036854775808},
+		{"2.5", 0, 2.5},
+		{".5", 0, 0.5},
+		{"5.", 0, 5},
+		{"1e3", 0, 1000},
+		{"1E-2", 0, 0.01},
+		{"1e999", 0, math.Inf(1)},
+		{"0x1.8", 0, 1.5},
+		{"0x1p4", 0, 16},
+		{"0xA.8P-1", 0, 5.25},
+		{"ff", 16, 255},
+		{"  -FF ", 16, -255},
+		{"1010", 2, 10},
+		{"zz", 36, 1295},
+		{"10", 10, 10},
+	} {
+		res, ok := ParseNumber(NewStringConst(c.in), c.base)
+		assert.True(ok.IsTrue(), c.in)
+		assert.True(res.IsConstant(), c.in)
+		assert.Equal(c.out, res.p.val, c.in)
+	}
+
+	for _, c := range []struct {
+		in   string
+		base int
+	}{
+		{"", 0},
+		{"  ", 0},
+		{"abc", 0},
+		{"1 2", 0},
+		{"--1", 0},
+		{"+-1", 0},
+		{"0x", 0},
+		{"1e", 0},
+		{"inf", 0},
+		{"nan", 0},
+		{"0x1p", 0},
+		{"1_000", 0},
+		{"0x_1p0", 0},
+		{"2", 2},
+		{"0x10", 16},
+		{"1.5", 10},
+		{"-", 10},
+	} {
+		res, ok := ParseNumber(NewStringConst(c.in), c.base)
+		assert.False(res.IsValid(), c.in)
+		assert.True(ok.IsFalse(), c.in)
+	}
+
+	for _, base := range []int{1, 37, -1} {
+		res, ok := ParseNumber(NewStringConst("1"), base)
+		assert.False(res.IsValid())
+		assert.True(ok.IsFalse())
+	}
+
+	unknown := newStringLen(1, 10)
+	res, ok := ParseNumber(unknown, 0)
+	assert.False(res.IsConstant())
+	assert.True(ok.IsUnknown())
+	res, ok = ParseNumber(unknown, 16)
+	assert.True(res.IsInteger().IsTrue())
+	assert.True(res.p.valRange.IsSame(int64Range))
+	assert.True(ok.IsUnknown())
+	_, ok = ParseNumber(unknown, 40)
+	assert.True(ok.IsFalse())
+}
+
+func (s *NumberSuite) TestToString() {
+	assert := assert.New(s.T())
+
+	for _, c := range []struct {
+		in      float64
+		integer bool
+		out     string
+	}{
+		{1, true, "1"},
+		{1, false, "1.0"},
+		{-7, true, "-7"},
+		{1e15, true, "1000000000000000"},
+		{1e15, false, "1e+15"},
+		{math.MinInt64, true, "-9223372036854775808"},
+		{math.Pow(2, 63), true, "9.2233720368548e+18"},
+		{1e100, false, "1e+100"},
+		{2.5, false, "2.5"},
+		{0.1, false, "0.1"},
+		{1.0 / 3, false, "0.33333333333333"},
+		{1e-5, false, "1e-05"},
+		{0, false, "0.0"},
+		{math.Copysign(0, -1), false, "-0.0"},
+		{math.Inf(1), true, "inf"},
+		{math.Inf(-1), false, "-inf"},
+		{math.Copysign(math.NaN(), -1), false, "-nan"},
+		{math.Copysign(math.NaN(), 1), false, "nan"},
+	} {
+		assert.Equal(c.out, formatLuaNumber(c.in, c.integer), c.in)
+	}
+
+	str := NewNumberConst(2.5).ToString()
+	assert.True(str.IsConstant())
+	assert.Equal("2.5", str.Value())
+	assert.Equal(`"2.5"`, str.String())
+
+	// subtype decides between 1 and 1.0
+	assert.Equal("1", NewNumberConst(1).ToString().Value())
+	assert.Equal("1.0", NewNumberConst(1).ToFloat().ToString().Value())
+	div, err := NewNumberConst(3).Div(NewNumberConst(3))
+	assert.Nil(err)
+	assert.Equal("1.0", div.ToFloat().ToString().Value())
+	assert.Equal("-0.0", NewNumberConst(math.Copysign(0, -1)).ToFloat().ToString().Value())
+
+	for _, c := range []struct {
+		in     *NRange
+		minLen int
+		maxLen int
+	}{
+		{newRangeSegment(1, 5), 1, 1},
+		{newRangeSegment(0, 10), 1, 2},
+		{newRangeSegment(-5, 5), 1, 2},
+		{newRangeSegment(-1000, -10), 3, 5},
+		{NewNRange(9, 123, false, true), 2, 3},
+	} {
+		i, err := NewIntegerRange(c.in)
+		assert.Nil(err)
+		str := i.ToString()
+		assert.False(str.IsConstant())
+		minLen, maxLen := str.Len()
+		assert.Equal(c.minLen, minLen, i.String())
+		assert.Equal(c.maxLen, maxLen, i.String())
+	}
+
+	i, err := NewIntegerRange(newRangeSegment(1, 5))
+	assert.Nil(err)
+	minLen, maxLen := i.ToFloat().ToString().Len()
+	assert.Equal(3, minLen)
+	assert.Equal(luaMaxFloatLen, maxLen)
+
+	minLen, maxLen = s.TwoAndHalfFourInterval.ToString().Len()
+	assert.Equal(1, minLen)
+	assert.Equal(luaMaxFloatLen, maxLen)
+	minLen, maxLen = NewNumberSegment(1e19, 1e20).ToString().Len()
+	assert.Equal(3, minLen)
+	assert.Equal(luaMaxFloatLen, maxLen)
+}
+
+func (s *NumberSuite) TestString() {
+	assert := assert.New(s.T())
+
+	assert.Equal("1", s.One.String())
+	assert.Equal("-1", s.MinusOne.String())
+	assert.Equal("inf", s.Inf.String())
+	assert.Equal("nan", NewNumberConst(math.NaN()).String())
+	assert.Equal("[-inf, inf]", s.Unknown.String())
+	assert.Equal("[0, 1)", s.ZeroOneSegOpen.String())
+	assert.Equal("(2.5, 4)", s.TwoAndHalfFourInterval.String())
+	assert.Equal("[1, 5] int", s.OneFiveSegInt.String())
+	assert.Equal("-1 | -0 | 0 | 1", s.MinusTenTenSeg.Sign().String())
+	assert.Equal("invalid", Number{}.String())
+}
+
 func TestNumber(t *testing.T) {
 	suite.Run(t, new(NumberSuite))
 }
@@ -0,0 +1,143 @@
+package virtual_types
+
+import "fmt"
+
+type ReasonKind int
+
+const (
+	// both operands are the same value
+	ReasonIdentity ReasonKind = iota
+	// both operands are constants
+	ReasonConstant
+	// ranges of operands decide the comparison
+	ReasonRange
+	// one operand carries constraint on the other
+	ReasonConstraint
+	// one operand is integer while the other is not
+	ReasonInteger
+	// value follows from Boolean operand
+	ReasonOperand
+	// one operand is NaN which is unordered
+	ReasonNaN
+)
+
+// Reason is a single step explaining why Boolean value is known.
+// Comparison reasons read as "lhs relation rhs is value".
+type Reason struct {
+	Kind ReasonKind
+	Val  BValue
+
+	rel        NRelation
+	lhs, rhs   *NumberPrivate
+	owner      *NumberPrivate
+	constraint NumberConstraint
+	operand    *BooleanPrivate
+}
+
+var relationStr = map[NRelation]string{
+	NRelationLess:      "<",
+	NRelationLessEqual: "<=",
+	NRelationEqual:     "==",
+}
+
+func (r Reason) Relation() NRelation {
+	return r.rel
+}
+
+func (r Reason) Operands() (Number, Number) {
+	return Number{p: r.lhs}, Number{p: r.rhs}
+}
+
+// Constraint returns constraint deciding ReasonConstraint and its owner.
+func (r Reason) Constraint() (NumberConstraint, Number) {
+	return r.constraint, Number{p: r.owner}
+}
+
+func (r Reason) Operand() Boolean {
+	return Boolean{p: r.operand}
+}
+
+func (r Reason) String() string {
+	if r.Kind == ReasonOperand {
+		return fmt.Sprintf("operand is %s", r.operand.val)
+	}
+
+	cmp := fmt.Sprintf("%s %s %s is %s", Number{p: r.lhs}, relationStr[r.rel], Number{p: r.rhs}, r.Val)
+	switch r.Kind {
+	case ReasonIdentity:
+		return cmp + " because operands are the same value"
+	case ReasonConstant:
+		return cmp
+	case ReasonRange:
+		return cmp + " because of ranges"
+	case ReasonConstraint:
+		if s, ok := r.constraint.(interface{ Subject() Number }); ok {
+			return fmt.Sprintf("%s because %s has %s(%s)", cmp, Number{p: r.owner}, r.constraint.Name(), s.Subject())
+		}
+		return fmt.Sprintf("%s because %s has %s", cmp, Number{p: r.owner}, r.constraint.Name())
+	case ReasonInteger:
+		return cmp + " because only one operand is integer"
+	case ReasonNaN:
+		return cmp + " because NaN is unordered"
+	}
+	return cmp
+}
+
+// decidedPrivate keeps comparison result and its reason in single
+// allocation
+type decidedPrivate struct {
+	p      BooleanPrivate
+	reason [1]Reason
+}
+
+// decided makes comparison result recording why it is known
+func decided(val BValue, r Reason) *BooleanPrivate {
+	if val == BUnknown {
+		return &BooleanPrivate{val: val}
+	}
+	r.Val = val
+	d := &decidedPrivate{p: BooleanPrivate{val: val}, reason: [1]Reason{r}}
+	d.p.reasons = d.reason[:]
+	return &d.p
+}
+
+func compareReason(kind ReasonKind, rel NRelation, lhs, rhs *NumberPrivate) Reason {
+	return Reason{Kind: kind, rel: rel, lhs: lhs, rhs: rhs}
+}
+
+func constraintReason(rel NRelation, lhs, rhs, owner *NumberPrivate, c NumberConstraint) Reason {
+	return Reason{Kind: ReasonConstraint, rel: rel, lhs: lhs, rhs: rhs, owner: owner, constraint: c}
+}
+
+// derived marks res as known because of operands
+func derived(res *BooleanPrivate, operands ...*BooleanPrivate) *BooleanPrivate {
+	if res.val == BUnknown {
+		return res
+	}
+	for _, o := range operands {
+		if o.val != BUnknown {
+			res.reasons = append(res.reasons, Reason{Kind: ReasonOperand, Val: res.val, operand: o})
+		}
+	}
+	return res
+}
+
+func explain(p *BooleanPrivate, visited map[*BooleanPrivate]bool, res []Reason) []Reason {
+	if visited[p] {
+		return res
+	}
+	visited[p] = true
+	for _, r := range p.reasons {
+		res = append(res, r)
+		if r.Kind == ReasonOperand {
+			res = explain(r.operand, visited, res)
+		}
+	}
+	return res
+}
+
+// Explain returns reasons of known value, reasons of operands follow
+// reasons referring them. Unknown and unexplained values give nil.
+func (b Boolean) Explain() []Reason {
+	return explain(b.p, map[*BooleanPrivate]bool{}, nil)
+}
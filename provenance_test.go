@@ -0,0 +1,91 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type ProvenanceSuite struct {
+	suite.Suite
+}
+
+func reasonStrings(reasons []Reason) []string {
+	res := make([]string, len(reasons))
+	for i, r := range reasons {
+		res[i] = r.String()
+	}
+	return res
+}
+
+func (s *ProvenanceSuite) TestComparison() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(0, 1)
+	y := NewNumberSegment(2, 3)
+
+	assert.Equal([]string{"[0, 1] < [2, 3] is true because of ranges"}, reasonStrings(x.Less(y).Explain()))
+	assert.Equal([]string{"[0, 1] < [2, 3] is true because of ranges"}, reasonStrings(y.Greater(x).Explain()))
+	assert.Equal([]string{"[0, 1] == [2, 3] is false because of ranges"}, reasonStrings(x.Equal(y).Explain()))
+	assert.Equal([]string{"1 < 2 is true"}, reasonStrings(NewNumberConst(1).Less(NewNumberConst(2)).Explain()))
+	assert.Equal([]string{"[0, 1] < [0, 1] is false because operands are the same value"}, reasonStrings(x.Less(x).Explain()))
+
+	i, err := NewIntegerRange(NewNRange(0, 10, true, true))
+	assert.Nil(err)
+	eq := i.Equal(NewNumberConst(0.5))
+	assert.True(eq.IsFalse())
+	assert.Len(eq.Explain(), 1)
+	assert.Equal(ReasonInteger, eq.Explain()[0].Kind)
+
+	// unknown results have no explanation
+	assert.Nil(x.Less(NewNumberSegment(0, 2)).Explain())
+}
+
+func (s *ProvenanceSuite) TestConstraint() {
+	assert := assert.New(s.T())
+
+	y := NewNumberSegment(-10, 10)
+	x := y.Abs()
+
+	ge := x.GreaterEqual(y)
+	assert.True(ge.IsTrue())
+
+	reasons := ge.Explain()
+	assert.Equal([]string{
+		"[-10, 10] <= [0, 10] is true because [0, 10] has NumberGreaterEqual([-10, 10])",
+	}, reasonStrings(reasons))
+
+	c, owner := reasons[0].Constraint()
+	assert.Equal("NumberGreaterEqual", c.Name())
+	assert.True(owner == x)
+
+	lhs, rhs := reasons[0].Operands()
+	assert.True(lhs == y)
+	assert.True(rhs == x)
+	assert.Equal(NRelationLessEqual, reasons[0].Relation())
+}
+
+func (s *ProvenanceSuite) TestDerived() {
+	assert := assert.New(s.T())
+
+	lt := NewNumberSegment(0, 1).Less(NewNumberSegment(2, 3))
+	not := lt.Not()
+	assert.True(not.IsFalse())
+	assert.Equal([]string{
+		"operand is true",
+		"[0, 1] < [2, 3] is true because of ranges",
+	}, reasonStrings(not.Explain()))
+	assert.True(not.Explain()[0].Operand() == lt)
+
+	or := NewBoolean().Or(lt)
+	assert.True(or.IsTrue())
+	assert.Len(or.Explain(), 2)
+
+	and := NewBoolean().And(not)
+	assert.True(and.IsFalse())
+	assert.Len(and.Explain(), 3)
+}
+
+func TestProvenance(t *testing.T) {
+	suite.Run(t, new(ProvenanceSuite))
+}
@@ -0,0 +1,691 @@
+package virtual_types
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	SMT_SORT_BOOL = "Bool"
+	SMT_SORT_INT  = "Int"
+	SMT_SORT_REAL = "Real"
+)
+
+type smtTerm struct {
+	text string
+	sort string
+}
+
+// SMTExporter collects Boolean and Number values together with everything
+// reachable through their constraints and renders them as an SMT-LIB 2 script.
+type SMTExporter struct {
+	numbers  map[*NumberPrivate]smtTerm
+	booleans map[*BooleanPrivate]smtTerm
+	names    map[string]bool
+
+	decls   []string
+	asserts []string
+
+	hasInt  bool
+	hasReal bool
+	counter int
+}
+
+func NewSMTExporter() *SMTExporter {
+	return &SMTExporter{
+		numbers:  map[*NumberPrivate]smtTerm{},
+		booleans: map[*BooleanPrivate]smtTerm{},
+		names:    map[string]bool{},
+	}
+}
+
+// reserved words, commands and theory symbols of Core, Ints and Reals,
+// such names are exported quoted
+var smtReserved = map[string]bool{
+	"_": true, "!": true, "as": true, "let": true, "exists": true, "forall": true,
+	"match": true, "par": true, "BINARY": true, "DECIMAL": true,
+	"HEXADECIMAL": true, "NUMERAL": true, "STRING": true,
+
+	"assert": true, "check-sat": true, "declare-const": true, "declare-fun": true,
+	"define-fun": true, "get-model": true, "get-value": true, "pop": true,
+	"push": true, "set-logic": true, "set-option": true, "exit": true,
+
+	"Bool": true, "Int": true, "Real": true,
+	"true": true, "false": true, "not": true, "and": true, "or": true,
+	"xor": true, "=>": true, "=": true, "distinct": true, "ite": true,
+	"<": true, "<=": true, ">": true, ">=": true, "+": true, "-": true,
+	"*": true, "/": true, "div": true, "mod": true, "abs": true,
+	"to_real": true, "to_int": true, "is_int": true,
+}
+
+// smtSymbolText escapes '|' and '\', which quoted symbols can't contain,
+// as %XX. '%' is escaped too, so different names never share a symbol.
+func smtSymbolText(name string) string {
+	if !strings.ContainsAny(name, `|\%`) {
+		return name
+	}
+	var sb strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`|\%`, r) {
+			fmt.Fprintf(&sb, "%%%02X", r)
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func smtSymbol(name string) string {
+	text := smtSymbolText(name)
+	if text == "" || text != name || smtReserved[name] {
+		return "|" + text + "|"
+	}
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isDigit := r >= '0' && r <= '9'
+		if isLetter || (isDigit && i > 0) || strings.ContainsRune("~!@$^&*_-+=<>.?/", r) {
+			continue
+		}
+		return "|" + text + "|"
+	}
+	return name
+}
+
+func smtLiteral(v float64, sort string) smtTerm {
+	if sort == SMT_SORT_INT && v == math.Floor(v) {
+		if v < 0 {
+			return smtTerm{text: "(- " + strconv.FormatFloat(-v, 'f', -1, 64) + ")", sort: SMT_SORT_INT}
+		}
+		return smtTerm{text: strconv.FormatFloat(v, 'f', -1, 64), sort: SMT_SORT_INT}
+	}
+
+	text := strconv.FormatFloat(math.Abs(v), 'f', -1, 64)
+	if !strings.Contains(text, ".") {
+		text += ".0"
+	}
+	if v < 0 {
+		text = "(- " + text + ")"
+	}
+	return smtTerm{text: text, sort: SMT_SORT_REAL}
+}
+
+// toReal converts Int terms to Real, which makes the script mixed
+// arithmetic, see Logic
+func (e *SMTExporter) toReal(t smtTerm) string {
+	if t.sort == SMT_SORT_INT {
+		e.hasReal = true
+		return "(to_real " + t.text + ")"
+	}
+	return t.text
+}
+
+func (e *SMTExporter) compare(op string, l, r smtTerm) string {
+	if l.sort != r.sort {
+		return "(" + op + " " + e.toReal(l) + " " + e.toReal(r) + ")"
+	}
+	return "(" + op + " " + l.text + " " + r.text + ")"
+}
+
+func (e *SMTExporter) freshName(prefix string) string {
+	for {
+		name := fmt.Sprintf("%s%d", prefix, e.counter)
+		e.counter++
+		if !e.names[name] {
+			return name
+		}
+	}
+}
+
+func (e *SMTExporter) declare(name, sort string) smtTerm {
+	e.names[name] = true
+	t := smtTerm{text: smtSymbol(name), sort: sort}
+	e.decls = append(e.decls, "(declare-const "+t.text+" "+sort+")")
+	return t
+}
+
+func (e *SMTExporter) assert(expr string) {
+	if expr == "" || expr == "true" {
+		return
+	}
+	e.asserts = append(e.asserts, "(assert "+expr+")")
+}
+
+func numberSort(p *NumberPrivate) string {
+	if p.IsInteger().IsTrue() {
+		return SMT_SORT_INT
+	}
+	return SMT_SORT_REAL
+}
+
+func (e *SMTExporter) rangeTerm(x smtTerm, r *NRange) string {
+	var parts []string
+	if !math.IsInf(r.lVal, -1) {
+		op := "<"
+		if r.lIncluding {
+			op = "<="
+		}
+		parts = append(parts, e.compare(op, smtLiteral(r.lVal, x.sort), x))
+	}
+	if !math.IsInf(r.rVal, 1) {
+		op := "<"
+		if r.rIncluding {
+			op = "<="
+		}
+		parts = append(parts, e.compare(op, x, smtLiteral(r.rVal, x.sort)))
+	}
+	return smtAnd(parts)
+}
+
+func smtAnd(parts []string) string {
+	switch len(parts) {
+	case 0:
+		return "true"
+	case 1:
+		return parts[0]
+	}
+	return "(and " + strings.Join(parts, " ") + ")"
+}
+
+func smtOr(parts []string) string {
+	for _, part := range parts {
+		if part == "true" {
+			return "true"
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return "false"
+	case 1:
+		return parts[0]
+	}
+	return "(or " + strings.Join(parts, " ") + ")"
+}
+
+// smtNot negates term, "true" may stand for relation which is not exported
+// and stays unconstrained
+func smtNot(term string) string {
+	if term == "true" {
+		return "true"
+	}
+	return "(not " + term + ")"
+}
+
+// valueTerm describes the value set of a single NumberPrivate (without
+// its next-chain). Infinite and NaN constants are not representable in the
+// linear arithmetic logics, so they are left unconstrained as well as
+// ranges which may be NaN.
+func (e *SMTExporter) valueTerm(x smtTerm, p *NumberPrivate) string {
+	if p.nan {
+		return "true"
+	}
+	if p.valRange != nil {
+		return e.rangeTerm(x, p.valRange)
+	}
+	if math.IsNaN(p.val) || math.IsInf(p.val, 0) {
+		return "true"
+	}
+	return e.compare("=", x, smtLiteral(p.val, x.sort))
+}
+
+func (e *SMTExporter) visitNumber(p *NumberPrivate, name string) smtTerm {
+	if t, ok := e.numbers[p]; ok {
+		return t
+	}
+
+	if name == "" {
+		name = e.freshName("_n")
+	}
+
+	sort := numberSort(p)
+	if sort == SMT_SORT_INT {
+		e.hasInt = true
+	} else {
+		e.hasReal = true
+	}
+
+	x := e.declare(name, sort)
+	e.numbers[p] = x
+
+	var variants []string
+	for curr := p; curr != nil; curr = curr.next {
+		variants = append(variants, e.valueTerm(x, curr))
+	}
+	e.assert(smtOr(variants))
+
+	for _, c := range p.constraints {
+		e.assert(e.numberConstraintTerm(x, c))
+	}
+
+	return x
+}
+
+func (e *SMTExporter) numberConstraintTerm(x smtTerm, c NumberConstraint) string {
+	switch c := c.(type) {
+	case NumberOr:
+		variants := make([]string, len(c.variants))
+		for i, v := range c.variants {
+			variants[i] = e.numberConstraintTerm(x, v)
+		}
+		return smtOr(variants)
+	case NumberAnd:
+		operands := make([]string, len(c.operands))
+		for i, o := range c.operands {
+			operands[i] = e.numberConstraintTerm(x, o)
+		}
+		return smtAnd(operands)
+	case NumberNot:
+		return smtNot(e.numberConstraintTerm(x, c.operand))
+	case NumberEqual:
+		return e.compare("=", x, e.visitNumber(c.subject, ""))
+	case NumberLess:
+		return e.compare("<", x, e.visitNumber(c.subject, ""))
+	case NumberLessEqual:
+		return e.compare("<=", x, e.visitNumber(c.subject, ""))
+	case NumberGreater:
+		return e.compare(">", x, e.visitNumber(c.subject, ""))
+	case NumberGreaterEqual:
+		return e.compare(">=", x, e.visitNumber(c.subject, ""))
+	case NumberNotEqual:
+		return "(not " + e.compare("=", x, e.visitNumber(c.subject, "")) + ")"
+	case NumberSelect:
+		cond := e.visitBoolean(c.cond, "")
+		then, els := e.visitNumber(c.then, ""), e.visitNumber(c.els, "")
+		ite := smtTerm{sort: then.sort}
+		if then.sort == els.sort {
+			ite.text = fmt.Sprintf("(ite %s %s %s)", cond.text, then.text, els.text)
+		} else {
+			ite.sort = SMT_SORT_REAL
+			ite.text = fmt.Sprintf("(ite %s %s %s)", cond.text, e.toReal(then), e.toReal(els))
+		}
+		return e.compare("=", x, ite)
+	}
+	return "true"
+}
+
+func (e *SMTExporter) visitBoolean(p *BooleanPrivate, name string) smtTerm {
+	if t, ok := e.booleans[p]; ok {
+		return t
+	}
+
+	if name == "" {
+		name = e.freshName("_b")
+	}
+
+	b := e.declare(name, SMT_SORT_BOOL)
+	e.booleans[p] = b
+
+	switch p.val {
+	case BTrue:
+		e.assert(b.text)
+	case BFalse:
+		e.assert("(not " + b.text + ")")
+	}
+
+	for _, c := range p.constraints {
+		e.assert(e.booleanConstraintTerm(b, c))
+	}
+
+	return b
+}
+
+func (e *SMTExporter) booleanConstraintTerm(b smtTerm, c BooleanConstraint) string {
+	switch c := c.(type) {
+	case BooleanOr:
+		variants := make([]string, len(c.variants))
+		for i, v := range c.variants {
+			variants[i] = e.booleanConstraintTerm(b, v)
+		}
+		return smtOr(variants)
+	case BooleanAnd:
+		operands := make([]string, len(c.operands))
+		for i, o := range c.operands {
+			operands[i] = e.booleanConstraintTerm(b, o)
+		}
+		return smtAnd(operands)
+	case BooleanNot:
+		return smtNot(e.booleanConstraintTerm(b, c.operand))
+	case BooleanEqual:
+		return e.compare("=", b, e.visitBoolean(c.subject, ""))
+	case BooleanNotEqual:
+		return e.compare("distinct", b, e.visitBoolean(c.subject, ""))
+	case BooleanConjunction:
+		return "(= " + b.text + " " + smtAnd(e.booleanOperandTerms(c.operands)) + ")"
+	case BooleanDisjunction:
+		return "(= " + b.text + " " + smtOr(e.booleanOperandTerms(c.operands)) + ")"
+	case BooleanNumberCompare:
+		lhs, rhs := e.visitNumber(c.lhs, ""), e.visitNumber(c.rhs, "")
+		op := "="
+		switch c.rel {
+		case NRelationLess:
+			op = "<"
+		case NRelationLessEqual:
+			op = "<="
+		}
+		return "(= " + b.text + " " + e.compare(op, lhs, rhs) + ")"
+	}
+	return "true"
+}
+
+func (e *SMTExporter) booleanOperandTerms(operands []*BooleanPrivate) []string {
+	terms := make([]string, len(operands))
+	for i, o := range operands {
+		terms[i] = e.visitBoolean(o, "").text
+	}
+	return terms
+}
+
+func (e *SMTExporter) checkName(name string) error {
+	if name == "" {
+		return errors.New("empty SMT symbol name")
+	}
+	if e.names[name] {
+		return fmt.Errorf("SMT symbol %s is already declared", name)
+	}
+	return nil
+}
+
+// DeclareNumber exports n under the given name. Declaring a value that was
+// already reached through constraints links both names with an equality.
+func (e *SMTExporter) DeclareNumber(name string, n Number) error {
+	if err := e.checkName(name); err != nil {
+		return err
+	}
+	if t, ok := e.numbers[n.p]; ok {
+		x := e.declare(name, t.sort)
+		e.assert(e.compare("=", x, t))
+		return nil
+	}
+	e.visitNumber(n.p, name)
+	return nil
+}
+
+// DeclareBoolean exports b under the given name, see DeclareNumber.
+func (e *SMTExporter) DeclareBoolean(name string, b Boolean) error {
+	if err := e.checkName(name); err != nil {
+		return err
+	}
+	if t, ok := e.booleans[b.p]; ok {
+		x := e.declare(name, SMT_SORT_BOOL)
+		e.assert(e.compare("=", x, t))
+		return nil
+	}
+	e.visitBoolean(b.p, name)
+	return nil
+}
+
+// Assert adds b to the set of assumptions checked by the solver.
+func (e *SMTExporter) Assert(b Boolean) {
+	e.assert(e.visitBoolean(b.p, "").text)
+}
+
+// AssertNot adds negation of b to the set of assumptions. An unsat answer
+// to such a script proves that b is always true.
+func (e *SMTExporter) AssertNot(b Boolean) {
+	e.assert("(not " + e.visitBoolean(b.p, "").text + ")")
+}
+
+func (e *SMTExporter) Logic() string {
+	if e.hasInt && e.hasReal {
+		return "QF_LIRA"
+	} else if e.hasReal {
+		return "QF_LRA"
+	}
+	return "QF_LIA"
+}
+
+func (e *SMTExporter) String() string {
+	var sb strings.Builder
+	sb.WriteString("(set-logic " + e.Logic() + ")\n")
+	for _, d := range e.decls {
+		sb.WriteString(d + "\n")
+	}
+	for _, a := range e.asserts {
+		sb.WriteString(a + "\n")
+	}
+	sb.WriteString("(check-sat)\n(get-model)\n")
+	return sb.String()
+}
+
+func (e *SMTExporter) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, e.String())
+	return int64(n), err
+}
+
+// ====== Solver response ======
+
+type SMTStatus int
+
+const (
+	SMTUnknown SMTStatus = iota
+	SMTSat
+	SMTUnsat
+)
+
+type SMTResponse struct {
+	Status SMTStatus
+	model  map[string]sExpr
+}
+
+type sExpr struct {
+	atom string
+	list []sExpr
+}
+
+func (s sExpr) isAtom() bool {
+	return s.list == nil
+}
+
+func tokenizeSExpr(r io.Reader) ([]string, error) {
+	var tokens []string
+	br := bufio.NewReader(r)
+	var curr strings.Builder
+	flush := func() {
+		if curr.Len() > 0 {
+			tokens = append(tokens, curr.String())
+			curr.Reset()
+		}
+	}
+	for {
+		ch, _, err := br.ReadRune()
+		if err == io.EOF {
+			flush()
+			return tokens, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case ch == '(' || ch == ')':
+			flush()
+			tokens = append(tokens, string(ch))
+		case ch == ';':
+			flush()
+			if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+		case ch == '"' || ch == '|':
+			flush()
+			quoted, err := br.ReadString(byte(ch))
+			if err != nil {
+				return nil, fmt.Errorf("unterminated %c in SMT response", ch)
+			}
+			if ch == '|' {
+				tokens = append(tokens, quoted[:len(quoted)-1])
+			} else {
+				tokens = append(tokens, string(ch)+quoted)
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			flush()
+		default:
+			curr.WriteRune(ch)
+		}
+	}
+}
+
+func parseSExprs(tokens []string) ([]sExpr, error) {
+	var stack [][]sExpr
+	var top []sExpr
+	for _, tok := range tokens {
+		switch tok {
+		case "(":
+			stack = append(stack, top)
+			top = []sExpr{}
+		case ")":
+			if len(stack) == 0 {
+				return nil, errors.New("unbalanced ')' in SMT response")
+			}
+			list := top
+			top = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top = append(top, sExpr{list: list})
+		default:
+			top = append(top, sExpr{atom: tok})
+		}
+	}
+	if len(stack) != 0 {
+		return nil, errors.New("unbalanced '(' in SMT response")
+	}
+	return top, nil
+}
+
+// ParseSMTResponse reads solver output produced for a script written by
+// SMTExporter: a check-sat answer optionally followed by a model.
+func ParseSMTResponse(r io.Reader) (*SMTResponse, error) {
+	tokens, err := tokenizeSExpr(r)
+	if err != nil {
+		return nil, err
+	}
+	exprs, err := parseSExprs(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SMTResponse{Status: SMTUnknown, model: map[string]sExpr{}}
+	statusFound := false
+	for _, expr := range exprs {
+		if expr.isAtom() {
+			switch expr.atom {
+			case "sat":
+				res.Status = SMTSat
+			case "unsat":
+				res.Status = SMTUnsat
+			case "unknown":
+				res.Status = SMTUnknown
+			default:
+				return nil, fmt.Errorf("unexpected SMT response token %s", expr.atom)
+			}
+			statusFound = true
+			continue
+		}
+
+		list := expr.list
+		if len(list) > 0 && list[0].isAtom() {
+			switch list[0].atom {
+			case "error":
+				if res.Status == SMTUnsat && len(list) > 1 && strings.Contains(list[1].atom, "model") {
+					// get-model is not available after unsat
+					continue
+				}
+				msg := ""
+				if len(list) > 1 {
+					msg = strings.Trim(list[1].atom, "\"")
+				}
+				return nil, fmt.Errorf("SMT solver error: %s", msg)
+			case "model":
+				list = list[1:]
+			}
+		}
+
+		for _, def := range list {
+			if def.isAtom() || len(def.list) != 5 || def.list[0].atom != "define-fun" {
+				continue
+			}
+			// (define-fun name () Sort value)
+			if len(def.list[2].list) != 0 {
+				continue
+			}
+			res.model[def.list[1].atom] = def.list[4]
+		}
+	}
+
+	if !statusFound {
+		return nil, errors.New("no check-sat answer in SMT response")
+	}
+	return res, nil
+}
+
+// Feasible folds the check-sat answer into BValue: sat means the asserted
+// constraints can hold, unsat means they never do.
+func (r *SMTResponse) Feasible() BValue {
+	switch r.Status {
+	case SMTSat:
+		return BTrue
+	case SMTUnsat:
+		return BFalse
+	}
+	return BUnknown
+}
+
+// Boolean returns value of named Bool constant from the model.
+func (r *SMTResponse) Boolean(name string) BValue {
+	v, ok := r.model[smtSymbolText(name)]
+	if !ok || !v.isAtom() {
+		return BUnknown
+	}
+	switch v.atom {
+	case "true":
+		return BTrue
+	case "false":
+		return BFalse
+	}
+	return BUnknown
+}
+
+func evalSMTNumeral(v sExpr) (float64, error) {
+	if v.isAtom() {
+		return strconv.ParseFloat(v.atom, 64)
+	}
+	if len(v.list) == 0 || !v.list[0].isAtom() {
+		return 0, errors.New("malformed SMT numeral")
+	}
+
+	args := make([]float64, len(v.list)-1)
+	for i, arg := range v.list[1:] {
+		val, err := evalSMTNumeral(arg)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = val
+	}
+
+	switch op := v.list[0].atom; {
+	case op == "-" && len(args) == 1:
+		return -args[0], nil
+	case op == "/" && len(args) == 2:
+		if args[1] == 0 {
+			return 0, ERR_DIV_BY_ZERO
+		}
+		return args[0] / args[1], nil
+	case op == "to_real" && len(args) == 1:
+		return args[0], nil
+	}
+	return 0, fmt.Errorf("unsupported SMT numeral operation %s", v.list[0].atom)
+}
+
+// Number returns value of named Int or Real constant from the model.
+func (r *SMTResponse) Number(name string) (Number, error) {
+	v, ok := r.model[smtSymbolText(name)]
+	if !ok {
+		return Number{}, fmt.Errorf("%s is absent in SMT model", name)
+	}
+	val, err := evalSMTNumeral(v)
+	if err != nil {
+		return Number{}, err
+	}
+	return NewNumberConst(val), nil
+}
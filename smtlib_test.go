@@ -0,0 +1,212 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"strings"
+	"testing"
+)
+
+type SMTSuite struct {
+	suite.Suite
+}
+
+func (s *SMTSuite) TestExportRange() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(1, 5)
+	x.p.integer = NewBooleanConst(BTrue, nil)
+	y := NewNumberRange(&NRange{
+		lVal: 0, lIncluding: false,
+		rVal: math.Inf(1), rIncluding: true,
+	})
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("x", x))
+	assert.Nil(e.DeclareNumber("y", y))
+	assert.NotNil(e.DeclareNumber("y", y))
+
+	script := e.String()
+	assert.Equal("QF_LIRA", e.Logic())
+	assert.True(strings.Contains(script, "(declare-const x Int)"))
+	assert.True(strings.Contains(script, "(declare-const y Real)"))
+	assert.True(strings.Contains(script, "(assert (and (<= 1 x) (<= x 5)))"))
+	assert.True(strings.Contains(script, "(assert (< 0.0 y))"))
+	assert.True(strings.HasSuffix(script, "(check-sat)\n(get-model)\n"))
+}
+
+func (s *SMTSuite) TestExportConstraints() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(-10, 10)
+	abs := x.Abs()
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("abs", abs))
+
+	script := e.String()
+	assert.Equal("QF_LRA", e.Logic())
+	assert.True(strings.Contains(script, "(declare-const _n0 Real)"))
+	assert.True(strings.Contains(script, "(assert (>= |abs| _n0))"))
+	assert.True(strings.Contains(script, "(assert (and (<= (- 10.0) _n0) (<= _n0 10.0)))"))
+
+	assert.Nil(e.DeclareNumber("x", x))
+	assert.True(strings.Contains(e.String(), "(assert (= x _n0))"))
+}
+
+func (s *SMTSuite) TestExportMixedSorts() {
+	assert := assert.New(s.T())
+
+	// integer with fractional bound is compared as real
+	x := NewNumberSegment(0.5, 3)
+	x.p.integer = NewBooleanConst(BTrue, nil)
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("x", x))
+
+	script := e.String()
+	assert.Equal("QF_LIRA", e.Logic())
+	assert.True(strings.Contains(script, "(<= 0.5 (to_real x))"))
+}
+
+func (s *SMTSuite) TestExportNotEqual() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(0, 10)
+	x.p.constraints = []NumberConstraint{NewNumberNotEqual(NewNumberConst(3))}
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("x", x))
+	assert.True(strings.Contains(e.String(), "(assert (not (= x (to_real _n0))))"))
+}
+
+func (s *SMTSuite) TestExportBoolean() {
+	assert := assert.New(s.T())
+
+	a := NewBoolean()
+	b := NewBooleanConst(BFalse, nil)
+	and := a.And(b)
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareBoolean("a", a))
+	assert.Nil(e.DeclareBoolean("and", and))
+	e.AssertNot(a.Not())
+
+	script := e.String()
+	assert.True(strings.Contains(script, "(declare-const a Bool)"))
+	assert.True(strings.Contains(script, "(declare-const |and| Bool)"))
+	assert.True(strings.Contains(script, "(assert (not |and|))"))
+	assert.True(strings.Contains(script, "(assert (or (= |and| a) (= |and| _b0)))"))
+	assert.True(strings.Contains(script, "(assert (not _b0))"))
+	assert.True(strings.Contains(script, "(assert (distinct _b1 a))"))
+	assert.True(strings.Contains(script, "(assert (not _b1))"))
+}
+
+func (s *SMTSuite) TestSymbol() {
+	assert := assert.New(s.T())
+
+	for _, c := range []struct{ name, symbol string }{
+		{"x", "x"},
+		{"x1", "x1"},
+		{"1x", "|1x|"},
+		{"y z", "|y z|"},
+		{"", "||"},
+		{"and", "|and|"},
+		{"=", "|=|"},
+		{"<=", "|<=|"},
+		{"ite", "|ite|"},
+		{"a|b", "|a%7Cb|"},
+		{`a\b`, "|a%5Cb|"},
+		{"a%7Cb", "|a%257Cb|"},
+		{"50%", "|50%25|"},
+	} {
+		assert.Equal(c.symbol, smtSymbol(c.name), c.name)
+	}
+
+	res, err := ParseSMTResponse(strings.NewReader(
+		"sat\n(model\n(define-fun |a%7Cb| () Bool true)\n(define-fun |and| () Int 2)\n)\n"))
+	assert.Nil(err)
+	assert.Equal(BTrue, res.Boolean("a|b"))
+	assert.Equal(BUnknown, res.Boolean("a%7Cb"))
+	and, err := res.Number("and")
+	assert.Nil(err)
+	assert.True(and.Equal(NewNumberConst(2)).IsTrue())
+}
+
+func (s *SMTSuite) TestExportCompare() {
+	assert := assert.New(s.T())
+
+	x := NewNumberSegment(0, 10)
+	y := NewNumberSegment(5, 20)
+
+	e := NewSMTExporter()
+	assert.Nil(e.DeclareNumber("x", x))
+	assert.Nil(e.DeclareNumber("y", y))
+	e.AssertNot(x.Less(y))
+
+	script := e.String()
+	assert.True(strings.Contains(script, "(declare-const _b0 Bool)"))
+	assert.True(strings.Contains(script, "(assert (= _b0 (< x y)))"))
+	assert.True(strings.Contains(script, "(assert (not _b0))"))
+}
+
+func (s *SMTSuite) TestParseSat() {
+	assert := assert.New(s.T())
+
+	res, err := ParseSMTResponse(strings.NewReader(`sat
+(model
+  (define-fun x () Int 3)
+  (define-fun |y z| () Real (/ 1.0 2.0))
+  (define-fun w () Int (- 4))
+  (define-fun b () Bool true)
+  (define-fun c () Bool false)
+)
+`))
+	assert.Nil(err)
+	assert.Equal(SMTSat, res.Status)
+	assert.Equal(BTrue, res.Feasible())
+
+	x, err := res.Number("x")
+	assert.Nil(err)
+	assert.True(x.Equal(NewNumberConst(3)).IsTrue())
+
+	y, err := res.Number("y z")
+	assert.Nil(err)
+	assert.True(y.Equal(NewNumberConst(0.5)).IsTrue())
+
+	w, err := res.Number("w")
+	assert.Nil(err)
+	assert.True(w.Equal(NewNumberConst(-4)).IsTrue())
+
+	_, err = res.Number("missing")
+	assert.NotNil(err)
+
+	assert.Equal(BTrue, res.Boolean("b"))
+	assert.Equal(BFalse, res.Boolean("c"))
+	assert.Equal(BUnknown, res.Boolean("missing"))
+}
+
+func (s *SMTSuite) TestParseUnsat() {
+	assert := assert.New(s.T())
+
+	res, err := ParseSMTResponse(strings.NewReader(
+		"unsat\n(error \"line 7 column 10: model is not available\")\n"))
+	assert.Nil(err)
+	assert.Equal(SMTUnsat, res.Status)
+	assert.Equal(BFalse, res.Feasible())
+
+	res, err = ParseSMTResponse(strings.NewReader("unknown\n"))
+	assert.Nil(err)
+	assert.Equal(BUnknown, res.Feasible())
+
+	_, err = ParseSMTResponse(strings.NewReader("(error \"unknown logic\")\n"))
+	assert.NotNil(err)
+
+	_, err = ParseSMTResponse(strings.NewReader("sat\n(model\n"))
+	assert.NotNil(err)
+}
+
+func TestSMT(t *testing.T) {
+	suite.Run(t, new(SMTSuite))
+}
@@ -0,0 +1,46 @@
+package virtual_types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ====== AbstractString ======
+
+// AbstractString is either a constant string or a string of which only
+// bounds of its length are known
+type AbstractString struct {
+	val      string
+	constant bool
+	minLen   int
+	maxLen   int
+}
+
+func NewStringConst(s string) AbstractString {
+	return AbstractString{val: s, constant: true, minLen: len(s), maxLen: len(s)}
+}
+
+func newStringLen(minLen, maxLen int) AbstractString {
+	return AbstractString{minLen: minLen, maxLen: maxLen}
+}
+
+func (s AbstractString) IsConstant() bool {
+	return s.constant
+}
+
+// Value returns the string itself, empty one for non constant strings
+func (s AbstractString) Value() string {
+	return s.val
+}
+
+// Len returns bounds of the length in bytes, both including
+func (s AbstractString) Len() (int, int) {
+	return s.minLen, s.maxLen
+}
+
+func (s AbstractString) String() string {
+	if s.constant {
+		return strconv.Quote(s.val)
+	}
+	return fmt.Sprintf("string len [%d, %d]", s.minLen, s.maxLen)
+}
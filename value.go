@@ -18,3 +18,14 @@ type Value interface {
 	Greater(o Value) (Boolean, error)
 	GreaterEqual(o Value) (Boolean, error)*/
 }
+
+// BasicValue is the part of Value which Number and Boolean implement, Env,
+// encoders and evaluators work with it.
+type BasicValue interface {
+	TypeName() string
+
+	IsValid() bool
+	IsConstant() bool
+
+	ToBoolean() Boolean
+}
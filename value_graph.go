@@ -0,0 +1,589 @@
+package virtual_types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// valueGraph is serialization independent form of values: nodes refer
+// each other by index, so values shared by constraints are decoded as
+// shared. Provenance of Boolean values (see Explain) is not encoded.
+type valueGraph struct {
+	Roots []int       `json:"roots"`
+	Nodes []graphNode `json:"nodes"`
+}
+
+const (
+	GRAPH_TYPE_NUMBER  = "Number"
+	GRAPH_TYPE_BOOLEAN = "Boolean"
+)
+
+type graphRange struct {
+	L          jsonFloat `json:"l"`
+	R          jsonFloat `json:"r"`
+	LIncluding bool      `json:"lIncluding,omitempty"`
+	RIncluding bool      `json:"rIncluding,omitempty"`
+}
+
+type graphConstraint struct {
+	Kind    string `json:"kind"`
+	Subject *int   `json:"subject,omitempty"`
+	// nested constraints of Or, And and Not
+	Variants []graphConstraint `json:"variants,omitempty"`
+	Operands []int             `json:"operands,omitempty"`
+	Rel      *NRelation        `json:"rel,omitempty"`
+	LHS      *int              `json:"lhs,omitempty"`
+	RHS      *int              `json:"rhs,omitempty"`
+	// NumberSelect
+	Cond *int `json:"cond,omitempty"`
+	Then *int `json:"then,omitempty"`
+	Else *int `json:"else,omitempty"`
+}
+
+type graphNode struct {
+	Type string `json:"type"`
+
+	// Number
+	Const   *jsonFloat  `json:"const,omitempty"`
+	Range   *graphRange `json:"range,omitempty"`
+	NaN     bool        `json:"nan,omitempty"`
+	Integer *int        `json:"integer,omitempty"`
+	Next    *int        `json:"next,omitempty"`
+
+	// Boolean
+	Val string `json:"val,omitempty"`
+
+	Constraints []graphConstraint `json:"constraints,omitempty"`
+}
+
+// ====== Encoding ======
+
+type graphEncoder struct {
+	numbers  map[*NumberPrivate]int
+	booleans map[*BooleanPrivate]int
+	nodes    []graphNode
+}
+
+func newGraphEncoder() *graphEncoder {
+	return &graphEncoder{
+		numbers:  map[*NumberPrivate]int{},
+		booleans: map[*BooleanPrivate]int{},
+	}
+}
+
+func ref(id int) *int {
+	return &id
+}
+
+func (e *graphEncoder) number(p *NumberPrivate) (int, error) {
+	if id, ok := e.numbers[p]; ok {
+		return id, nil
+	}
+	if p == nil {
+		return 0, errors.New("could not encode invalid Number")
+	}
+
+	id := len(e.nodes)
+	e.numbers[p] = id
+	e.nodes = append(e.nodes, graphNode{Type: GRAPH_TYPE_NUMBER})
+
+	node := graphNode{Type: GRAPH_TYPE_NUMBER}
+	if p.valRange == nil {
+		val := jsonFloat(p.val)
+		node.Const = &val
+	} else {
+		r := p.valRange
+		node.Range = &graphRange{
+			L:          jsonFloat(r.lVal),
+			R:          jsonFloat(r.rVal),
+			LIncluding: r.lIncluding,
+			RIncluding: r.rIncluding,
+		}
+		node.NaN = p.nan
+	}
+
+	integer, err := e.boolean(p.integer.p)
+	if err != nil {
+		return 0, err
+	}
+	node.Integer = ref(integer)
+
+	if p.next != nil {
+		next, err := e.number(p.next)
+		if err != nil {
+			return 0, err
+		}
+		node.Next = ref(next)
+	}
+
+	for _, c := range p.constraints {
+		jc, err := e.numberConstraint(c)
+		if err != nil {
+			return 0, err
+		}
+		node.Constraints = append(node.Constraints, jc)
+	}
+
+	e.nodes[id] = node
+	return id, nil
+}
+
+func (e *graphEncoder) numberConstraints(cs []NumberConstraint) ([]graphConstraint, error) {
+	res := make([]graphConstraint, len(cs))
+	for i, c := range cs {
+		jc, err := e.numberConstraint(c)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = jc
+	}
+	return res, nil
+}
+
+func (e *graphEncoder) numberConstraint(c NumberConstraint) (graphConstraint, error) {
+	jc := graphConstraint{Kind: c.Name()}
+	var err error
+	switch c := c.(type) {
+	case NumberOr:
+		jc.Variants, err = e.numberConstraints(c.variants)
+		return jc, err
+	case NumberAnd:
+		jc.Variants, err = e.numberConstraints(c.operands)
+		return jc, err
+	case NumberNot:
+		jc.Variants, err = e.numberConstraints([]NumberConstraint{c.operand})
+		return jc, err
+	case NumberSelect:
+		cond, err := e.boolean(c.cond)
+		if err != nil {
+			return jc, err
+		}
+		then, err := e.number(c.then)
+		if err != nil {
+			return jc, err
+		}
+		els, err := e.number(c.els)
+		if err != nil {
+			return jc, err
+		}
+		jc.Cond, jc.Then, jc.Else = ref(cond), ref(then), ref(els)
+		return jc, nil
+	case interface{ Subject() Number }:
+		subject, err := e.number(c.Subject().p)
+		if err != nil {
+			return jc, err
+		}
+		jc.Subject = ref(subject)
+		return jc, nil
+	}
+	return jc, fmt.Errorf("could not encode %s constraint", c.Name())
+}
+
+func (e *graphEncoder) boolean(p *BooleanPrivate) (int, error) {
+	if id, ok := e.booleans[p]; ok {
+		return id, nil
+	}
+	if p == nil {
+		return 0, errors.New("could not encode invalid Boolean")
+	}
+
+	id := len(e.nodes)
+	e.booleans[p] = id
+	e.nodes = append(e.nodes, graphNode{Type: GRAPH_TYPE_BOOLEAN})
+
+	node := graphNode{Type: GRAPH_TYPE_BOOLEAN, Val: p.val.String()}
+	for _, c := range p.constraints {
+		jc, err := e.booleanConstraint(c)
+		if err != nil {
+			return 0, err
+		}
+		node.Constraints = append(node.Constraints, jc)
+	}
+
+	e.nodes[id] = node
+	return id, nil
+}
+
+func (e *graphEncoder) booleanOperands(operands []*BooleanPrivate) ([]int, error) {
+	ids := make([]int, len(operands))
+	for i, o := range operands {
+		id, err := e.boolean(o)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (e *graphEncoder) booleanConstraints(cs []BooleanConstraint) ([]graphConstraint, error) {
+	res := make([]graphConstraint, len(cs))
+	for i, c := range cs {
+		jc, err := e.booleanConstraint(c)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = jc
+	}
+	return res, nil
+}
+
+func (e *graphEncoder) booleanConstraint(c BooleanConstraint) (graphConstraint, error) {
+	jc := graphConstraint{Kind: c.Name()}
+	var err error
+	switch c := c.(type) {
+	case BooleanOr:
+		jc.Variants, err = e.booleanConstraints(c.variants)
+	case BooleanAnd:
+		jc.Variants, err = e.booleanConstraints(c.operands)
+	case BooleanNot:
+		jc.Variants, err = e.booleanConstraints([]BooleanConstraint{c.operand})
+	case BooleanEqual:
+		var subject int
+		subject, err = e.boolean(c.subject)
+		jc.Subject = ref(subject)
+	case BooleanNotEqual:
+		var subject int
+		subject, err = e.boolean(c.subject)
+		jc.Subject = ref(subject)
+	case BooleanConjunction:
+		jc.Operands, err = e.booleanOperands(c.operands)
+	case BooleanDisjunction:
+		jc.Operands, err = e.booleanOperands(c.operands)
+	case BooleanNumberCompare:
+		var lhs, rhs int
+		if lhs, err = e.number(c.lhs); err != nil {
+			return jc, err
+		}
+		rhs, err = e.number(c.rhs)
+		rel := c.rel
+		jc.Rel, jc.LHS, jc.RHS = &rel, ref(lhs), ref(rhs)
+	case BooleanDummyConstraint:
+	default:
+		err = fmt.Errorf("could not encode %s constraint", c.Name())
+	}
+	return jc, err
+}
+
+func (e *graphEncoder) value(v BasicValue) (int, error) {
+	switch v := v.(type) {
+	case Number:
+		return e.number(v.p)
+	case Boolean:
+		return e.boolean(v.p)
+	}
+	return 0, fmt.Errorf("could not encode %s value", v.TypeName())
+}
+
+func encodeGraph(values ...BasicValue) (valueGraph, error) {
+	e := newGraphEncoder()
+	g := valueGraph{Roots: make([]int, len(values))}
+	for i, v := range values {
+		id, err := e.value(v)
+		if err != nil {
+			return g, err
+		}
+		g.Roots[i] = id
+	}
+	g.Nodes = e.nodes
+	return g, nil
+}
+
+// ====== Decoding ======
+
+type graphDecoder struct {
+	nodes    []graphNode
+	numbers  []*NumberPrivate
+	booleans []*BooleanPrivate
+}
+
+func (d *graphDecoder) numberRef(id *int) (*NumberPrivate, error) {
+	if id == nil || *id < 0 || *id >= len(d.nodes) || d.numbers[*id] == nil {
+		return nil, errors.New("invalid Number reference")
+	}
+	return d.numbers[*id], nil
+}
+
+func (d *graphDecoder) booleanRef(id *int) (*BooleanPrivate, error) {
+	if id == nil || *id < 0 || *id >= len(d.nodes) || d.booleans[*id] == nil {
+		return nil, errors.New("invalid Boolean reference")
+	}
+	return d.booleans[*id], nil
+}
+
+func parseBValue(s string) (BValue, error) {
+	for _, v := range []BValue{BFalse, BTrue, BUnknown} {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return -1, fmt.Errorf("invalid Boolean value %q", s)
+}
+
+// checkNested checks count of nested constraints of combinator jc: Not has
+// single operand, Or and And atleast 2
+func checkNested(jc graphConstraint) error {
+	n := len(jc.Variants)
+	if strings.HasSuffix(jc.Kind, "Not") {
+		if n != 1 {
+			return fmt.Errorf("expected single operand for %s", jc.Kind)
+		}
+	} else if n < 2 {
+		return fmt.Errorf("expected atleast 2 variants for %s", jc.Kind)
+	}
+	return nil
+}
+
+func (d *graphDecoder) numberConstraints(jcs []graphConstraint) ([]NumberConstraint, error) {
+	res := make([]NumberConstraint, len(jcs))
+	for i, jc := range jcs {
+		c, err := d.numberConstraint(jc)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = c
+	}
+	return res, nil
+}
+
+func (d *graphDecoder) numberConstraint(jc graphConstraint) (NumberConstraint, error) {
+	switch jc.Kind {
+	case "NumberOr", "NumberAnd", "NumberNot":
+		if err := checkNested(jc); err != nil {
+			return nil, err
+		}
+		cs, err := d.numberConstraints(jc.Variants)
+		if err != nil {
+			return nil, err
+		}
+		switch jc.Kind {
+		case "NumberOr":
+			return NumberOr{variants: cs}, nil
+		case "NumberAnd":
+			return NumberAnd{operands: cs}, nil
+		}
+		return NumberNot{operand: cs[0]}, nil
+	}
+	if jc.Kind == "NumberSelect" {
+		cond, err := d.booleanRef(jc.Cond)
+		if err != nil {
+			return nil, fmt.Errorf("NumberSelect: %s", err.Error())
+		}
+		then, err := d.numberRef(jc.Then)
+		if err != nil {
+			return nil, fmt.Errorf("NumberSelect: %s", err.Error())
+		}
+		els, err := d.numberRef(jc.Else)
+		if err != nil {
+			return nil, fmt.Errorf("NumberSelect: %s", err.Error())
+		}
+		return NumberSelect{cond: cond, then: then, els: els}, nil
+	}
+
+	subject, err := d.numberRef(jc.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", jc.Kind, err.Error())
+	}
+	switch jc.Kind {
+	case "NumberEqual":
+		return NumberEqual{subject: subject}, nil
+	case "NumberNotEqual":
+		return NumberNotEqual{subject: subject}, nil
+	case "NumberLess":
+		return NumberLess{subject: subject}, nil
+	case "NumberLessEqual":
+		return NumberLessEqual{subject: subject}, nil
+	case "NumberGreater":
+		return NumberGreater{subject: subject}, nil
+	case "NumberGreaterEqual":
+		return NumberGreaterEqual{subject: subject}, nil
+	}
+	return nil, fmt.Errorf("unknown Number constraint %q", jc.Kind)
+}
+
+func (d *graphDecoder) booleanOperands(ids []int) ([]*BooleanPrivate, error) {
+	operands := make([]*BooleanPrivate, len(ids))
+	for i := range ids {
+		o, err := d.booleanRef(&ids[i])
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = o
+	}
+	return operands, nil
+}
+
+func (d *graphDecoder) booleanConstraint(jc graphConstraint) (BooleanConstraint, error) {
+	switch jc.Kind {
+	case "BooleanOr", "BooleanAnd", "BooleanNot":
+		if err := checkNested(jc); err != nil {
+			return nil, err
+		}
+		cs := make([]BooleanConstraint, len(jc.Variants))
+		for i, jv := range jc.Variants {
+			c, err := d.booleanConstraint(jv)
+			if err != nil {
+				return nil, err
+			}
+			cs[i] = c
+		}
+		switch jc.Kind {
+		case "BooleanOr":
+			return BooleanOr{variants: cs}, nil
+		case "BooleanAnd":
+			return BooleanAnd{operands: cs}, nil
+		}
+		return BooleanNot{operand: cs[0]}, nil
+	case "BooleanEqual", "BooleanNotEqual":
+		subject, err := d.booleanRef(jc.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", jc.Kind, err.Error())
+		}
+		if jc.Kind == "BooleanEqual" {
+			return BooleanEqual{subject: subject}, nil
+		}
+		return BooleanNotEqual{subject: subject}, nil
+	case "BooleanConjunction", "BooleanDisjunction":
+		operands, err := d.booleanOperands(jc.Operands)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", jc.Kind, err.Error())
+		}
+		if jc.Kind == "BooleanConjunction" {
+			return BooleanConjunction{operands: operands}, nil
+		}
+		return BooleanDisjunction{operands: operands}, nil
+	case "BooleanNumberCompare":
+		lhs, err := d.numberRef(jc.LHS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", jc.Kind, err.Error())
+		}
+		rhs, err := d.numberRef(jc.RHS)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", jc.Kind, err.Error())
+		}
+		if jc.Rel == nil || *jc.Rel < NRelationLess || *jc.Rel > NRelationEqual {
+			return nil, errors.New("BooleanNumberCompare: invalid relation")
+		}
+		return BooleanNumberCompare{rel: *jc.Rel, lhs: lhs, rhs: rhs}, nil
+	case "BooleanDummyConstraint":
+		return BooleanDummyConstraint{}, nil
+	}
+	return nil, fmt.Errorf("unknown Boolean constraint %q", jc.Kind)
+}
+
+func (d *graphDecoder) fill(id int) error {
+	node := d.nodes[id]
+
+	if node.Type == GRAPH_TYPE_BOOLEAN {
+		p := d.booleans[id]
+		val, err := parseBValue(node.Val)
+		if err != nil {
+			return err
+		}
+		p.val = val
+		for _, jc := range node.Constraints {
+			c, err := d.booleanConstraint(jc)
+			if err != nil {
+				return err
+			}
+			p.constraints = append(p.constraints, c)
+		}
+		return nil
+	}
+
+	p := d.numbers[id]
+	switch {
+	case node.Const != nil && node.Range == nil:
+		p.val = float64(*node.Const)
+	case node.Range != nil && node.Const == nil:
+		r := node.Range
+		if !(r.L <= r.R) {
+			return errors.New("invalid range of Number")
+		}
+		p.valRange = &NRange{
+			lVal:       float64(r.L),
+			rVal:       float64(r.R),
+			lIncluding: r.LIncluding,
+			rIncluding: r.RIncluding,
+		}
+		p.nan = node.NaN
+	default:
+		return errors.New("expected either const or range of Number")
+	}
+
+	integer, err := d.booleanRef(node.Integer)
+	if err != nil {
+		return fmt.Errorf("integer: %s", err.Error())
+	}
+	p.integer = Boolean{p: integer}
+	if node.Next != nil {
+		next, err := d.numberRef(node.Next)
+		if err != nil {
+			return fmt.Errorf("next: %s", err.Error())
+		}
+		p.next = next
+	}
+	for _, jc := range node.Constraints {
+		c, err := d.numberConstraint(jc)
+		if err != nil {
+			return err
+		}
+		p.constraints = append(p.constraints, c)
+	}
+	return nil
+}
+
+// nextCycle tells whether next chain of p never ends, the chain is longer
+// than the graph then
+func (d *graphDecoder) nextCycle(p *NumberPrivate) bool {
+	for i := 0; p != nil; i++ {
+		if i > len(d.nodes) {
+			return true
+		}
+		p = p.next
+	}
+	return false
+}
+
+func decodeGraph(g valueGraph) ([]BasicValue, error) {
+	// allocate nodes first, so references may point forward
+	d := &graphDecoder{
+		nodes:    g.Nodes,
+		numbers:  make([]*NumberPrivate, len(g.Nodes)),
+		booleans: make([]*BooleanPrivate, len(g.Nodes)),
+	}
+	for i, node := range g.Nodes {
+		switch node.Type {
+		case GRAPH_TYPE_NUMBER:
+			d.numbers[i] = &NumberPrivate{}
+		case GRAPH_TYPE_BOOLEAN:
+			d.booleans[i] = &BooleanPrivate{}
+		default:
+			return nil, fmt.Errorf("node %d: unknown type %q", i, node.Type)
+		}
+	}
+	for i := range g.Nodes {
+		if err := d.fill(i); err != nil {
+			return nil, fmt.Errorf("node %d: %s", i, err.Error())
+		}
+	}
+	for i, p := range d.numbers {
+		if p != nil && d.nextCycle(p) {
+			return nil, fmt.Errorf("node %d: next: cycle", i)
+		}
+	}
+
+	values := make([]BasicValue, len(g.Roots))
+	for i, id := range g.Roots {
+		if id < 0 || id >= len(g.Nodes) {
+			return nil, fmt.Errorf("root %d: invalid reference", i)
+		}
+		if d.numbers[id] != nil {
+			values[i] = Number{p: d.numbers[id]}
+		} else {
+			values[i] = Boolean{p: d.booleans[id]}
+		}
+	}
+	return values, nil
+}
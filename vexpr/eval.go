@@ -0,0 +1,332 @@
+package vexpr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+)
+
+type Expr interface {
+	Eval(env *vt.Env) (vt.BasicValue, error)
+	String() string
+}
+
+type numberExpr struct {
+	val float64
+}
+
+type booleanExpr struct {
+	val bool
+}
+
+type varExpr struct {
+	name string
+}
+
+type unaryExpr struct {
+	op      string
+	operand Expr
+}
+
+type binaryExpr struct {
+	op  string
+	lhs Expr
+	rhs Expr
+}
+
+type callExpr struct {
+	name string
+	args []Expr
+}
+
+// Number, Bool, Var, Unary, Binary and Call build the nodes Parse produces,
+// so expressions can be constructed without printing and parsing source.
+
+func Number(v float64) Expr {
+	return &numberExpr{val: v}
+}
+
+func Bool(v bool) Expr {
+	return &booleanExpr{val: v}
+}
+
+func Var(name string) Expr {
+	return &varExpr{name: name}
+}
+
+// Unary op is "-" or "not"
+func Unary(op string, operand Expr) Expr {
+	return &unaryExpr{op: op, operand: operand}
+}
+
+// Binary op is one of the operators of Parse, "!=" is written as "~="
+func Binary(op string, lhs, rhs Expr) Expr {
+	return &binaryExpr{op: op, lhs: lhs, rhs: rhs}
+}
+
+// Call is a call of builtin function: abs, floor, ceil, isinteger or isnan
+func Call(name string, args ...Expr) Expr {
+	return &callExpr{name: name, args: args}
+}
+
+func (e *numberExpr) String() string {
+	if math.IsInf(e.val, 1) {
+		return "inf"
+	}
+	return strconv.FormatFloat(e.val, 'g', -1, 64)
+}
+
+func (e *booleanExpr) String() string { return strconv.FormatBool(e.val) }
+func (e *varExpr) String() string     { return e.name }
+
+func (e *unaryExpr) String() string {
+	if e.op == "not" {
+		return "(not " + e.operand.String() + ")"
+	}
+	return "(" + e.op + e.operand.String() + ")"
+}
+
+func (e *binaryExpr) String() string {
+	return "(" + e.lhs.String() + " " + e.op + " " + e.rhs.String() + ")"
+}
+
+func (e *callExpr) String() string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.String()
+	}
+	return e.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func errType(op string, expected string, v vt.BasicValue) error {
+	return fmt.Errorf("%s expects %s operand, got %s", op, expected, v.TypeName())
+}
+
+func evalNumber(e Expr, env *vt.Env, op string) (vt.Number, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return vt.Number{}, err
+	}
+	n, ok := v.(vt.Number)
+	if !ok {
+		return vt.Number{}, errType(op, "Number", v)
+	}
+	return n, nil
+}
+
+func evalBoolean(e Expr, env *vt.Env, op string) (vt.Boolean, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return vt.Boolean{}, err
+	}
+	b, ok := v.(vt.Boolean)
+	if !ok {
+		return vt.Boolean{}, errType(op, "Boolean", v)
+	}
+	return b, nil
+}
+
+func (e *numberExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	return vt.NewNumberConst(e.val), nil
+}
+
+func (e *booleanExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	if e.val {
+		return vt.NewBooleanConst(vt.BTrue, nil), nil
+	}
+	return vt.NewBooleanConst(vt.BFalse, nil), nil
+}
+
+func (e *varExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	if v, ok := env.Get(e.name); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("undeclared variable %s", e.name)
+}
+
+func (e *unaryExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	if e.op == "not" {
+		b, err := evalBoolean(e.operand, env, e.op)
+		if err != nil {
+			return nil, err
+		}
+		return b.Not(), nil
+	}
+
+	n, err := evalNumber(e.operand, env, e.op)
+	if err != nil {
+		return nil, err
+	}
+	return n.Negate(), nil
+}
+
+func (e *binaryExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	switch e.op {
+	case "and", "or":
+		lhs, err := evalBoolean(e.lhs, env, e.op)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := evalBoolean(e.rhs, env, e.op)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "and" {
+			return lhs.And(rhs), nil
+		}
+		return lhs.Or(rhs), nil
+	case "==", "~=":
+		lhs, err := e.lhs.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := e.rhs.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		eq, err := equal(lhs, rhs)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "~=" {
+			return eq.Not(), nil
+		}
+		return eq, nil
+	}
+
+	lhs, err := evalNumber(e.lhs, env, e.op)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := evalNumber(e.rhs, env, e.op)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "<":
+		return lhs.Less(rhs), nil
+	case "<=":
+		return lhs.LessEqual(rhs), nil
+	case ">":
+		return lhs.Greater(rhs), nil
+	case ">=":
+		return lhs.GreaterEqual(rhs), nil
+	case "+":
+		return lhs.Add(rhs)
+	case "-":
+		return lhs.Sub(rhs)
+	case "*":
+		return lhs.Mul(rhs)
+	case "/":
+		return lhs.Div(rhs)
+	case "//":
+		return lhs.IDiv(rhs)
+	case "^":
+		return lhs.Pow(rhs)
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+func equal(lhs, rhs vt.BasicValue) (vt.Boolean, error) {
+	switch l := lhs.(type) {
+	case vt.Number:
+		if r, ok := rhs.(vt.Number); ok {
+			return l.Equal(r), nil
+		}
+	case vt.Boolean:
+		if r, ok := rhs.(vt.Boolean); ok {
+			return l.Equal(r), nil
+		}
+	}
+	// values of different types are never equal
+	return vt.NewBooleanConst(vt.BFalse, nil), nil
+}
+
+var functions = map[string]func(args []vt.Number) (vt.BasicValue, error){
+	"abs": func(args []vt.Number) (vt.BasicValue, error) {
+		return args[0].Abs(), nil
+	},
+	"floor": func(args []vt.Number) (vt.BasicValue, error) {
+		return args[0].Floor(), nil
+	},
+	"ceil": func(args []vt.Number) (vt.BasicValue, error) {
+		return args[0].Ceil(), nil
+	},
+	"isinteger": func(args []vt.Number) (vt.BasicValue, error) {
+		return args[0].IsInteger(), nil
+	},
+	"isnan": func(args []vt.Number) (vt.BasicValue, error) {
+		return args[0].IsNaN(), nil
+	},
+}
+
+func (e *callExpr) Eval(env *vt.Env) (vt.BasicValue, error) {
+	f, ok := functions[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", e.name)
+	}
+	if len(e.args) != 1 {
+		return nil, fmt.Errorf("%s expects 1 argument, got %d", e.name, len(e.args))
+	}
+
+	args := make([]vt.Number, len(e.args))
+	for i, arg := range e.args {
+		n, err := evalNumber(arg, env, e.name)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = n
+	}
+	return f(args)
+}
+
+// Eval parses and evaluates src against variables declared in env.
+func Eval(src string, env *vt.Env) (vt.BasicValue, error) {
+	e, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(env)
+}
+
+func (d Decl) Value() (vt.BasicValue, error) {
+	if d.Type == "bool" {
+		return vt.NewBoolean(), nil
+	}
+
+	r := vt.NewNRange(math.Inf(-1), math.Inf(1), true, true)
+	if d.Range {
+		if d.LVal > d.RVal || (d.LVal == d.RVal && !(d.LIncluding && d.RIncluding)) {
+			return nil, errors.New("empty range in declaration of " + d.Name)
+		}
+		r = vt.NewNRange(d.LVal, d.RVal, d.LIncluding, d.RIncluding)
+	}
+
+	if d.Type == "int" {
+		n, err := vt.NewIntegerRange(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", d.Name, err.Error())
+		}
+		return n, nil
+	}
+	return vt.NewNumberRange(r), nil
+}
+
+// Declare parses declaration and binds a fresh value to its name in env.
+func Declare(env *vt.Env, src string) error {
+	d, err := ParseDecl(src)
+	if err != nil {
+		return err
+	}
+	v, err := d.Value()
+	if err != nil {
+		return err
+	}
+	env.Set(d.Name, v)
+	return nil
+}
@@ -0,0 +1,350 @@
+package vexpr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(texts ...string) (token, bool) {
+	t := p.peek()
+	if t.kind == tokOp || t.kind == tokIdent {
+		for _, text := range texts {
+			if t.text == text {
+				return p.next(), true
+			}
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(text string) error {
+	if t, ok := p.accept(text); !ok {
+		return fmt.Errorf("expected %q at %d, got %s", text, t.pos, t)
+	}
+	return nil
+}
+
+func (p *parser) expectEOF() error {
+	if t := p.peek(); t.kind != tokEOF {
+		return fmt.Errorf("unexpected %s at %d", t, t.pos)
+	}
+	return nil
+}
+
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"true": true, "false": true,
+	"in": true, "inf": true,
+}
+
+// IsKeyword tells whether name is reserved and can't be a variable name
+func IsKeyword(name string) bool {
+	return keywords[name]
+}
+
+func parseNumberLiteral(text string) (float64, error) {
+	lower := strings.ToLower(text)
+	if strings.HasPrefix(lower, "0x") && !strings.ContainsAny(lower, ".p") {
+		v, err := strconv.ParseUint(lower[2:], 16, 64)
+		return float64(int64(v)), err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// Parse parses single expression.
+func Parse(src string) (Expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectEOF(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// MustParse is like Parse but panics on malformed src.
+func MustParse(src string) Expr {
+	e, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (p *parser) parseBinary(sub func() (Expr, error), ops ...string) (Expr, error) {
+	lhs, err := sub()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return lhs, nil
+		}
+		rhs, err := sub()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binaryExpr{op: op.text, lhs: lhs, rhs: rhs}
+	}
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseBinary(p.parseCompare, "and")
+}
+
+func (p *parser) parseCompare() (Expr, error) {
+	lhs, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("<", "<=", ">", ">=", "==", "~=", "!=")
+	if !ok {
+		return lhs, nil
+	}
+	rhs, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	if op.text == "!=" {
+		op.text = "~="
+	}
+	return &binaryExpr{op: op.text, lhs: lhs, rhs: rhs}, nil
+}
+
+func (p *parser) parseAdd() (Expr, error) {
+	return p.parseBinary(p.parseMul, "+", "-")
+}
+
+func (p *parser) parseMul() (Expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "//")
+}
+
+// parseUnary follows Lua precedence: not binds tighter than comparison,
+// so "not a == b" means "(not a) == b"
+func (p *parser) parseUnary() (Expr, error) {
+	if op, ok := p.accept("-", "not"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op.text, operand: operand}, nil
+	}
+	return p.parsePow()
+}
+
+// parsePow binds tighter than unary minus and is right associative: -2^2 = -4
+func (p *parser) parsePow() (Expr, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("^"); !ok {
+		return base, nil
+	}
+	exp, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryExpr{op: "^", lhs: base, rhs: exp}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		v, err := parseNumberLiteral(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number %s at %d", t, t.pos)
+		}
+		return &numberExpr{val: v}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return &booleanExpr{val: t.text == "true"}, nil
+		case "inf":
+			return &numberExpr{val: math.Inf(1)}, nil
+		}
+		if keywords[t.text] {
+			return nil, fmt.Errorf("unexpected %s at %d", t, t.pos)
+		}
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+		return &varExpr{name: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at %d", t, t.pos)
+}
+
+func (p *parser) parseCall(name token) (Expr, error) {
+	call := &callExpr{name: name.text}
+	if _, ok := p.accept(")"); ok {
+		return call, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// ====== Declarations ======
+
+// Decl is a variable declaration:
+//
+//	x in [0, 10] int
+//	y in (-inf, 0)
+//	n: int
+//	b: bool
+type Decl struct {
+	Name string
+	Type string
+
+	Range      bool
+	LVal, RVal float64
+	LIncluding bool
+	RIncluding bool
+}
+
+func (p *parser) parseBound() (float64, error) {
+	sign := 1.0
+	if _, ok := p.accept("-"); ok {
+		sign = -1
+	}
+	t := p.next()
+	switch {
+	case t.kind == tokIdent && t.text == "inf":
+		return sign * math.Inf(1), nil
+	case t.kind == tokNumber:
+		v, err := parseNumberLiteral(t.text)
+		if err != nil {
+			return 0, fmt.Errorf("malformed number %s at %d", t, t.pos)
+		}
+		return sign * v, nil
+	}
+	return 0, fmt.Errorf("expected range bound at %d, got %s", t.pos, t)
+}
+
+func (p *parser) parseDeclType(d *Decl, required bool) error {
+	t := p.peek()
+	if t.kind != tokIdent {
+		if required {
+			return fmt.Errorf("expected type at %d, got %s", t.pos, t)
+		}
+		return nil
+	}
+	switch t.text {
+	case "int", "number", "bool":
+		d.Type = p.next().text
+		return nil
+	}
+	return fmt.Errorf("unknown type %s at %d", t, t.pos)
+}
+
+func ParseDecl(src string) (Decl, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return Decl{}, err
+	}
+	p := &parser{tokens: tokens}
+
+	name := p.next()
+	if name.kind != tokIdent || keywords[name.text] {
+		return Decl{}, fmt.Errorf("expected variable name at %d, got %s", name.pos, name)
+	}
+	d := Decl{Name: name.text, Type: "number"}
+
+	if _, ok := p.accept(":"); ok {
+		if err := p.parseDeclType(&d, true); err != nil {
+			return Decl{}, err
+		}
+	} else if _, ok := p.accept("in"); ok {
+		open, ok := p.accept("[", "(")
+		if !ok {
+			return Decl{}, fmt.Errorf("expected range at %d, got %s", open.pos, open)
+		}
+		d.Range = true
+		d.LIncluding = open.text == "["
+
+		if d.LVal, err = p.parseBound(); err != nil {
+			return Decl{}, err
+		}
+		if err := p.expect(","); err != nil {
+			return Decl{}, err
+		}
+		if d.RVal, err = p.parseBound(); err != nil {
+			return Decl{}, err
+		}
+
+		closing, ok := p.accept("]", ")")
+		if !ok {
+			return Decl{}, fmt.Errorf("expected end of range at %d, got %s", closing.pos, closing)
+		}
+		d.RIncluding = closing.text == "]"
+
+		if err := p.parseDeclType(&d, false); err != nil {
+			return Decl{}, err
+		}
+		if d.Type == "bool" {
+			return Decl{}, fmt.Errorf("range is not applicable to bool variable %s", d.Name)
+		}
+	} else {
+		t := p.peek()
+		return Decl{}, fmt.Errorf("expected ':' or 'in' at %d, got %s", t.pos, t)
+	}
+
+	if err := p.expectEOF(); err != nil {
+		return Decl{}, err
+	}
+	return d, nil
+}
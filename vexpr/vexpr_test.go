@@ -0,0 +1,146 @@
+package vexpr
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"math"
+	"strings"
+	"testing"
+
+	vt "github.com/alexeyknyshev/virtual-reasoning-types"
+)
+
+type VExprSuite struct {
+	suite.Suite
+}
+
+// each case is "declarations; expression => result"
+var evalCases = `
+x in [0, 10] int; y in [22, 30]         ; x * 2 + 1 < y             => true
+x in [0, 10] int; y in [15, 30]         ; x * 2 + 1 < y             => unknown
+x in [0, 10] int; y in [15, 30]; b: bool; x * 2 + 1 < y and not b   => unknown
+x in [0, 10] int; b: bool               ; x > 10 and b              => false
+x in [0, 10] int; b: bool               ; x < 11 or b               => true
+x in [0, 10]                            ; x - x                     => 0
+x in [0, 10]                            ; x + 1                     => [1, 11]
+x in (0, 10] int                        ; x                         => [1, 10] int
+x in [0, 10] int                        ; x // 1                    => [0, 10] int
+x in [-10, 2.5]                         ; abs(x)                    => [0, 10]
+n: int                                  ; isinteger(n)              => true
+x in [1, 5]                             ; x == x                    => true
+x in [1, 5]                             ; x ~= 7                    => true
+b: bool                                 ; b == not b                => false
+b: bool                                 ; not b == b                => false
+                                        ; -2 ^ 2                    => -4
+                                        ; 2 ^ -1                    => 0.5
+                                        ; 7 // 2 * 2 + 0x10         => 22
+                                        ; 1 < 2 and 2 <= 2          => true
+                                        ; inf > 1e308               => true
+`
+
+func (s *VExprSuite) TestEvalTable() {
+	assert := assert.New(s.T())
+
+	for _, line := range strings.Split(evalCases, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "=>")
+		expected := strings.TrimSpace(parts[1])
+		stmts := strings.Split(parts[0], ";")
+
+		env := vt.NewEnv()
+		for _, decl := range stmts[:len(stmts)-1] {
+			if decl = strings.TrimSpace(decl); decl != "" {
+				assert.Nil(Declare(env, decl), line)
+			}
+		}
+
+		res, err := Eval(stmts[len(stmts)-1], env)
+		if assert.Nil(err, line) {
+			assert.Equal(expected, res.(interface{ String() string }).String(), line)
+		}
+	}
+}
+
+func (s *VExprSuite) TestParse() {
+	assert := assert.New(s.T())
+
+	e, err := Parse("x * 2 + 1 < y and not b")
+	assert.Nil(err)
+	assert.Equal("((((x * 2) + 1) < y) and (not b))", e.String())
+
+	e, err = Parse("-a ^ -b // c")
+	assert.Nil(err)
+	assert.Equal("((-(a ^ (-b))) // c)", e.String())
+
+	e, err = Parse("abs(x - 1) != floor(y)")
+	assert.Nil(err)
+	assert.Equal("(abs((x - 1)) ~= floor(y))", e.String())
+
+	for _, src := range []string{"", "1 +", "(1", "and", "1 2", "x $ y", "f(1,"} {
+		_, err := Parse(src)
+		assert.NotNil(err, src)
+	}
+}
+
+func (s *VExprSuite) TestBuild() {
+	assert := assert.New(s.T())
+
+	e := Binary("and",
+		Binary("<", Binary("+", Binary("*", Var("x"), Number(2)), Number(1)), Var("y")),
+		Unary("not", Var("b")))
+	assert.Equal(MustParse("x * 2 + 1 < y and not b").String(), e.String())
+	assert.Equal("(abs((x - 1)) ~= floor(y))",
+		Binary("~=", Call("abs", Binary("-", Var("x"), Number(1))), Call("floor", Var("y"))).String())
+
+	env := vt.NewEnv()
+	assert.Nil(Declare(env, "x in [0, 10] int"))
+	v, err := Binary("+", Binary("*", Var("x"), Number(2)), Number(1)).Eval(env)
+	assert.Nil(err)
+	assert.Equal("[1, 21] int", v.(vt.Number).String())
+	v, err = Bool(true).Eval(env)
+	assert.Nil(err)
+	assert.True(v.(vt.Boolean).IsTrue())
+	assert.Equal("inf", Number(math.Inf(1)).String())
+
+	_, err = Binary("$", Number(1), Number(2)).Eval(env)
+	assert.NotNil(err)
+}
+
+func (s *VExprSuite) TestDecl() {
+	assert := assert.New(s.T())
+
+	d, err := ParseDecl("x in (-inf, 2.5] int")
+	assert.Nil(err)
+	assert.Equal("x", d.Name)
+	assert.Equal("int", d.Type)
+	assert.True(d.Range)
+	assert.True(math.IsInf(d.LVal, -1))
+	assert.Equal(2.5, d.RVal)
+	assert.False(d.LIncluding)
+	assert.True(d.RIncluding)
+
+	d, err = ParseDecl("b: bool")
+	assert.Nil(err)
+	assert.Equal(Decl{Name: "b", Type: "bool"}, d)
+
+	env := vt.NewEnv()
+	assert.Nil(Declare(env, "y in [1, 5)"))
+	y, ok := env.Number("y")
+	assert.True(ok)
+	assert.Equal("[1, 5)", y.String())
+
+	for _, src := range []string{
+		"x", "x:", "x: string", "x in 1", "x in [1, 2", "x in [1 2]",
+		"b in [0, 1] bool", "x in [5, 1]", "x in (0, 1) int", "and: bool",
+	} {
+		assert.NotNil(Declare(env, src), src)
+	}
+}
+
+func TestVExpr(t *testing.T) {
+	suite.Run(t, new(VExprSuite))
+}
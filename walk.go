@@ -0,0 +1,50 @@
+package virtual_types
+
+// ====== Constraint walk ======
+
+// booleanWalk is state of single query through Boolean constraint graph.
+// Pairs under evaluation guard against cycles: reaching one again gives
+// BUnknown, which is always sound. Finished pairs are memoized, so shared
+// subgraphs are evaluated once per query.
+//
+// Number constraints compare their subject by identity only and never
+// walk further, so Number queries need no such state.
+type booleanWalk struct {
+	active map[booleanPair]bool
+	memo   map[booleanPair]BValue
+}
+
+type booleanPair struct {
+	p, o *BooleanPrivate
+}
+
+func newBooleanWalk() *booleanWalk {
+	return &booleanWalk{
+		active: map[booleanPair]bool{},
+		memo:   map[booleanPair]BValue{},
+	}
+}
+
+// walkConstraint is implemented by constraints evaluated through their
+// subjects, so nested queries share walk state
+type walkConstraint interface {
+	equalWalk(obj *BooleanPrivate, w *booleanWalk) (BValue, error)
+}
+
+func (w *booleanWalk) enter(p, o *BooleanPrivate) (BValue, bool) {
+	key := booleanPair{p, o}
+	if val, ok := w.memo[key]; ok {
+		return val, false
+	}
+	if w.active[key] {
+		return BUnknown, false
+	}
+	w.active[key] = true
+	return BUnknown, true
+}
+
+func (w *booleanWalk) leave(p, o *BooleanPrivate, val BValue) {
+	key := booleanPair{p, o}
+	delete(w.active, key)
+	w.memo[key] = val
+}
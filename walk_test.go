@@ -0,0 +1,110 @@
+package virtual_types
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type WalkSuite struct {
+	suite.Suite
+}
+
+// cyclicBooleans returns unknown Booleans each equal to the next one, the
+// last is equal to the first
+func cyclicBooleans(n int) []Boolean {
+	res := make([]Boolean, n)
+	for i := range res {
+		res[i] = NewBoolean()
+	}
+	for i := range res {
+		res[i].p.constraints = []BooleanConstraint{BooleanEqual{subject: res[(i+1)%n].p}}
+	}
+	return res
+}
+
+func (s *WalkSuite) TestBooleanCycle() {
+	assert := assert.New(s.T())
+
+	for _, n := range []int{1, 2, 5} {
+		bs := cyclicBooleans(n)
+		o := NewBoolean()
+		assert.True(bs[0].Equal(o).IsUnknown(), n)
+		assert.True(bs[0].Equal(o).Not().IsUnknown(), n)
+		assert.True(o.Equal(bs[n-1]).IsUnknown(), n)
+		assert.True(bs[0].And(o).IsUnknown(), n)
+		assert.True(bs[0].Or(o).IsUnknown(), n)
+		assert.True(bs[0].Equal(bs[0]).IsTrue(), n)
+		assert.True(bs[0].Equal(bs[0].Not()).IsFalse(), n)
+	}
+
+	// cycle through BooleanNotEqual and BooleanOr
+	a, b := NewBoolean(), NewBoolean()
+	a.p.constraints = []BooleanConstraint{NewBooleanOr(BooleanNotEqual{subject: b.p}, BooleanEqual{subject: a.p})}
+	b.p.constraints = []BooleanConstraint{BooleanNotEqual{subject: a.p}}
+	o := NewBoolean()
+	assert.True(a.Equal(o).IsUnknown())
+	assert.True(b.Equal(o).IsUnknown())
+	assert.True(a.Equal(b).IsFalse())
+}
+
+func (s *WalkSuite) TestBooleanCycleJSON() {
+	assert := assert.New(s.T())
+
+	data := []byte(`{"roots":[0,1],"nodes":[` +
+		`{"type":"Boolean","val":"unknown","constraints":[{"kind":"BooleanEqual","subject":1}]},` +
+		`{"type":"Boolean","val":"unknown","constraints":[{"kind":"BooleanNotEqual","subject":0}]}]}`)
+	values, err := UnmarshalValuesJSON(data)
+	assert.Nil(err)
+	assert.Len(values, 2)
+
+	a, b := values[0].(Boolean), values[1].(Boolean)
+	assert.True(a.Equal(NewBoolean()).IsUnknown())
+	assert.True(b.Not().Equal(NewBoolean()).IsUnknown())
+	assert.True(a.Equal(b).IsFalse())
+}
+
+func (s *WalkSuite) TestMemo() {
+	assert := assert.New(s.T())
+
+	// diamond: both variants reach shared subject
+	shared := cyclicBooleans(3)[0]
+	l, r := NewBoolean(), NewBoolean()
+	l.p.constraints = []BooleanConstraint{BooleanEqual{subject: shared.p}}
+	r.p.constraints = []BooleanConstraint{BooleanEqual{subject: shared.p}}
+	top := NewBoolean()
+	top.p.constraints = []BooleanConstraint{NewBooleanOr(BooleanEqual{subject: l.p}, BooleanEqual{subject: r.p})}
+
+	o := NewBoolean()
+	w := newBooleanWalk()
+	assert.True(top.p.equalWalk(o.p, w).val == BUnknown)
+	assert.Len(w.active, 0)
+	val, ok := w.memo[booleanPair{shared.p, o.p}]
+	assert.True(ok)
+	assert.Equal(BUnknown, val)
+
+	// memoized result is reused as is
+	w.memo[booleanPair{shared.p, o.p}] = BTrue
+	assert.True(shared.p.equalWalk(o.p, w).val == BTrue)
+	assert.True(shared.Equal(o).IsUnknown())
+}
+
+func (s *WalkSuite) TestNumberCycle() {
+	assert := assert.New(s.T())
+
+	x, y := NewNumberSegment(0, 10), NewNumberSegment(0, 10)
+	x.p.constraints = []NumberConstraint{NumberLess{subject: y.p}}
+	y.p.constraints = []NumberConstraint{NumberGreater{subject: x.p}, NumberLess{subject: y.p}}
+
+	assert.True(x.Less(y).IsTrue())
+	assert.True(y.Greater(x).IsTrue())
+	assert.True(x.Equal(y).IsFalse())
+	assert.True(x.Less(NewNumberSegment(0, 10)).IsUnknown())
+	sum, err := x.Add(y)
+	assert.Nil(err)
+	assert.True(sum.Less(y).IsUnknown())
+}
+
+func TestWalk(t *testing.T) {
+	suite.Run(t, new(WalkSuite))
+}